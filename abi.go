@@ -0,0 +1,251 @@
+package merkletree
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedABIType is the error for an ABI type which is not supported by the encoder.
+	ErrUnsupportedABIType = errors.New("unsupported ABI type")
+	// ErrInvalidABIValue is the error for a value which cannot be encoded as its ABI type.
+	ErrInvalidABIValue = errors.New("invalid value for the ABI type")
+)
+
+// abiWordSize is the size of an ABI word in bytes.
+const abiWordSize = 32
+
+// ABIEncode encodes the values in the same way as abi.encode of Solidity.
+// The supported types are address, bool, uint<M>, int<M>, bytes<M>, bytes, string, and the dynamic arrays
+// T[] of the static types among them.
+// The integers can be given as Go integers, *big.Int, json.Number, or decimal or 0x-prefixed hex strings,
+// and the addresses and bytes as []byte or 0x-prefixed hex strings.
+func ABIEncode(types []string, values []any) ([]byte, error) {
+	if len(types) != len(values) {
+		return nil, fmt.Errorf("%w: %d types for %d values", ErrInvalidABIValue, len(types), len(values))
+	}
+	var (
+		head = make([]byte, 0, len(types)*abiWordSize)
+		tail []byte
+	)
+	for i, typ := range types {
+		word, dynamic, err := abiEncodeValue(typ, values[i])
+		if err != nil {
+			return nil, err
+		}
+		if !dynamic {
+			head = append(head, word...)
+			continue
+		}
+		// The head of a dynamic value is the offset of its tail from the start of the encoding.
+		head = append(head, abiUint(big.NewInt(int64(len(types)*abiWordSize+len(tail))))...)
+		tail = append(tail, word...)
+	}
+	return append(head, tail...), nil
+}
+
+// ABIDataBlock is the DataBlock of typed values, which are serialized by ABIEncode.
+// With Keccak256HashFunc, its leaf is keccak256(abi.encode(values...)) as computed in Solidity.
+type ABIDataBlock struct {
+	// Types are the ABI types of the values, e.g. address, uint256, bytes32 and string.
+	Types []string
+	// Values are the values to encode.
+	Values []any
+}
+
+// NewABIDataBlock creates an ABIDataBlock of the values with the ABI types.
+func NewABIDataBlock(types []string, values ...any) *ABIDataBlock {
+	return &ABIDataBlock{
+		Types:  types,
+		Values: values,
+	}
+}
+
+// Serialize returns the ABI encoding of the values.
+func (b *ABIDataBlock) Serialize() ([]byte, error) {
+	return ABIEncode(b.Types, b.Values)
+}
+
+// abiEncodeValue encodes a single value. For the dynamic types, it returns the tail encoding.
+func abiEncodeValue(typ string, value any) (encoded []byte, dynamic bool, err error) {
+	switch {
+	case strings.HasSuffix(typ, "[]"):
+		return abiEncodeArray(strings.TrimSuffix(typ, "[]"), value)
+	case typ == "address":
+		b, err := abiBytes(value)
+		if err != nil || len(b) != 20 {
+			return nil, false, fmt.Errorf("%w: %v as %s", ErrInvalidABIValue, value, typ)
+		}
+		return abiPadLeft(b), false, nil
+	case typ == "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, false, fmt.Errorf("%w: %v as %s", ErrInvalidABIValue, value, typ)
+		}
+		if b {
+			return abiUint(big.NewInt(1)), false, nil
+		}
+		return abiUint(new(big.Int)), false, nil
+	case typ == "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("%w: %v as %s", ErrInvalidABIValue, value, typ)
+		}
+		return abiDynamicBytes([]byte(s)), true, nil
+	case typ == "bytes":
+		b, err := abiBytes(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %v as %s", ErrInvalidABIValue, value, typ)
+		}
+		return abiDynamicBytes(b), true, nil
+	case strings.HasPrefix(typ, "bytes"):
+		size, err := strconv.Atoi(typ[len("bytes"):])
+		if err != nil || size < 1 || size > abiWordSize {
+			return nil, false, fmt.Errorf("%w: %s", ErrUnsupportedABIType, typ)
+		}
+		b, err := abiBytes(value)
+		if err != nil || len(b) != size {
+			return nil, false, fmt.Errorf("%w: %v as %s", ErrInvalidABIValue, value, typ)
+		}
+		return abiPadRight(b), false, nil
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		signed := strings.HasPrefix(typ, "int")
+		bitSize, err := abiIntBitSize(strings.TrimPrefix(strings.TrimPrefix(typ, "u"), "int"))
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %s", ErrUnsupportedABIType, typ)
+		}
+		n, err := abiBigInt(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %v as %s", ErrInvalidABIValue, value, typ)
+		}
+		lower, upper := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(bitSize))
+		if signed {
+			upper.Rsh(upper, 1)
+			lower.Neg(upper)
+		}
+		if n.Cmp(lower) < 0 || n.Cmp(upper) >= 0 {
+			return nil, false, fmt.Errorf("%w: %v out of range of %s", ErrInvalidABIValue, value, typ)
+		}
+		if n.Sign() < 0 {
+			// Two's complement in 256 bits.
+			n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 8*abiWordSize))
+		}
+		return abiUint(n), false, nil
+	}
+	return nil, false, fmt.Errorf("%w: %s", ErrUnsupportedABIType, typ)
+}
+
+// abiEncodeArray encodes the tail of a dynamic array of static elements, given as a slice.
+func abiEncodeArray(elemType string, value any) ([]byte, bool, error) {
+	var elems []any
+	switch v := value.(type) {
+	case []any:
+		elems = v
+	case [][]byte:
+		for _, e := range v {
+			elems = append(elems, e)
+		}
+	case []string:
+		for _, e := range v {
+			elems = append(elems, e)
+		}
+	default:
+		return nil, false, fmt.Errorf("%w: %v as %s[]", ErrInvalidABIValue, value, elemType)
+	}
+	encoded := abiUint(big.NewInt(int64(len(elems))))
+	for _, elem := range elems {
+		word, dynamic, err := abiEncodeValue(elemType, elem)
+		if err != nil {
+			return nil, false, err
+		}
+		if dynamic {
+			return nil, false, fmt.Errorf("%w: %s[]", ErrUnsupportedABIType, elemType)
+		}
+		encoded = append(encoded, word...)
+	}
+	return encoded, true, nil
+}
+
+// abiIntBitSize parses the bit size of an integer type, which is 256 if it is omitted.
+func abiIntBitSize(s string) (int, error) {
+	if s == "" {
+		return 256, nil
+	}
+	bitSize, err := strconv.Atoi(s)
+	if err != nil || bitSize < 8 || bitSize > 256 || bitSize%8 != 0 {
+		return 0, ErrUnsupportedABIType
+	}
+	return bitSize, nil
+}
+
+// abiBigInt converts an integer value to *big.Int.
+func abiBigInt(value any) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return new(big.Int).Set(v), nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case int32:
+		return big.NewInt(int64(v)), nil
+	case uint:
+		return new(big.Int).SetUint64(uint64(v)), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case uint32:
+		return new(big.Int).SetUint64(uint64(v)), nil
+	case json.Number:
+		return abiBigInt(string(v))
+	case string:
+		n, ok := new(big.Int).SetString(v, 0)
+		if !ok {
+			return nil, ErrInvalidABIValue
+		}
+		return n, nil
+	}
+	return nil, ErrInvalidABIValue
+}
+
+// abiBytes converts a bytes value to []byte.
+func abiBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if !strings.HasPrefix(v, "0x") && !strings.HasPrefix(v, "0X") {
+			return nil, ErrInvalidABIValue
+		}
+		return hex.DecodeString(v[2:])
+	}
+	return nil, ErrInvalidABIValue
+}
+
+// abiUint encodes a non-negative integer less than 2^256 as an ABI word.
+func abiUint(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, abiWordSize))
+}
+
+// abiPadLeft pads the bytes with leading zeros to an ABI word.
+func abiPadLeft(b []byte) []byte {
+	word := make([]byte, abiWordSize)
+	copy(word[abiWordSize-len(b):], b)
+	return word
+}
+
+// abiPadRight pads the bytes with trailing zeros to a multiple of the ABI word size.
+func abiPadRight(b []byte) []byte {
+	padded := make([]byte, (len(b)+abiWordSize-1)/abiWordSize*abiWordSize)
+	copy(padded, b)
+	return padded
+}
+
+// abiDynamicBytes encodes the tail of a dynamic bytes or string value.
+func abiDynamicBytes(b []byte) []byte {
+	return append(abiUint(big.NewInt(int64(len(b)))), abiPadRight(b)...)
+}
@@ -0,0 +1,155 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// abiHex joins the hex encoded words of an ABI encoding.
+func abiHex(words ...string) string {
+	return strings.Join(words, "")
+}
+
+func TestABIEncode(t *testing.T) {
+	const (
+		zeroWord = "0000000000000000000000000000000000000000000000000000000000000000"
+		oneWord  = "0000000000000000000000000000000000000000000000000000000000000001"
+	)
+	tests := []struct {
+		name    string
+		types   []string
+		values  []any
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "test_address_uint256",
+			types:  []string{"address", "uint256"},
+			values: []any{"0x1111111111111111111111111111111111111111", "5000000000000000000"},
+			want: abiHex(
+				"0000000000000000000000001111111111111111111111111111111111111111",
+				"0000000000000000000000000000000000000000000000004563918244f40000",
+			),
+		},
+		{
+			name:   "test_bool_int",
+			types:  []string{"bool", "int8", "uint"},
+			values: []any{true, -1, big.NewInt(0)},
+			want: abiHex(
+				oneWord,
+				"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+				zeroWord,
+			),
+		},
+		{
+			name:   "test_bytes32",
+			types:  []string{"bytes32", "bytes4"},
+			values: []any{"0x" + strings.Repeat("ab", 32), []byte{1, 2, 3, 4}},
+			want: abiHex(
+				strings.Repeat("ab", 32),
+				"0102030400000000000000000000000000000000000000000000000000000000",
+			),
+		},
+		{
+			name:   "test_dynamic",
+			types:  []string{"string", "uint256", "bytes"},
+			values: []any{"hello", 1, "0x"},
+			want: abiHex(
+				"0000000000000000000000000000000000000000000000000000000000000060",
+				oneWord,
+				"00000000000000000000000000000000000000000000000000000000000000a0",
+				"0000000000000000000000000000000000000000000000000000000000000005",
+				"68656c6c6f000000000000000000000000000000000000000000000000000000",
+				zeroWord,
+			),
+		},
+		{name: "test_uint8_overflow", types: []string{"uint8"}, values: []any{256}, wantErr: ErrInvalidABIValue},
+		{name: "test_negative_uint", types: []string{"uint256"}, values: []any{"-1"}, wantErr: ErrInvalidABIValue},
+		{name: "test_short_address", types: []string{"address"}, values: []any{"0x1234"}, wantErr: ErrInvalidABIValue},
+		{
+			name:   "test_array",
+			types:  []string{"bytes32[]", "uint32"},
+			values: []any{[][]byte{bytes.Repeat([]byte{0xab}, 32)}, uint32(5)},
+			want: abiHex(
+				"0000000000000000000000000000000000000000000000000000000000000040",
+				"0000000000000000000000000000000000000000000000000000000000000005",
+				oneWord,
+				strings.Repeat("ab", 32),
+			),
+		},
+		{name: "test_unsupported_type", types: []string{"string[]"}, values: []any{[]string{"a"}}, wantErr: ErrUnsupportedABIType},
+		{name: "test_unknown_type", types: []string{"fixed128x18"}, values: []any{1}, wantErr: ErrUnsupportedABIType},
+		{name: "test_length_mismatch", types: []string{"bool"}, values: nil, wantErr: ErrInvalidABIValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ABIEncode(tt.types, tt.values)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ABIEncode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && hex.EncodeToString(got) != tt.want {
+				t.Errorf("ABIEncode() = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeccak256HashFunc(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{data: nil, want: "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{data: []byte("hello"), want: "1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8"},
+	}
+	for _, tt := range tests {
+		for _, hashFunc := range []TypeHashFunc{Keccak256HashFunc, Keccak256HashFuncParallel} {
+			got, err := hashFunc(tt.data)
+			if err != nil {
+				t.Fatalf("hashFunc() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("hashFunc(%q) = %x, want %s", tt.data, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestABIDataBlock_openZeppelin(t *testing.T) {
+	// The OpenZeppelin leaf is keccak256(bytes.concat(keccak256(abi.encode(values...)))),
+	// so hashing the leaves of a tree of ABIDataBlocks once more gives the StandardMerkleTree leaves.
+	leafEncoding := []string{"address", "uint256"}
+	blocks := make([]DataBlock, len(ozValues))
+	for i, value := range ozValues {
+		blocks[i] = NewABIDataBlock(leafEncoding, value...)
+	}
+	config := &Config{HashFunc: Keccak256HashFunc, SortSiblingPairs: true}
+	leaves := make([]DataBlock, len(blocks))
+	for i, block := range blocks {
+		leaf, err := dataBlockToLeaf(block, config)
+		if err != nil {
+			t.Fatalf("dataBlockToLeaf() error = %v", err)
+		}
+		leaves[i] = BytesBlock(leaf)
+	}
+	m, err := New(config, leaves)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := hex.EncodeToString(m.Root); got != ozRoot {
+		t.Errorf("root = %s, want %s", got, ozRoot)
+	}
+	for i, value := range ozValues {
+		want, err := StandardLeafHash(leafEncoding, value)
+		if err != nil {
+			t.Fatalf("StandardLeafHash() error = %v", err)
+		}
+		if !bytes.Equal(m.Leaves[i], want) {
+			t.Errorf("leaf %d = %x, want %x", i, m.Leaves[i], want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package merkletree
+
+import (
+	"bytes"
+	"math/bits"
+)
+
+// PieceInfo is a piece commitment (commP) with its padded piece size.
+type PieceInfo struct {
+	// Commitment is the root of the piece tree.
+	Commitment []byte
+	// Size is the padded piece size in bytes, a power of two of at least 128 bytes.
+	Size uint64
+}
+
+// PieceInclusion is the proof that a piece commitment is a node of an aggregate tree at the given offset.
+type PieceInclusion struct {
+	// Offset is the padded byte offset of the piece in the aggregate, a multiple of the piece size.
+	Offset uint64
+	// Size is the padded piece size in bytes.
+	Size uint64
+	// AggregateSize is the padded size of the aggregate in bytes.
+	AggregateSize uint64
+	// Proof is the proof of the piece commitment from its level up to the aggregate root.
+	Proof *Proof
+}
+
+// Aggregate is a deal piece aggregated from many client pieces, in the style of the Proof of Data Segment
+// Inclusion (PoDSI). The pieces are placed in order, each one aligned to its own size, and the space
+// between them is filled with zero padding. Only the nodes above the pieces are kept in memory.
+type Aggregate struct {
+	// Root is the piece commitment of the aggregate.
+	Root []byte
+	// Size is the padded size of the aggregate in bytes.
+	Size uint64
+	// Pieces are the aggregated pieces.
+	Pieces []PieceInfo
+	// Offsets are the padded byte offsets of the pieces in the aggregate.
+	Offsets []uint64
+	// config is the configuration with the resolved padding table.
+	config *Config
+	// levels[i] holds the nodes at level i that are not zero padding, keyed by their index.
+	levels []map[uint64][]byte
+}
+
+// NewAggregate aggregates the pieces into a tree of the given padded aggregate size.
+// If aggregateSize is 0, the smallest power-of-two size holding all the pieces is used.
+// If config is nil, the configuration of NewCommPConfig is used. The empty space is filled with
+// the nodes of the Padding table, or the ZeroPaddingTable of the configuration if it is empty.
+func NewAggregate(config *Config, aggregateSize uint64, pieces []PieceInfo) (*Aggregate, error) {
+	if len(pieces) == 0 {
+		return nil, ErrInvalidNumOfDataBlocks
+	}
+	if config == nil {
+		config = NewCommPConfig(false)
+	}
+	config = copyConfig(config)
+	if config.Arity > 2 {
+		return nil, ErrUnsupportedArity
+	}
+	if config.Padding[0] == nil {
+		padding, err := ZeroPaddingTable(config)
+		if err != nil {
+			return nil, err
+		}
+		config.Padding = padding
+	}
+
+	// Place the pieces in order, aligning each one to its size.
+	offsets := make([]uint64, len(pieces))
+	var end uint64
+	for i, piece := range pieces {
+		if err := checkPieceSize(piece.Size); err != nil {
+			return nil, err
+		}
+		if len(piece.Commitment) != len(config.Padding[0]) {
+			return nil, ErrInvalidLeafSize
+		}
+		offsets[i] = (end + piece.Size - 1) &^ (piece.Size - 1)
+		end = offsets[i] + piece.Size
+		if end > MaxPaddedPieceSize {
+			return nil, ErrAggregateTooLarge
+		}
+	}
+	if aggregateSize == 0 {
+		aggregateSize = uint64(1) << bits.Len64(end-1)
+	}
+	if err := checkPieceSize(aggregateSize); err != nil {
+		return nil, err
+	}
+	if end > aggregateSize {
+		return nil, ErrAggregateTooLarge
+	}
+
+	a := &Aggregate{
+		Size:    aggregateSize,
+		Pieces:  pieces,
+		Offsets: offsets,
+		config:  config,
+		levels:  make([]map[uint64][]byte, pieceLevel(aggregateSize)+1),
+	}
+	for i := range a.levels {
+		a.levels[i] = make(map[uint64][]byte)
+	}
+	for i, piece := range pieces {
+		a.levels[pieceLevel(piece.Size)][offsets[i]/piece.Size] = piece.Commitment
+	}
+	concatFunc := concatFuncOf(config)
+	for level := 0; level < len(a.levels)-1; level++ {
+		for idx := range a.levels[level] {
+			if _, ok := a.levels[level+1][idx>>1]; ok {
+				continue
+			}
+			parent, err := config.HashFunc(concatFunc(a.node(level, idx&^1), a.node(level, idx|1)))
+			if err != nil {
+				return nil, err
+			}
+			a.levels[level+1][idx>>1] = parent
+		}
+	}
+	a.Root = a.levels[len(a.levels)-1][0]
+	return a, nil
+}
+
+// checkPieceSize checks that the padded piece size is a supported power of two.
+func checkPieceSize(size uint64) error {
+	if size < Fr32PaddedChunkSize || size&(size-1) != 0 {
+		return ErrPieceSizeNotPowerOfTwo
+	}
+	if size > MaxPaddedPieceSize {
+		return ErrInvalidPieceSize
+	}
+	return nil
+}
+
+// pieceLevel returns the tree level of the root of a piece with the given padded size.
+func pieceLevel(size uint64) int {
+	return bits.TrailingZeros64(size / Fr32LeafSize)
+}
+
+// node returns the node at the given level and index, which is the padding node if it is not kept.
+func (a *Aggregate) node(level int, idx uint64) []byte {
+	if node, ok := a.levels[level][idx]; ok {
+		return node
+	}
+	return a.config.Padding[level]
+}
+
+// PieceProof returns the inclusion proof of the piece at the given index in the aggregate.
+func (a *Aggregate) PieceProof(pieceIdx int) (*PieceInclusion, error) {
+	if pieceIdx < 0 || pieceIdx >= len(a.Pieces) {
+		return nil, ErrPieceIndexOutOfRange
+	}
+	var (
+		size  = a.Pieces[pieceIdx].Size
+		level = pieceLevel(size)
+		idx   = a.Offsets[pieceIdx] / size
+		proof = &Proof{Siblings: make([][]byte, 0, len(a.levels)-1-level)}
+	)
+	for i := 0; level+i < len(a.levels)-1; i++ {
+		if idx&1 == 0 {
+			proof.Path |= 1 << i
+		}
+		proof.Siblings = append(proof.Siblings, a.node(level+i, idx^1))
+		idx >>= 1
+	}
+	return &PieceInclusion{
+		Offset:        a.Offsets[pieceIdx],
+		Size:          size,
+		AggregateSize: a.Size,
+		Proof:         proof,
+	}, nil
+}
+
+// VerifyPieceInclusion checks that the piece commitment sits at the offset of the inclusion proof
+// inside the aggregate root. If config is nil, the configuration of NewCommPConfig is used.
+func VerifyPieceInclusion(commitment []byte, inclusion *PieceInclusion, root []byte, config *Config) (bool, error) {
+	if inclusion == nil || inclusion.Proof == nil {
+		return false, ErrProofIsNil
+	}
+	if err := checkPieceSize(inclusion.Size); err != nil {
+		return false, err
+	}
+	if err := checkPieceSize(inclusion.AggregateSize); err != nil {
+		return false, err
+	}
+	if inclusion.Offset%inclusion.Size != 0 || inclusion.Size > inclusion.AggregateSize ||
+		inclusion.Offset > inclusion.AggregateSize-inclusion.Size {
+		return false, nil
+	}
+	// The proof must lead from the piece position to the aggregate root.
+	var (
+		steps = pieceLevel(inclusion.AggregateSize) - pieceLevel(inclusion.Size)
+		idx   = inclusion.Offset / inclusion.Size
+	)
+	if len(inclusion.Proof.Siblings) != steps || inclusion.Proof.Path != uint32(^idx)&(1<<steps-1) {
+		return false, nil
+	}
+	if config == nil {
+		config = NewCommPConfig(false)
+	}
+	result, err := proofRoot(commitment, inclusion.Proof, copyConfig(config))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(result, root), nil
+}
@@ -0,0 +1,174 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// randomPiece returns the Fr32 padded leaves and the commitment of a random piece of the padded size.
+func randomPiece(t *testing.T, paddedSize uint64) ([]DataBlock, PieceInfo) {
+	t.Helper()
+	data := make([]byte, UnpaddedPieceSize(paddedSize))
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := Fr32DataBlocks(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatalf("Fr32DataBlocks() error = %v", err)
+	}
+	m, err := New(NewCommPConfig(false), blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return blocks, PieceInfo{Commitment: m.Root, Size: paddedSize}
+}
+
+func TestNewAggregate(t *testing.T) {
+	tests := []struct {
+		name          string
+		sizes         []uint64
+		aggregateSize uint64
+		wantOffsets   []uint64
+		wantSize      uint64
+	}{
+		{
+			name:        "test_aligned",
+			sizes:       []uint64{512, 128, 256},
+			wantOffsets: []uint64{0, 512, 768},
+			wantSize:    1024,
+		},
+		{
+			name:        "test_alignment_gap",
+			sizes:       []uint64{128, 512, 128},
+			wantOffsets: []uint64{0, 512, 1024},
+			wantSize:    2048,
+		},
+		{
+			name:          "test_larger_aggregate",
+			sizes:         []uint64{256, 128},
+			aggregateSize: 4096,
+			wantOffsets:   []uint64{0, 256},
+			wantSize:      4096,
+		},
+		{
+			name:        "test_single_piece",
+			sizes:       []uint64{1024},
+			wantOffsets: []uint64{0},
+			wantSize:    1024,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				pieces      []PieceInfo
+				pieceBlocks [][]DataBlock
+			)
+			for _, size := range tt.sizes {
+				blocks, piece := randomPiece(t, size)
+				pieces = append(pieces, piece)
+				pieceBlocks = append(pieceBlocks, blocks)
+			}
+			a, err := NewAggregate(nil, tt.aggregateSize, pieces)
+			if err != nil {
+				t.Fatalf("NewAggregate() error = %v", err)
+			}
+			if a.Size != tt.wantSize {
+				t.Errorf("Size = %d, want %d", a.Size, tt.wantSize)
+			}
+			for i, offset := range a.Offsets {
+				if offset != tt.wantOffsets[i] {
+					t.Errorf("Offsets[%d] = %d, want %d", i, offset, tt.wantOffsets[i])
+				}
+			}
+
+			// Build the full aggregate tree from the leaves of the pieces and zero leaves.
+			blocks := zeroPieceBlocks(int(a.Size))
+			for i, offset := range a.Offsets {
+				copy(blocks[offset/Fr32LeafSize:], pieceBlocks[i])
+			}
+			want := pieces[0].Commitment
+			if len(blocks) > len(pieceBlocks[0]) {
+				full, err := New(NewCommPConfig(false), blocks)
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				want = full.Root
+			}
+			if !bytes.Equal(a.Root, want) {
+				t.Fatalf("root mismatch, got %x, want %x", a.Root, want)
+			}
+
+			for i, piece := range pieces {
+				inclusion, err := a.PieceProof(i)
+				if err != nil {
+					t.Fatalf("PieceProof() error = %v", err)
+				}
+				ok, err := VerifyPieceInclusion(piece.Commitment, inclusion, a.Root, nil)
+				if err != nil || !ok {
+					t.Fatalf("VerifyPieceInclusion() = %v, %v", ok, err)
+				}
+				if len(inclusion.Proof.Siblings) == 0 {
+					continue
+				}
+				moved := *inclusion
+				moved.Offset ^= piece.Size
+				if ok, _ = VerifyPieceInclusion(piece.Commitment, &moved, a.Root, nil); ok {
+					t.Errorf("VerifyPieceInclusion() at a wrong offset = true")
+				}
+				if ok, _ = VerifyPieceInclusion(pieces[(i+1)%len(pieces)].Commitment, inclusion, a.Root, nil); ok && len(pieces) > 1 {
+					t.Errorf("VerifyPieceInclusion() with a wrong commitment = true")
+				}
+			}
+		})
+	}
+}
+
+func TestNewAggregate_errors(t *testing.T) {
+	commitment := make([]byte, 32)
+	tests := []struct {
+		name          string
+		pieces        []PieceInfo
+		aggregateSize uint64
+		wantErr       error
+	}{
+		{name: "test_no_pieces", wantErr: ErrInvalidNumOfDataBlocks},
+		{
+			name:    "test_not_power_of_two",
+			pieces:  []PieceInfo{{Commitment: commitment, Size: 384}},
+			wantErr: ErrPieceSizeNotPowerOfTwo,
+		},
+		{
+			name:    "test_too_small",
+			pieces:  []PieceInfo{{Commitment: commitment, Size: 64}},
+			wantErr: ErrPieceSizeNotPowerOfTwo,
+		},
+		{
+			name:    "test_invalid_commitment",
+			pieces:  []PieceInfo{{Commitment: commitment[:31], Size: 128}},
+			wantErr: ErrInvalidLeafSize,
+		},
+		{
+			name:          "test_too_large",
+			pieces:        []PieceInfo{{Commitment: commitment, Size: 256}, {Commitment: commitment, Size: 512}},
+			aggregateSize: 512,
+			wantErr:       ErrAggregateTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAggregate(nil, tt.aggregateSize, tt.pieces); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewAggregate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	a, err := NewAggregate(nil, 0, []PieceInfo{{Commitment: commitment, Size: 128}})
+	if err != nil {
+		t.Fatalf("NewAggregate() error = %v", err)
+	}
+	if _, err = a.PieceProof(1); !errors.Is(err, ErrPieceIndexOutOfRange) {
+		t.Errorf("PieceProof() error = %v, want %v", err, ErrPieceIndexOutOfRange)
+	}
+}
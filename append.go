@@ -0,0 +1,244 @@
+package merkletree
+
+import (
+	"math/bits"
+	"runtime"
+
+	"github.com/txaty/gool"
+)
+
+// levelUpdate holds the nodes of a level from index start to the end of the level.
+type levelUpdate struct {
+	start int
+	nodes [][]byte
+}
+
+// Append adds the data blocks as new leaves after the existing ones. Only the nodes on the right edge of the tree
+// are recomputed, and the resulting root is the same as building the tree by New over all the data blocks.
+// The leafMap and the cached proofs in ModeProofGenAndTreeBuild are updated as well.
+// This method is only available when the configuration mode is ModeTreeBuild or ModeProofGenAndTreeBuild,
+// and it must not be called concurrently with other methods of the tree.
+func (m *MerkleTree) Append(blocks ...DataBlock) error {
+	if m.Mode != ModeTreeBuild && m.Mode != ModeProofGenAndTreeBuild {
+		return ErrTreeNotBuilt
+	}
+	if m.Arity > 2 {
+		return ErrUnsupportedArity
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	numLeaves := m.NumLeaves + len(blocks)
+	if numLeaves > 1<<MaxDepth {
+		return ErrTooManyDataBlocks
+	}
+	depth := bits.Len(uint(numLeaves - 1))
+
+	if m.RunInParallel {
+		m.openWorkerPool()
+		defer m.wp.Close()
+	}
+	leaves, err := m.modifiedLeaves(blocks, depth)
+	if err != nil {
+		return err
+	}
+
+	// Compute the changed nodes before modifying the tree, so that the tree is unchanged if the hashing fails.
+	updates, root, err := m.appendLevels(leaves, numLeaves, depth)
+	if err != nil {
+		return err
+	}
+	for i, update := range updates {
+		for j, node := range update.nodes {
+			if err = m.NodeStore.Put(i, update.start+j, node); err != nil {
+				return err
+			}
+		}
+	}
+	oldNumLeaves := m.NumLeaves
+	if len(m.Leaves) == oldNumLeaves {
+		if m.ContiguousNodes {
+			m.appendContiguousLeaves(leaves)
+		} else {
+			m.Leaves = append(m.Leaves, leaves...)
+		}
+	}
+	m.NumLeaves, m.Depth, m.Root = numLeaves, depth, root
+
+	m.leafMapMu.Lock()
+	if m.leafMap != nil {
+		for i, leaf := range leaves {
+			m.leafMap.add(string(leaf), oldNumLeaves+i)
+		}
+	}
+	m.leafMapMu.Unlock()
+
+	// The cached proofs of the old leaves are updated with the changed siblings,
+	// and the proofs of the new leaves are generated from the node store.
+	if m.Proofs != nil {
+		levels := make([]nodeUpdates, len(updates))
+		for i, update := range updates {
+			levels[i].nodes = update.nodes
+			levels[i].indices = make([]int, len(update.nodes))
+			for j := range update.nodes {
+				levels[i].indices[j] = update.start + j
+			}
+		}
+		if err = m.updateCachedProofs(levels, oldNumLeaves); err != nil {
+			return err
+		}
+		for i := oldNumLeaves; i < numLeaves; i++ {
+			proof, err := m.nodeProof(0, i)
+			if err != nil {
+				return err
+			}
+			m.Proofs = append(m.Proofs, proof)
+		}
+	}
+	return nil
+}
+
+// appendLevels computes the nodes of each level changed by appending the leaves and the new root.
+// On level i, the nodes from index m.NumLeaves>>i are changed, and the node before them is kept in the
+// work buffer when needed to pair the nodes.
+func (m *MerkleTree) appendLevels(leaves [][]byte, numLeaves, depth int) ([]levelUpdate, []byte, error) {
+	var (
+		updates = make([]levelUpdate, depth)
+		start   = m.NumLeaves
+		lo      = start &^ 1
+		buffer  = make([][]byte, 0, start-lo+len(leaves)+1)
+	)
+	if lo < start {
+		node, err := m.appendedNode(0, lo)
+		if err != nil {
+			return nil, nil, err
+		}
+		buffer = append(buffer, node)
+	}
+	buffer = append(buffer, leaves...)
+	count := numLeaves
+	for i := 0; i < depth; i++ {
+		if count&1 == 1 && !m.RFC6962 {
+			if m.Duplicates || m.Padding[0] == nil {
+				buffer = append(buffer, buffer[len(buffer)-1])
+			} else {
+				buffer = append(buffer, m.Padding[i])
+			}
+		}
+		if i < m.Depth {
+			updates[i] = levelUpdate{start: start, nodes: buffer[start-lo:]}
+		} else {
+			// A new level also stores the old root on its left.
+			updates[i] = levelUpdate{start: lo, nodes: buffer}
+		}
+		if i == depth-1 {
+			break
+		}
+
+		// The first node of the next level is on the left of the changed nodes if its index is odd.
+		start >>= 1
+		next := make([][]byte, 0, len(buffer)>>1+2)
+		if start&1 == 1 {
+			node, err := m.appendedNode(i+1, start-1)
+			if err != nil {
+				return nil, nil, err
+			}
+			next = append(next, node)
+		}
+		nodes, err := m.computeTreeNodes(buffer, len(buffer)&^1)
+		if err != nil {
+			return nil, nil, err
+		}
+		next = append(next, nodes...)
+		if len(buffer)&1 == 1 {
+			// The odd last node is promoted in RFC 6962 mode.
+			next = append(next, buffer[len(buffer)-1])
+		}
+		buffer, lo, count = next, start&^1, (count+1)>>1
+	}
+	root, err := m.HashFunc(m.concatHashFunc(buffer[0], buffer[1]))
+	if err != nil {
+		return nil, nil, err
+	}
+	return updates, root, nil
+}
+
+// appendedNode returns an unchanged node of the tree before appending the leaves.
+// The level above the top level only has the root, which is the left child of a new level.
+func (m *MerkleTree) appendedNode(level, idx int) ([]byte, error) {
+	if level == m.Depth {
+		return m.Root, nil
+	}
+	return m.NodeStore.Get(level, idx)
+}
+
+// openWorkerPool creates the worker pool for the parallel computation on a built tree.
+// The caller closes the pool when the computation is done.
+func (m *MerkleTree) openWorkerPool() {
+	if m.NumRoutines <= 0 {
+		m.NumRoutines = runtime.NumCPU()
+	}
+	m.wp = gool.NewPool[workerArgs, error](m.NumRoutines, 0)
+}
+
+// modifiedLeaves generates the leaves of the data blocks added to or updated in a built tree of the given depth.
+// For the contiguous node layout, the sizes of the leaves and the padding of the new levels are checked.
+func (m *MerkleTree) modifiedLeaves(blocks []DataBlock, depth int) ([][]byte, error) {
+	for _, block := range blocks {
+		if block == nil {
+			return nil, ErrDataBlockIsNil
+		}
+	}
+	var (
+		leaves [][]byte
+		err    error
+	)
+	if m.RunInParallel {
+		leaves, err = m.generateLeavesInParallel(blocks)
+	} else {
+		leaves, err = m.generateLeaves(blocks)
+	}
+	if err != nil || !m.ContiguousNodes {
+		return leaves, err
+	}
+	if m.nodeWidth == 0 {
+		width, err := hashSize(m.HashFunc)
+		if err != nil {
+			return nil, err
+		}
+		m.nodeWidth = width
+	}
+	for _, leaf := range leaves {
+		if len(leaf) != m.nodeWidth {
+			return nil, ErrInvalidLeafSize
+		}
+	}
+	if !m.Duplicates && m.Padding[0] != nil {
+		for i := m.Depth; i < depth; i++ {
+			if len(m.Padding[i]) != m.nodeWidth {
+				return nil, ErrInvalidLeafSize
+			}
+		}
+	}
+	return leaves, nil
+}
+
+// appendContiguousLeaves packs the appended leaves after the contiguous leaves.
+// The slice grows geometrically and keeps the space for a padding node, as initContiguous does.
+func (m *MerkleTree) appendContiguousLeaves(leaves [][]byte) {
+	width := m.nodeWidth
+	data := m.contiguousLeaves()
+	if size := (m.NumLeaves + len(leaves) + 1) * width; cap(data) < size {
+		grown := make([]byte, len(data), size<<1)
+		copy(grown, data)
+		data = grown
+		for i := range m.Leaves {
+			m.Leaves[i] = data[i*width : (i+1)*width : (i+1)*width]
+		}
+	}
+	for _, leaf := range leaves {
+		data = append(data, leaf...)
+		m.Leaves = append(m.Leaves, data[len(data)-width:len(data):len(data)])
+	}
+	m.leafData = data
+}
@@ -0,0 +1,173 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMerkleTree_Append(t *testing.T) {
+	padding := [MaxDepth][]byte{}
+	for i := range padding {
+		padding[i] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	configs := []struct {
+		name   string
+		config Config
+	}{
+		{name: "test_default", config: Config{}},
+		{name: "test_duplicates", config: Config{Duplicates: true}},
+		{name: "test_padding", config: Config{Padding: padding}},
+		{name: "test_zero_padding", config: Config{ZeroPadding: true}},
+		{name: "test_sort_sibling_pairs", config: Config{SortSiblingPairs: true}},
+		{name: "test_parallel", config: Config{RunInParallel: true, NumRoutines: 4}},
+		{name: "test_contiguous", config: Config{ContiguousNodes: true, Padding: padding}},
+		{name: "test_rfc6962", config: Config{RFC6962: true}},
+	}
+	blocks := generatedTestDataBlocks(70)
+	for _, tt := range configs {
+		for _, mode := range []TypeConfigMode{ModeTreeBuild, ModeProofGenAndTreeBuild} {
+			for _, chunk := range []int{1, 3, 16} {
+				config := tt.config
+				config.Mode = mode
+				m, err := New(&config, blocks[:2])
+				if err != nil {
+					t.Fatalf("%s: New() error = %v", tt.name, err)
+				}
+				for n := 2; n < len(blocks); n += chunk {
+					end := min(n+chunk, len(blocks))
+					if err = m.Append(blocks[n:end]...); err != nil {
+						t.Fatalf("%s: Append() error = %v", tt.name, err)
+					}
+					want, err := New(&config, blocks[:end])
+					if err != nil {
+						t.Fatalf("%s: New() error = %v", tt.name, err)
+					}
+					if !bytes.Equal(m.Root, want.Root) || m.NumLeaves != end || m.Depth != want.Depth {
+						t.Fatalf("%s mode %d: %d leaves after Append(), root = %x, want %x",
+							tt.name, mode, end, m.Root, want.Root)
+					}
+					for level := 0; level < want.Depth; level++ {
+						if got, want := m.NodeStore.Len(level), want.NodeStore.Len(level); got != want {
+							t.Fatalf("%s: level %d has %d nodes, want %d", tt.name, level, got, want)
+						}
+					}
+					if !reflect.DeepEqual(m.Leaves, want.Leaves) {
+						t.Fatalf("%s: %d leaves after Append(), leaves mismatch", tt.name, end)
+					}
+					if mode == ModeProofGenAndTreeBuild && !reflect.DeepEqual(m.Proofs, want.Proofs) {
+						t.Fatalf("%s: %d leaves after Append(), proofs mismatch", tt.name, end)
+					}
+				}
+				for i, block := range blocks {
+					proof, err := m.Proof(block)
+					if err != nil {
+						t.Fatalf("%s: Proof() error = %v", tt.name, err)
+					}
+					if ok, err := m.VerifyByIndex(block, i, proof); err != nil || !ok {
+						t.Fatalf("%s: VerifyByIndex(%d) = %v, %v", tt.name, i, ok, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleTree_AppendNodeStore(t *testing.T) {
+	blocks := generatedTestDataBlocks(40)
+	store, err := NewFileNodeStore(t.TempDir(), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	m, err := NewWithNodeStore(&Config{}, blocks[:13], store)
+	if err != nil {
+		t.Fatalf("NewWithNodeStore() error = %v", err)
+	}
+	if err = m.Append(blocks[13:]...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	want, err := New(&Config{Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !bytes.Equal(m.Root, want.Root) {
+		t.Fatalf("root = %x, want %x", m.Root, want.Root)
+	}
+
+	// The leaves of a restored tree are not loaded, so only the node store is updated.
+	restored, err := NewFromNodeStore(&Config{}, store, len(blocks))
+	if err != nil {
+		t.Fatalf("NewFromNodeStore() error = %v", err)
+	}
+	more := generatedTestDataBlocks(5)
+	if err = restored.Append(more...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if want, err = New(&Config{}, append(append([]DataBlock(nil), blocks...), more...)); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !bytes.Equal(restored.Root, want.Root) || restored.Leaves != nil {
+		t.Fatalf("restored root = %x, want %x", restored.Root, want.Root)
+	}
+	proof, err := restored.Proof(more[4])
+	if err != nil {
+		t.Fatalf("Proof() error = %v", err)
+	}
+	if ok, err := restored.VerifyByIndex(more[4], len(blocks)+4, proof); err != nil || !ok {
+		t.Fatalf("VerifyByIndex() = %v, %v", ok, err)
+	}
+}
+
+func TestMerkleTree_AppendHeldProofs(t *testing.T) {
+	blocks := generatedTestDataBlocks(9)
+	m, err := New(&Config{Mode: ModeProofGenAndTreeBuild}, blocks[:5])
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	oldRoot := m.Root
+	held := append([]*Proof(nil), m.Proofs...)
+	want := make([]*Proof, len(held))
+	for i, proof := range held {
+		want[i] = copyProof(proof)
+	}
+	// The new level and the changed right edge are added to the cached proofs without modifying the held ones.
+	if err = m.Append(blocks[5:]...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if !reflect.DeepEqual(held, want) {
+		t.Fatalf("the held proofs are modified by Append()")
+	}
+	for i, proof := range held {
+		if ok, err := Verify(blocks[i], proof, oldRoot, nil); err != nil || !ok {
+			t.Errorf("Verify(%d) of the held proof = %v, %v", i, ok, err)
+		}
+	}
+}
+
+func TestMerkleTree_AppendErrors(t *testing.T) {
+	blocks := generatedTestDataBlocks(5)
+	m, err := New(&Config{}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err = m.Append(blocks...); !errors.Is(err, ErrTreeNotBuilt) {
+		t.Errorf("Append() error = %v, want %v", err, ErrTreeNotBuilt)
+	}
+	m, err = New(&Config{Mode: ModeTreeBuild, ContiguousNodes: true, DisableLeafHashing: true},
+		[]DataBlock{BytesBlock(make([]byte, 32)), BytesBlock(make([]byte, 32))})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	root := m.Root
+	if err = m.Append(BytesBlock("short")); !errors.Is(err, ErrInvalidLeafSize) {
+		t.Errorf("Append() error = %v, want %v", err, ErrInvalidLeafSize)
+	}
+	if !bytes.Equal(m.Root, root) || m.NumLeaves != 2 {
+		t.Errorf("tree modified by a failed Append()")
+	}
+	if err = m.Append(); err != nil || m.NumLeaves != 2 {
+		t.Errorf("Append() with no blocks = %v, %d leaves", err, m.NumLeaves)
+	}
+}
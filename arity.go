@@ -0,0 +1,244 @@
+package merkletree
+
+// arityOf returns the arity of the trees of the configuration, which is 2 unless a higher Arity is set.
+func arityOf(config *Config) int {
+	if config != nil && config.Arity > 2 {
+		return config.Arity
+	}
+	return 2
+}
+
+// checkArityConfig checks the arity and the options which only support binary trees.
+func checkArityConfig(config *Config) error {
+	if config.Arity < 0 || config.Arity == 1 {
+		return ErrInvalidArity
+	}
+	if config.Arity > 2 && (config.RFC6962 || config.SortSiblingPairs || config.ContiguousNodes) {
+		return ErrInvalidArity
+	}
+	return nil
+}
+
+// aryDepth returns the depth of a tree of the arity with the number of leaves,
+// i.e. the smallest depth such that arity^depth is not less than the number of leaves.
+func aryDepth(numLeaves, arity int) int {
+	depth := 0
+	for capacity := 1; capacity < numLeaves; capacity *= arity {
+		depth++
+	}
+	return depth
+}
+
+// concatGroup concatenates the nodes of a sibling group in order.
+func concatGroup(nodes [][]byte) []byte {
+	size := 0
+	for _, node := range nodes {
+		size += len(node)
+	}
+	result := make([]byte, 0, size)
+	for _, node := range nodes {
+		result = append(result, node...)
+	}
+	return result
+}
+
+// fixLengthNary pads the level to a multiple of the arity, with the padding node of the level
+// or the duplicates of the last node as fixOddLength does.
+func (m *MerkleTree) fixLengthNary(buffer [][]byte, depth int) [][]byte {
+	last := buffer[len(buffer)-1]
+	for len(buffer)%m.Arity != 0 {
+		if m.Duplicates || m.Padding[0] == nil {
+			buffer = append(buffer, last)
+		} else {
+			buffer = append(buffer, m.Padding[depth])
+		}
+	}
+	return buffer
+}
+
+// computeGroupNodes computes the nodes of the next tree level from the groups of nodes in the buffer,
+// whose length is a multiple of the arity.
+func (m *MerkleTree) computeGroupNodes(buffer [][]byte) ([][]byte, error) {
+	nodes := make([][]byte, len(buffer)/m.Arity)
+	if m.RunInParallel {
+		return nodes, m.computeGroupNodesInParallel(buffer, nodes)
+	}
+	var err error
+	for i := range nodes {
+		if nodes[i], err = m.HashFunc(concatGroup(buffer[i*m.Arity : (i+1)*m.Arity])); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// workerArgsComputeGroupNodes contains arguments for the workerComputeGroupNodes function.
+type workerArgsComputeGroupNodes struct {
+	hashFunc    TypeHashFunc
+	arity       int
+	buffer      [][]byte
+	nodes       [][]byte
+	startIdx    int
+	numRoutines int
+}
+
+// workerComputeGroupNodes is the worker function that computes the nodes of a level of a tree
+// of a higher arity in parallel.
+func workerComputeGroupNodes(args workerArgs) error {
+	chosenArgs := args.computeGroupNodes
+	var (
+		hashFunc    = chosenArgs.hashFunc
+		arity       = chosenArgs.arity
+		buffer      = chosenArgs.buffer
+		nodes       = chosenArgs.nodes
+		start       = chosenArgs.startIdx
+		numRoutines = chosenArgs.numRoutines
+	)
+	for i := start; i < len(nodes); i += numRoutines {
+		newHash, err := hashFunc(concatGroup(buffer[i*arity : (i+1)*arity]))
+		if err != nil {
+			return err
+		}
+		nodes[i] = newHash
+	}
+	return nil
+}
+
+// computeGroupNodesInParallel computes the nodes of the next tree level in parallel.
+func (m *MerkleTree) computeGroupNodesInParallel(buffer, nodes [][]byte) error {
+	numRoutines := m.NumRoutines
+	if numRoutines > len(nodes) {
+		numRoutines = len(nodes)
+	}
+	argList := make([]workerArgs, numRoutines)
+	for i := 0; i < numRoutines; i++ {
+		argList[i] = workerArgs{
+			computeGroupNodes: &workerArgsComputeGroupNodes{
+				hashFunc:    m.HashFunc,
+				arity:       m.Arity,
+				buffer:      buffer,
+				nodes:       nodes,
+				startIdx:    i,
+				numRoutines: numRoutines,
+			},
+		}
+	}
+	errList := m.wp.Map(workerComputeGroupNodes, argList)
+	for _, err := range errList {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildLevelsNary computes the levels of a tree of a higher arity and stores them in the node store.
+// Each level is padded to a multiple of the arity, and the root is the parent of the top level.
+// If the proofs are initialized, they are generated from the node store.
+func (m *MerkleTree) buildLevelsNary(store NodeStore) (err error) {
+	buffer := make([][]byte, m.NumLeaves)
+	copy(buffer, m.Leaves)
+	for i := 0; i < m.Depth; i++ {
+		buffer = m.fixLengthNary(buffer, i)
+		if err = putLevel(store, i, buffer); err != nil {
+			return
+		}
+		if buffer, err = m.computeGroupNodes(buffer); err != nil {
+			return
+		}
+	}
+	m.Root = buffer[0]
+	if m.Proofs == nil {
+		return
+	}
+	for i := range m.Proofs {
+		if m.Proofs[i], err = m.nodeProofFrom(store, 0, i); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// generateProofsNary generates the proofs for each leaf of a tree of a higher arity.
+// The levels are kept in a temporary node store, which is released afterwards.
+func (m *MerkleTree) generateProofsNary() error {
+	m.initProofs()
+	return m.buildLevelsNary(NewMemoryNodeStore())
+}
+
+// nodeProofNary computes the proof of the node from the levels in the node store for a tree of a higher arity.
+// For each level, the proof has the other nodes of the sibling group in order and the position of the node.
+func (m *MerkleTree) nodeProofNary(store NodeStore, level, idx int) (*Proof, error) {
+	var (
+		positions = make([]int, 0, m.Depth-level)
+		siblings  = make([][]byte, 0, (m.Depth-level)*(m.Arity-1))
+	)
+	for i := level; i < m.Depth; i++ {
+		start := idx - idx%m.Arity
+		for j := start; j < start+m.Arity; j++ {
+			if j == idx {
+				continue
+			}
+			sibling, err := store.Get(i, j)
+			if err != nil {
+				return nil, err
+			}
+			siblings = append(siblings, sibling)
+		}
+		positions = append(positions, idx-start)
+		idx /= m.Arity
+	}
+	return &Proof{
+		Siblings:  siblings,
+		Positions: positions,
+	}, nil
+}
+
+// proofRootNary traverses the proof of a tree of a higher arity from the node and computes the root hash.
+func proofRootNary(node []byte, proof *Proof, config *Config) ([]byte, error) {
+	arity := config.Arity
+	if len(proof.Siblings) != len(proof.Positions)*(arity-1) {
+		return nil, ErrInvalidArityProof
+	}
+	var (
+		result = node
+		group  = make([][]byte, arity)
+		err    error
+	)
+	for i, pos := range proof.Positions {
+		if pos < 0 || pos >= arity {
+			return nil, ErrInvalidArityProof
+		}
+		siblings := proof.Siblings[i*(arity-1) : (i+1)*(arity-1)]
+		copy(group, siblings[:pos])
+		group[pos] = result
+		copy(group[pos+1:], siblings[pos:])
+		if result, err = config.HashFunc(concatGroup(group)); err != nil {
+			return nil, err
+		}
+	}
+	// Copy the node if the proof is empty so that the original node won't be modified.
+	if len(proof.Positions) == 0 {
+		result = append([]byte{}, node...)
+	}
+	return result, nil
+}
+
+// verifyByIndexNary checks that the positions of the proof of a tree of a higher arity are the digits
+// of the leaf index in base arity, and then verifies the proof.
+func verifyByIndexNary(dataBlock DataBlock, idx int, proof *Proof, root []byte, config *Config) (bool, error) {
+	if idx < 0 {
+		return false, ErrLeafIndexOutOfRange
+	}
+	rest := idx
+	for _, pos := range proof.Positions {
+		if rest%config.Arity != pos {
+			return false, nil
+		}
+		rest /= config.Arity
+	}
+	if rest != 0 {
+		return false, ErrLeafIndexOutOfRange
+	}
+	return Verify(dataBlock, proof, root, config)
+}
@@ -0,0 +1,181 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// aryReferenceRoot computes the root of a tree of the arity level by level, padding each level
+// with the duplicates of its last node or the padding table.
+func aryReferenceRoot(t *testing.T, leaves [][]byte, arity int, padding [MaxDepth][]byte) []byte {
+	t.Helper()
+	level := append([][]byte(nil), leaves...)
+	for depth := 0; depth == 0 || len(level) > 1; depth++ {
+		last := level[len(level)-1]
+		for len(level)%arity != 0 {
+			if padding[0] == nil {
+				level = append(level, last)
+			} else {
+				level = append(level, padding[depth])
+			}
+		}
+		next := make([][]byte, len(level)/arity)
+		for i := range next {
+			var err error
+			if next[i], err = DefaultHashFunc(bytes.Join(level[i*arity:(i+1)*arity], nil)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		level = next
+	}
+	return level[0]
+}
+
+func TestMerkleTreeNew_arity(t *testing.T) {
+	padding := [MaxDepth][]byte{}
+	for i := range padding {
+		padding[i] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	for _, arity := range []int{3, 4, 8, 16} {
+		for _, numBlocks := range []int{2, 5, 8, 17, 64, 100} {
+			blocks := generatedTestDataBlocks(numBlocks)
+			configs := []Config{
+				{Arity: arity},
+				{Arity: arity, Duplicates: true},
+				{Arity: arity, Padding: padding},
+				{Arity: arity, RunInParallel: true, NumRoutines: 4},
+			}
+			for _, config := range configs {
+				var want []byte
+				for _, mode := range []TypeConfigMode{ModeProofGen, ModeTreeBuild, ModeProofGenAndTreeBuild} {
+					config.Mode = mode
+					m, err := New(&config, blocks)
+					if err != nil {
+						t.Fatalf("New() error = %v", err)
+					}
+					if want == nil {
+						want = aryReferenceRoot(t, m.Leaves, arity, config.Padding)
+					}
+					if !bytes.Equal(m.Root, want) {
+						t.Fatalf("arity %d blocks %d mode %d: root = %x, want %x", arity, numBlocks, mode, m.Root, want)
+					}
+					for i, block := range blocks {
+						proof, err := m.ProofByIndex(i)
+						if err != nil {
+							t.Fatalf("ProofByIndex() error = %v", err)
+						}
+						if len(proof.Positions) != m.Depth || len(proof.Siblings) != m.Depth*(arity-1) {
+							t.Fatalf("proof has %d positions and %d siblings", len(proof.Positions), len(proof.Siblings))
+						}
+						if ok, err := m.Verify(block, proof); err != nil || !ok {
+							t.Fatalf("arity %d blocks %d: Verify(%d) = %v, %v", arity, numBlocks, i, ok, err)
+						}
+						if ok, err := m.VerifyByIndex(block, i, proof); err != nil || !ok {
+							t.Fatalf("VerifyByIndex(%d) = %v, %v", i, ok, err)
+						}
+						if ok, _ := m.VerifyByIndex(block, (i+1)%numBlocks, proof); ok {
+							t.Fatalf("VerifyByIndex() at a wrong index = true")
+						}
+						if mode != ModeProofGen {
+							treeProof, err := m.Proof(block)
+							if err != nil || !reflect.DeepEqual(treeProof, proof) {
+								t.Fatalf("Proof(%d) = %v, %v, want %v", i, treeProof, err, proof)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleTree_arityProveNode(t *testing.T) {
+	blocks := generatedTestDataBlocks(70)
+	m, err := New(&Config{Arity: 4, Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	node, err := m.NodeStore.Get(1, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := m.ProveNode(1, 9)
+	if err != nil {
+		t.Fatalf("ProveNode() error = %v", err)
+	}
+	if ok, err := m.VerifyNode(node, 1, proof); err != nil || !ok {
+		t.Fatalf("VerifyNode() = %v, %v", ok, err)
+	}
+
+	restored, err := NewFromNodeStore(&Config{Arity: 4}, m.NodeStore, len(blocks))
+	if err != nil {
+		t.Fatalf("NewFromNodeStore() error = %v", err)
+	}
+	if !bytes.Equal(restored.Root, m.Root) {
+		t.Fatalf("restored root = %x, want %x", restored.Root, m.Root)
+	}
+}
+
+func TestMerkleTree_arityZeroPadding(t *testing.T) {
+	blocks := zeroPieceBlocks(5 * 32)
+	m, err := New(&Config{Arity: 8, DisableLeafHashing: true, ZeroPadding: true}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	// The padded tree is an all-zero tree of 8 leaves.
+	table, err := ZeroPaddingTable(&Config{Arity: 8, DisableLeafHashing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.Root, table[1]) {
+		t.Fatalf("root = %x, want %x", m.Root, table[1])
+	}
+	binary, err := ZeroPaddingTable(&Config{DisableLeafHashing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(binary[1], table[1]) {
+		t.Fatalf("the zero padding tables of different arities are the same")
+	}
+}
+
+func TestMerkleTree_arityErrors(t *testing.T) {
+	blocks := generatedTestDataBlocks(10)
+	for _, config := range []*Config{
+		{Arity: 1},
+		{Arity: -1},
+		{Arity: 4, RFC6962: true},
+		{Arity: 4, SortSiblingPairs: true},
+		{Arity: 4, ContiguousNodes: true},
+	} {
+		if _, err := New(config, blocks); !errors.Is(err, ErrInvalidArity) {
+			t.Errorf("New() error = %v, want %v", err, ErrInvalidArity)
+		}
+	}
+	m, err := New(&Config{Arity: 4, Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err = m.Append(blocks...); !errors.Is(err, ErrUnsupportedArity) {
+		t.Errorf("Append() error = %v, want %v", err, ErrUnsupportedArity)
+	}
+	if err = m.Update(0, blocks[1]); !errors.Is(err, ErrUnsupportedArity) {
+		t.Errorf("Update() error = %v, want %v", err, ErrUnsupportedArity)
+	}
+	if _, err = NewLevelCache(m, 0, 1); !errors.Is(err, ErrUnsupportedArity) {
+		t.Errorf("NewLevelCache() error = %v, want %v", err, ErrUnsupportedArity)
+	}
+	if _, err = NewStreamBuilder(&Config{Arity: 4}); !errors.Is(err, ErrUnsupportedArity) {
+		t.Errorf("NewStreamBuilder() error = %v, want %v", err, ErrUnsupportedArity)
+	}
+	proof, err := m.ProofByIndex(3)
+	if err != nil {
+		t.Fatalf("ProofByIndex() error = %v", err)
+	}
+	proof.Siblings = proof.Siblings[1:]
+	if _, err = m.Verify(blocks[3], proof); !errors.Is(err, ErrInvalidArityProof) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrInvalidArityProof)
+	}
+}
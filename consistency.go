@@ -0,0 +1,164 @@
+package merkletree
+
+import (
+	"bytes"
+	"math/bits"
+)
+
+// checkRFC6962Tree checks that the tree is built in RFC 6962 mode.
+func (m *MerkleTree) checkRFC6962Tree() error {
+	if !m.RFC6962 {
+		return ErrNotRFC6962
+	}
+	if m.Mode != ModeTreeBuild && m.Mode != ModeProofGenAndTreeBuild {
+		return ErrProofInvalidModeTreeNotBuilt
+	}
+	return nil
+}
+
+// rangeHash returns the Merkle Tree Hash of the leaves in [start, end), which must be the leaves of a stored node:
+// start is a multiple of 2^level and end is either start+2^level or the number of leaves, where 2^level is the
+// smallest power of two not less than end-start. The whole tree is the root.
+func (m *MerkleTree) rangeHash(start, end int) ([]byte, error) {
+	level := bits.Len(uint(end - start - 1))
+	if level >= m.Depth {
+		return m.Root, nil
+	}
+	return m.NodeStore.Get(level, start>>level)
+}
+
+// RootAt returns the root of the tree of the first size leaves, i.e. the root of an earlier version of
+// an append-only log. This method is only available in RFC 6962 mode with the tree built.
+func (m *MerkleTree) RootAt(size int) ([]byte, error) {
+	if err := m.checkRFC6962Tree(); err != nil {
+		return nil, err
+	}
+	if size < 1 || size > m.NumLeaves {
+		return nil, ErrInvalidTreeSize
+	}
+	// Fold the perfect subtrees of the binary decomposition of size from right to left.
+	var (
+		root []byte
+		end  = size
+	)
+	for end > 0 {
+		start := end - 1<<bits.TrailingZeros(uint(end))
+		node, err := m.rangeHash(start, end)
+		if err != nil {
+			return nil, err
+		}
+		if root == nil {
+			root = node
+			end = start
+			continue
+		}
+		if root, err = m.HashFunc(concatRFC6962Node(node, root)); err != nil {
+			return nil, err
+		}
+		end = start
+	}
+	return root, nil
+}
+
+// ConsistencyProof generates the proof that the tree of the first oldSize leaves is a prefix of the tree,
+// following PROOF(m, D[n]) of RFC 6962. This method is only available in RFC 6962 mode with the tree built.
+func (m *MerkleTree) ConsistencyProof(oldSize int) ([][]byte, error) {
+	if err := m.checkRFC6962Tree(); err != nil {
+		return nil, err
+	}
+	if oldSize < 1 || oldSize > m.NumLeaves {
+		return nil, ErrInvalidTreeSize
+	}
+	return m.subProof(oldSize, 0, m.NumLeaves, true)
+}
+
+// subProof computes SUBPROOF(size, D[start:end], complete) of RFC 6962.
+func (m *MerkleTree) subProof(size, start, end int, complete bool) ([][]byte, error) {
+	n := end - start
+	if size == n {
+		if complete {
+			return nil, nil
+		}
+		node, err := m.rangeHash(start, end)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{node}, nil
+	}
+	// k is the largest power of two smaller than n.
+	k := 1 << (bits.Len(uint(n-1)) - 1)
+	if size <= k {
+		proof, err := m.subProof(size, start, start+k, complete)
+		if err != nil {
+			return nil, err
+		}
+		node, err := m.rangeHash(start+k, end)
+		if err != nil {
+			return nil, err
+		}
+		return append(proof, node), nil
+	}
+	proof, err := m.subProof(size-k, start+k, end, false)
+	if err != nil {
+		return nil, err
+	}
+	node, err := m.rangeHash(start, start+k)
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, node), nil
+}
+
+// VerifyConsistency checks the consistency proof between the tree of oldSize leaves with oldRoot and the tree of
+// newSize leaves with newRoot, following the verification algorithm of RFC 9162.
+// The hash function of the configuration is used with the RFC 6962 node prefix.
+func VerifyConsistency(oldSize, newSize int, oldRoot, newRoot []byte, proof [][]byte, config *Config) (bool, error) {
+	if oldSize < 1 || oldSize > newSize {
+		return false, ErrInvalidTreeSize
+	}
+	if oldSize == newSize {
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot), nil
+	}
+	if len(proof) == 0 {
+		return false, nil
+	}
+	// Work on a copy so that the configuration shared by other goroutines is not modified.
+	config = copyConfig(config)
+
+	// If the old tree is a perfect subtree, its root is the first node of the path.
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([][]byte{oldRoot}, proof...)
+	}
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	var (
+		fr  = proof[0]
+		sr  = proof[0]
+		err error
+	)
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false, nil
+		}
+		if fn&1 == 1 || fn == sn {
+			if fr, err = config.HashFunc(concatRFC6962Node(c, fr)); err != nil {
+				return false, err
+			}
+			if sr, err = config.HashFunc(concatRFC6962Node(c, sr)); err != nil {
+				return false, err
+			}
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else if sr, err = config.HashFunc(concatRFC6962Node(sr, c)); err != nil {
+			return false, err
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(fr, oldRoot) && bytes.Equal(sr, newRoot), nil
+}
@@ -0,0 +1,143 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestMerkleTree_ConsistencyProofVectors(t *testing.T) {
+	// Consistency proofs of the Certificate Transparency test vectors.
+	tests := []struct {
+		oldSize int
+		newSize int
+		proof   []string
+	}{
+		{1, 1, nil},
+		{1, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{6, 8, []string{
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 5, []string{
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+	blocks := rfc6962Blocks(t)
+	for _, tt := range tests {
+		m, err := New(&Config{RFC6962: true, Mode: ModeTreeBuild}, blocks[:tt.newSize])
+		if tt.newSize == 1 {
+			// A tree requires at least two leaves, use the full tree to prove the size one prefix.
+			m, err = New(&Config{RFC6962: true, Mode: ModeTreeBuild}, blocks)
+		}
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		proof, err := m.ConsistencyProof(tt.oldSize)
+		if err != nil {
+			t.Fatalf("ConsistencyProof() error = %v", err)
+		}
+		if tt.newSize == 1 {
+			continue
+		}
+		if len(proof) != len(tt.proof) {
+			t.Fatalf("%d -> %d: proof length = %d, want %d", tt.oldSize, tt.newSize, len(proof), len(tt.proof))
+		}
+		for i := range proof {
+			if got := hex.EncodeToString(proof[i]); got != tt.proof[i] {
+				t.Errorf("%d -> %d: proof[%d] = %s, want %s", tt.oldSize, tt.newSize, i, got, tt.proof[i])
+			}
+		}
+		oldRoot, _ := hex.DecodeString(rfc6962Roots[tt.oldSize-1])
+		ok, err := VerifyConsistency(tt.oldSize, tt.newSize, oldRoot, m.Root, proof, nil)
+		if err != nil || !ok {
+			t.Errorf("%d -> %d: VerifyConsistency() = %v, %v", tt.oldSize, tt.newSize, ok, err)
+		}
+	}
+}
+
+func TestMerkleTree_ConsistencyProof(t *testing.T) {
+	blocks := generatedTestDataBlocks(40)
+	data := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		data[i], _ = block.Serialize()
+	}
+	for _, parallel := range []bool{false, true} {
+		config := &Config{RFC6962: true, Mode: ModeProofGenAndTreeBuild, RunInParallel: parallel}
+		for n := 2; n <= len(blocks); n++ {
+			m, err := New(config, blocks[:n])
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			for size := 1; size <= n; size++ {
+				oldRoot, err := m.RootAt(size)
+				if err != nil {
+					t.Fatalf("RootAt() error = %v", err)
+				}
+				if want := rfc6962ReferenceRoot(data[:size]); !bytes.Equal(oldRoot, want) {
+					t.Fatalf("size %d of %d: RootAt() = %x, want %x", size, n, oldRoot, want)
+				}
+				proof, err := m.ConsistencyProof(size)
+				if err != nil {
+					t.Fatalf("ConsistencyProof() error = %v", err)
+				}
+				ok, err := VerifyConsistency(size, n, oldRoot, m.Root, proof, config)
+				if err != nil || !ok {
+					t.Fatalf("size %d of %d: VerifyConsistency() = %v, %v", size, n, ok, err)
+				}
+				if size == n {
+					continue
+				}
+				if ok, _ = VerifyConsistency(size, n, m.Root, m.Root, proof, config); ok {
+					t.Errorf("size %d of %d: VerifyConsistency() with a wrong old root = true", size, n)
+				}
+				if ok, _ = VerifyConsistency(size, n, oldRoot, oldRoot, proof, config); ok {
+					t.Errorf("size %d of %d: VerifyConsistency() with a wrong new root = true", size, n)
+				}
+				if ok, _ = VerifyConsistency(size, n, oldRoot, m.Root, proof[:len(proof)-1], config); ok {
+					t.Errorf("size %d of %d: VerifyConsistency() with a truncated proof = true", size, n)
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleTree_ConsistencyProofErrors(t *testing.T) {
+	blocks := generatedTestDataBlocks(5)
+	m, err := New(&Config{Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err = m.ConsistencyProof(2); !errors.Is(err, ErrNotRFC6962) {
+		t.Errorf("ConsistencyProof() error = %v, want %v", err, ErrNotRFC6962)
+	}
+	if _, err = m.RootAt(2); !errors.Is(err, ErrNotRFC6962) {
+		t.Errorf("RootAt() error = %v, want %v", err, ErrNotRFC6962)
+	}
+	m, err = New(&Config{RFC6962: true}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err = m.ConsistencyProof(2); !errors.Is(err, ErrProofInvalidModeTreeNotBuilt) {
+		t.Errorf("ConsistencyProof() error = %v, want %v", err, ErrProofInvalidModeTreeNotBuilt)
+	}
+	m, err = New(&Config{RFC6962: true, Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, size := range []int{0, -1, 6} {
+		if _, err = m.ConsistencyProof(size); !errors.Is(err, ErrInvalidTreeSize) {
+			t.Errorf("ConsistencyProof(%d) error = %v, want %v", size, err, ErrInvalidTreeSize)
+		}
+	}
+	if _, err = VerifyConsistency(3, 2, m.Root, m.Root, nil, nil); !errors.Is(err, ErrInvalidTreeSize) {
+		t.Errorf("VerifyConsistency() error = %v, want %v", err, ErrInvalidTreeSize)
+	}
+}
@@ -0,0 +1,242 @@
+package merkletree
+
+// initContiguous determines the node width from the hash function and packs the leaves into one
+// contiguous byte slice for the contiguous node layout.
+func (m *MerkleTree) initContiguous() error {
+	width, err := hashSize(m.HashFunc)
+	if err != nil {
+		return err
+	}
+	m.nodeWidth = width
+	if !m.Duplicates && m.Padding[0] != nil {
+		for i := 0; i < m.Depth; i++ {
+			if len(m.Padding[i]) != m.nodeWidth {
+				return ErrInvalidLeafSize
+			}
+		}
+	}
+	// Reserve the space for a padding node, so that the leaves can be used as the level 0 in place.
+	w := m.nodeWidth
+	leafData := make([]byte, m.NumLeaves*w, (m.NumLeaves+1)*w)
+	for i, leaf := range m.Leaves {
+		if len(leaf) != w {
+			return ErrInvalidLeafSize
+		}
+		copy(leafData[i*w:], leaf)
+		// Limit the capacity, so that appending to a leaf does not overwrite the next one.
+		m.Leaves[i] = leafData[i*w : (i+1)*w : (i+1)*w]
+	}
+	m.leafData = leafData
+	return nil
+}
+
+// contiguousLeaves returns the contiguous byte slice of the leaves packed by initContiguous.
+func (m *MerkleTree) contiguousLeaves() []byte {
+	return m.leafData
+}
+
+// contiguousViews returns the nodes of a contiguous level as views into the level slice.
+func contiguousViews(level []byte, count, width int) [][]byte {
+	views := make([][]byte, count)
+	for i := range views {
+		views[i] = level[i*width : (i+1)*width : (i+1)*width]
+	}
+	return views
+}
+
+// putContiguousLevel stores all the nodes of a contiguous level in the node store.
+func putContiguousLevel(store NodeStore, level int, data []byte, width int) error {
+	if flatStore, ok := store.(*FlatNodeStore); ok && flatStore.width == width {
+		flatStore.setLevel(level, data)
+		return nil
+	}
+	for i := 0; i*width < len(data); i++ {
+		if err := store.Put(level, i, data[i*width:(i+1)*width]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getContiguousLevel returns all the nodes of a level in the node store as a contiguous byte slice.
+// The level of a FlatNodeStore with the same width is returned without copying, so it must not be modified.
+func getContiguousLevel(store NodeStore, level, width int) ([]byte, error) {
+	if flatStore, ok := store.(*FlatNodeStore); ok && flatStore.width == width {
+		if level < 0 || level >= len(flatStore.levels) {
+			return nil, ErrNodeNotFound
+		}
+		return flatStore.levels[level], nil
+	}
+	data := make([]byte, 0, store.Len(level)*width)
+	for i := 0; i < store.Len(level); i++ {
+		node, err := store.Get(level, i)
+		if err != nil {
+			return nil, err
+		}
+		if len(node) != width {
+			return nil, ErrInvalidNodeSize
+		}
+		data = append(data, node...)
+	}
+	return data, nil
+}
+
+// fixOddLengthContiguous adjusts a contiguous level with an odd number of nodes by appending a node,
+// in the same way as fixOddLength.
+func (m *MerkleTree) fixOddLengthContiguous(level []byte, count, depth int) ([]byte, int) {
+	if count&1 == 0 {
+		return level, count
+	}
+	width := m.nodeWidth
+	level = level[:count*width]
+	if m.Duplicates || m.Padding[0] == nil {
+		level = append(level, level[(count-1)*width:]...)
+	} else {
+		level = append(level, m.Padding[depth]...)
+	}
+	return level, count + 1
+}
+
+// hashPairContiguous computes the parent of the pair of nodes at index 2*idx and 2*idx+1 in a contiguous level.
+// If the sibling pairs are not sorted, the pair is hashed in place without concatenation.
+func (m *MerkleTree) hashPairContiguous(level []byte, idx int) ([]byte, error) {
+	width := m.nodeWidth
+	pair := level[idx*2*width : (idx+1)*2*width]
+	if m.SortSiblingPairs {
+		return m.HashFunc(concatSortHash(pair[:width], pair[width:]))
+	}
+	return m.HashFunc(pair)
+}
+
+// hashLevelContiguous computes the next contiguous level from a contiguous level with an even number of nodes.
+func (m *MerkleTree) hashLevelContiguous(level []byte, count int) ([]byte, error) {
+	width := m.nodeWidth
+	// Reserve the space for a padding node.
+	next := make([]byte, count>>1*width, (count>>1+1)*width)
+	if m.RunInParallel {
+		return next, m.hashLevelContiguousInParallel(level, next, count>>1)
+	}
+	for i := 0; i < count>>1; i++ {
+		hash, err := m.hashPairContiguous(level, i)
+		if err != nil {
+			return nil, err
+		}
+		if len(hash) != width {
+			return nil, ErrInvalidLeafSize
+		}
+		copy(next[i*width:], hash)
+	}
+	return next, nil
+}
+
+// workerArgsHashContiguous contains arguments for the workerHashContiguous function.
+type workerArgsHashContiguous struct {
+	tree        *MerkleTree
+	level       []byte
+	next        []byte
+	startIdx    int
+	numParents  int
+	numRoutines int
+}
+
+// workerHashContiguous is the worker function that computes a contiguous level in parallel.
+func workerHashContiguous(args workerArgs) error {
+	chosenArgs := args.hashContiguous
+	var (
+		tree        = chosenArgs.tree
+		level       = chosenArgs.level
+		next        = chosenArgs.next
+		start       = chosenArgs.startIdx
+		numParents  = chosenArgs.numParents
+		numRoutines = chosenArgs.numRoutines
+	)
+	for i := start; i < numParents; i += numRoutines {
+		hash, err := tree.hashPairContiguous(level, i)
+		if err != nil {
+			return err
+		}
+		if len(hash) != tree.nodeWidth {
+			return ErrInvalidLeafSize
+		}
+		copy(next[i*tree.nodeWidth:], hash)
+	}
+	return nil
+}
+
+// hashLevelContiguousInParallel computes the next contiguous level in parallel.
+func (m *MerkleTree) hashLevelContiguousInParallel(level, next []byte, numParents int) error {
+	numRoutines := m.NumRoutines
+	if numRoutines > numParents {
+		numRoutines = numParents
+	}
+	argList := make([]workerArgs, numRoutines)
+	for i := 0; i < numRoutines; i++ {
+		argList[i] = workerArgs{
+			hashContiguous: &workerArgsHashContiguous{
+				tree:        m,
+				level:       level,
+				next:        next,
+				startIdx:    i,
+				numParents:  numParents,
+				numRoutines: numRoutines,
+			},
+		}
+	}
+	errList := m.wp.Map(workerHashContiguous, argList)
+	for _, err := range errList {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateProofsContiguous updates the proofs with the nodes of a contiguous level.
+func (m *MerkleTree) updateProofsContiguous(level []byte, count, step int) {
+	views := contiguousViews(level, count, m.nodeWidth)
+	if m.RunInParallel {
+		m.updateProofsInParallel(views, count, step)
+		return
+	}
+	m.updateProofs(views, count, step)
+}
+
+// generateProofsContiguous generates the proofs for each leaf with the contiguous node layout.
+// Each level is kept in its own slice, as the proof siblings are views into the levels.
+func (m *MerkleTree) generateProofsContiguous() (err error) {
+	m.initProofs()
+	level, count := m.fixOddLengthContiguous(m.contiguousLeaves(), m.NumLeaves, 0)
+	m.updateProofsContiguous(level, count, 0)
+	for step := 1; step < m.Depth; step++ {
+		if level, err = m.hashLevelContiguous(level, count); err != nil {
+			return
+		}
+		level, count = m.fixOddLengthContiguous(level, count>>1, step)
+		m.updateProofsContiguous(level, count, step)
+	}
+	m.Root, err = m.hashPairContiguous(level, 0)
+	return
+}
+
+// buildLevelsContiguous computes the contiguous levels and stores them in the node store.
+func (m *MerkleTree) buildLevelsContiguous() (err error) {
+	level, count := m.contiguousLeaves(), m.NumLeaves
+	for i := 0; i < m.Depth; i++ {
+		level, count = m.fixOddLengthContiguous(level, count, i)
+		if err = putContiguousLevel(m.NodeStore, i, level, m.nodeWidth); err != nil {
+			return
+		}
+		if m.Proofs != nil {
+			m.updateProofsContiguous(level, count, i)
+		}
+		if i == m.Depth-1 {
+			break
+		}
+		if level, err = m.hashLevelContiguous(level, count); err != nil {
+			return
+		}
+		count >>= 1
+	}
+	m.Root, err = m.hashPairContiguous(level, 0)
+	return
+}
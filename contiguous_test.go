@@ -0,0 +1,172 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/txaty/go-merkletree/mock"
+)
+
+func TestMerkleTreeNew_contiguousNodes(t *testing.T) {
+	zeroPadding, err := ZeroPaddingTable(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := []struct {
+		name   string
+		config Config
+	}{
+		{name: "test_default", config: Config{}},
+		{name: "test_parallel", config: Config{RunInParallel: true, NumRoutines: 4}},
+		{name: "test_padding", config: Config{Padding: zeroPadding}},
+		{name: "test_sort_sibling_pairs", config: Config{SortSiblingPairs: true}},
+		{name: "test_disable_leaf_hashing", config: Config{DisableLeafHashing: true}},
+	}
+	modes := []TypeConfigMode{ModeProofGen, ModeTreeBuild, ModeProofGenAndTreeBuild}
+	for _, tt := range configs {
+		for _, mode := range modes {
+			for _, numBlocks := range []int{2, 5, 8, 100} {
+				blocks := generatedTestDataBlocks(numBlocks)
+				if tt.config.DisableLeafHashing {
+					blocks = zeroPieceBlocks(numBlocks * 32)
+				}
+				nestedConfig := tt.config
+				nestedConfig.Mode = mode
+				want, err := New(&nestedConfig, blocks)
+				if err != nil {
+					t.Fatalf("%s: New() error = %v", tt.name, err)
+				}
+				contiguousConfig := nestedConfig
+				contiguousConfig.ContiguousNodes = true
+				got, err := New(&contiguousConfig, blocks)
+				if err != nil {
+					t.Fatalf("%s: New() contiguous error = %v", tt.name, err)
+				}
+				if !bytes.Equal(got.Root, want.Root) {
+					t.Fatalf("%s mode %d blocks %d: root mismatch", tt.name, mode, numBlocks)
+				}
+				for i, block := range blocks {
+					proof, err := got.Proof(block)
+					if mode == ModeProofGen {
+						proof, err = got.Proofs[i], nil
+					}
+					if err != nil {
+						t.Fatalf("%s mode %d: Proof() error = %v", tt.name, mode, err)
+					}
+					ok, err := got.Verify(block, proof)
+					if err != nil || !ok {
+						t.Fatalf("%s mode %d blocks %d: Verify() = %v, %v", tt.name, mode, numBlocks, ok, err)
+					}
+				}
+				if mode == ModeTreeBuild {
+					if _, ok := got.NodeStore.(*FlatNodeStore); !ok {
+						t.Errorf("%s: NodeStore = %T, want *FlatNodeStore", tt.name, got.NodeStore)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleTreeNew_contiguousNodesInvalidLeafSize(t *testing.T) {
+	blocks := []DataBlock{
+		&mock.DataBlock{Data: make([]byte, 32)},
+		&mock.DataBlock{Data: make([]byte, 31)},
+	}
+	_, err := New(&Config{DisableLeafHashing: true, ContiguousNodes: true}, blocks)
+	if !errors.Is(err, ErrInvalidLeafSize) {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidLeafSize)
+	}
+
+	config := &Config{ContiguousNodes: true}
+	config.Padding[0] = []byte("short")
+	_, err = New(config, generatedTestDataBlocks(3))
+	if !errors.Is(err, ErrInvalidLeafSize) {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidLeafSize)
+	}
+}
+
+func TestFlatNodeStore(t *testing.T) {
+	s := NewFlatNodeStore(4)
+	if err := s.Put(1, 2, []byte("cccc")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if got := s.Len(1); got != 3 {
+		t.Errorf("Len() = %d, want 3", got)
+	}
+	if err := s.Put(1, 0, []byte("aaaa")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	got, err := s.Get(1, 0)
+	if err != nil || !bytes.Equal(got, []byte("aaaa")) {
+		t.Errorf("Get() = %s, %v", got, err)
+	}
+	if _, err := s.Get(1, 3); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, ErrNodeNotFound)
+	}
+	if err := s.Put(0, 0, []byte("a")); !errors.Is(err, ErrInvalidNodeSize) {
+		t.Errorf("Put() error = %v, want %v", err, ErrInvalidNodeSize)
+	}
+}
+
+func TestMerkleTree_contiguousLeavesCapacity(t *testing.T) {
+	m, err := New(&Config{ContiguousNodes: true}, generatedTestDataBlocks(5))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	next := append([]byte(nil), m.Leaves[1]...)
+	// Appending to a leaf must not overwrite the next leaf in the contiguous slice.
+	_ = append(m.Leaves[0], 0xff)
+	if !bytes.Equal(m.Leaves[1], next) {
+		t.Errorf("Leaves[1] = %x, want %x", m.Leaves[1], next)
+	}
+}
+
+func TestNewLevelCache_contiguousNodes(t *testing.T) {
+	blocks := generatedTestDataBlocks(21)
+	want, err := New(&Config{Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	m, err := New(&Config{Mode: ModeTreeBuild, ContiguousNodes: true}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	wantLC, err := NewLevelCache(want, 1, 3)
+	if err != nil {
+		t.Fatalf("NewLevelCache() error = %v", err)
+	}
+	lc, err := NewLevelCache(m, 1, 3)
+	if err != nil {
+		t.Fatalf("NewLevelCache() error = %v", err)
+	}
+	if lc.Nodes != nil || len(lc.FlatNodes) != 3 || lc.NodeWidth != 32 {
+		t.Fatalf("NewLevelCache() does not use the contiguous node layout")
+	}
+
+	// The cache is still valid after a round trip through the file.
+	path := filepath.Join(t.TempDir(), "level_cache.gob")
+	if err = lc.StoreToFile(path); err != nil {
+		t.Fatalf("StoreToFile() error = %v", err)
+	}
+	if lc, err = NewLevelCacheFromFile(path); err != nil {
+		t.Fatalf("NewLevelCacheFromFile() error = %v", err)
+	}
+	config := &Config{DisableLeafHashing: true}
+	for _, node := range wantLC.Nodes[0] {
+		wantProof, wantRoot, err := wantLC.Prove(BytesBlock(node), config)
+		if err != nil {
+			t.Fatalf("Prove() error = %v", err)
+		}
+		proof, root, err := lc.Prove(BytesBlock(node), config)
+		if err != nil {
+			t.Fatalf("Prove() error = %v", err)
+		}
+		if !reflect.DeepEqual(proof, wantProof) || !bytes.Equal(root, wantRoot) {
+			t.Fatalf("Prove() = %v, %x, want %v, %x", proof, root, wantProof, wantRoot)
+		}
+	}
+}
@@ -22,18 +22,26 @@
 
 package merkletree
 
-import "crypto/sha256"
+import (
+	"crypto/sha256"
+	"hash"
+	"sync"
+)
 
-// sha256Digest is the reusable digest for DefaultHashFunc.
-// It is used to avoid creating a new hash digest for every call to DefaultHashFunc.
-var sha256Digest = sha256.New()
+// sha256DigestPool is the pool of reusable digests for DefaultHashFunc.
+// It is used to avoid creating a new hash digest for every call to DefaultHashFunc,
+// while each digest is only used by one goroutine at a time.
+var sha256DigestPool = sync.Pool{
+	New: func() any {
+		return sha256.New()
+	},
+}
 
 // DefaultHashFunc is the default hash function used when no user-specified hash function is provided.
-// It implements the SHA256 hash function and reuses sha256Digest to reduce memory allocations.
+// It implements the SHA256 hash function and reuses the digests in sha256DigestPool to reduce memory
+// allocations. It is safe for concurrent use.
 func DefaultHashFunc(data []byte) ([]byte, error) {
-	defer sha256Digest.Reset()
-	sha256Digest.Write(data)
-	return sha256Digest.Sum(make([]byte, 0, sha256Digest.Size())), nil
+	return pooledSum(&sha256DigestPool, data), nil
 }
 
 // DefaultHashFuncParallel is the default hash function used by parallel algorithms when no user-specified
@@ -44,3 +52,13 @@ func DefaultHashFuncParallel(data []byte) ([]byte, error) {
 	digest.Write(data)
 	return digest.Sum(make([]byte, 0, digest.Size())), nil
 }
+
+// pooledSum computes the hash of the data with a digest taken from the pool,
+// and puts the digest back to the pool after resetting it.
+func pooledSum(pool *sync.Pool, data []byte) []byte {
+	digest := pool.Get().(hash.Hash)
+	defer pool.Put(digest)
+	defer digest.Reset()
+	digest.Write(data)
+	return digest.Sum(make([]byte, 0, digest.Size()))
+}
@@ -0,0 +1,71 @@
+package merkletree
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestDefaultHashFunc_concurrent(t *testing.T) {
+	blocks := generatedTestDataBlocks(200)
+	hashFuncs := []struct {
+		name      string
+		hashFunc  TypeHashFunc
+		reference TypeHashFunc
+	}{
+		{name: "sha256", hashFunc: DefaultHashFunc, reference: DefaultHashFuncParallel},
+		{name: "keccak256", hashFunc: Keccak256HashFunc, reference: Keccak256HashFuncParallel},
+		{name: "sha256-trunc254", hashFunc: SHA256Trunc254HashFunc, reference: SHA256Trunc254HashFuncParallel},
+	}
+	want := make([][]byte, len(hashFuncs))
+	for i, h := range hashFuncs {
+		m, err := New(&Config{HashFunc: h.reference, RunInParallel: true}, blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		want[i] = m.Root
+	}
+
+	// Independent non-parallel trees share the default hash functions across goroutines.
+	const numGoroutines = 32
+	var wg sync.WaitGroup
+	for g := 0; g < numGoroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			i := g % len(hashFuncs)
+			config := &Config{HashFunc: hashFuncs[i].hashFunc}
+			if i == 0 {
+				config = nil
+			}
+			m, err := New(config, blocks)
+			if err != nil {
+				t.Errorf("New() error = %v", err)
+				return
+			}
+			if !bytes.Equal(m.Root, want[i]) {
+				t.Errorf("%s: root = %x, want %x", hashFuncs[i].name, m.Root, want[i])
+				return
+			}
+			for j := g; j < len(blocks); j += numGoroutines {
+				if ok, err := Verify(blocks[j], m.Proofs[j], want[i], config); err != nil || !ok {
+					t.Errorf("%s: Verify(%d) = %v, %v", hashFuncs[i].name, j, ok, err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkDefaultHashFunc(b *testing.B) {
+	data := make([]byte, 64)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := DefaultHashFunc(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
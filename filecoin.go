@@ -0,0 +1,43 @@
+package merkletree
+
+import "crypto/sha256"
+
+// SHA256Trunc254HashFunc implements the SHA256-trunc254-padded hash function used by Filecoin
+// piece commitments (commP) and unsealed sector commitments (commD).
+// It computes SHA256 and zeroes the two most significant bits of the last byte, so that
+// the result fits into a BLS12-381 scalar field element.
+// It shares the SHA256 digests of DefaultHashFunc to reduce memory allocations, and it is safe for concurrent use.
+func SHA256Trunc254HashFunc(data []byte) ([]byte, error) {
+	hash := pooledSum(&sha256DigestPool, data)
+	hash[len(hash)-1] &= 0x3f
+	return hash, nil
+}
+
+// SHA256Trunc254HashFuncParallel is the version of SHA256Trunc254HashFunc for parallel algorithms.
+// It creates a new hash digest for each call instead of reusing the pooled digests.
+func SHA256Trunc254HashFuncParallel(data []byte) ([]byte, error) {
+	digest := sha256.New()
+	digest.Write(data)
+	hash := digest.Sum(make([]byte, 0, digest.Size()))
+	hash[len(hash)-1] &= 0x3f
+	return hash, nil
+}
+
+// NewCommPConfig returns a Config preset that makes New produce roots identical to
+// Filecoin piece commitments (commP) and unsealed sector commitments (commD).
+// The data blocks must be the 32-byte chunks of the Fr32 padded piece, which are used
+// as the leaves directly. If the number of data blocks is not a power of two, the piece
+// is padded with zero piece commitments up to the next power-of-two piece size.
+// If runInParallel is true, the concurrent-safe hash function is used.
+func NewCommPConfig(runInParallel bool) *Config {
+	config := &Config{
+		HashFunc:           SHA256Trunc254HashFunc,
+		RunInParallel:      runInParallel,
+		ZeroPadding:        true,
+		DisableLeafHashing: true,
+	}
+	if runInParallel {
+		config.HashFunc = SHA256Trunc254HashFuncParallel
+	}
+	return config
+}
@@ -0,0 +1,159 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"strings"
+	"testing"
+
+	"github.com/txaty/go-merkletree/mock"
+)
+
+// pieceCIDToCommitment extracts the 32-byte commitment from a Filecoin piece CID
+// (CIDv1, fil-commitment-unsealed codec, sha2-256-trunc254-padded multihash) encoded in base32.
+func pieceCIDToCommitment(t *testing.T, cid string) []byte {
+	t.Helper()
+	if !strings.HasPrefix(cid, "b") {
+		t.Fatalf("piece CID %s is not base32 encoded", cid)
+	}
+	raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(cid[1:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// version, codec (0xf101), multihash code (0x1012) and digest length, all as varints
+	prefix := []byte{0x01, 0x81, 0xe2, 0x03, 0x92, 0x20, 0x20}
+	if !bytes.HasPrefix(raw, prefix) || len(raw) != len(prefix)+32 {
+		t.Fatalf("piece CID %s has an unexpected prefix", cid)
+	}
+	return raw[len(prefix):]
+}
+
+func zeroPieceBlocks(paddedSize int) []DataBlock {
+	blocks := make([]DataBlock, paddedSize/32)
+	for i := range blocks {
+		blocks[i] = &mock.DataBlock{
+			Data: make([]byte, 32),
+		}
+	}
+	return blocks
+}
+
+func TestSHA256Trunc254HashFunc(t *testing.T) {
+	data := []byte("filecoin")
+	want := sha256.Sum256(data)
+	want[31] &= 0x3f
+	for _, hashFunc := range []TypeHashFunc{SHA256Trunc254HashFunc, SHA256Trunc254HashFuncParallel} {
+		got, err := hashFunc(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want[:]) {
+			t.Errorf("hash mismatch, got %x, want %x", got, want)
+		}
+		if got[31]&0xc0 != 0 {
+			t.Errorf("two most significant bits of the last byte are not zeroed: %x", got)
+		}
+	}
+}
+
+func TestNewCommPConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		paddedSize    int
+		mode          TypeConfigMode
+		runInParallel bool
+		pieceCID      string
+	}{
+		{
+			name:       "zero_piece_2KiB",
+			paddedSize: 2 << 10,
+			pieceCID:   "baga6ea4seaqpy7usqklokfx2vxuynmupslkeutzexe2uqurdg5vhtebhxqmpqmy",
+		},
+		{
+			name:       "zero_piece_2KiB_tree_build",
+			paddedSize: 2 << 10,
+			mode:       ModeTreeBuild,
+			pieceCID:   "baga6ea4seaqpy7usqklokfx2vxuynmupslkeutzexe2uqurdg5vhtebhxqmpqmy",
+		},
+		{
+			name:          "zero_piece_2KiB_parallel",
+			paddedSize:    2 << 10,
+			runInParallel: true,
+			pieceCID:      "baga6ea4seaqpy7usqklokfx2vxuynmupslkeutzexe2uqurdg5vhtebhxqmpqmy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewCommPConfig(tt.runInParallel)
+			config.Mode = tt.mode
+			blocks := zeroPieceBlocks(tt.paddedSize)
+			m, err := New(config, blocks)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			want := pieceCIDToCommitment(t, tt.pieceCID)
+			if !bytes.Equal(m.Root, want) {
+				t.Errorf("root mismatch, got %x, want %x", m.Root, want)
+			}
+		})
+	}
+}
+
+func TestNewCommPConfig_pieceData(t *testing.T) {
+	// The piece CIDs are computed by go-fil-commp-hashhash from the data bytes i % 251.
+	tests := []struct {
+		name          string
+		unpaddedSize  int
+		runInParallel bool
+		pieceCID      string
+	}{
+		{
+			name:         "full_piece_2KiB",
+			unpaddedSize: 2032,
+			pieceCID:     "baga6ea4seaqg5lowgrr66nlwung4w37xil4ejdvsf4e5lg4xswspkxjfppr5uni",
+		},
+		{
+			name:         "partial_piece_2KiB",
+			unpaddedSize: 1500,
+			pieceCID:     "baga6ea4seaqccvy4vqhhf5cqhuzef2d2t3u3lcwpyi4f7ytqedlvoam6nwargia",
+		},
+		{
+			name:          "full_piece_128KiB_parallel",
+			unpaddedSize:  130048,
+			runInParallel: true,
+			pieceCID:      "baga6ea4seaqh3ap5wddy3nywtm2bjof2uuxa2s4fphegugnul276ds6gjx654ci",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.unpaddedSize)
+			for i := range data {
+				data[i] = byte(i % 251)
+			}
+			want := pieceCIDToCommitment(t, tt.pieceCID)
+
+			blocks, err := Fr32DataBlocks(bytes.NewReader(data), uint64(len(data)))
+			if err != nil {
+				t.Fatalf("Fr32DataBlocks() error = %v", err)
+			}
+			m, err := New(NewCommPConfig(tt.runInParallel), blocks)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if !bytes.Equal(m.Root, want) {
+				t.Errorf("root mismatch, got %x, want %x", m.Root, want)
+			}
+
+			// Only the leaves of the data chunks, the rest of the piece is padded with zero piece commitments.
+			chunks := (tt.unpaddedSize + Fr32UnpaddedChunkSize - 1) / Fr32UnpaddedChunkSize
+			m, err = New(NewCommPConfig(tt.runInParallel), blocks[:chunks*Fr32PaddedChunkSize/Fr32LeafSize])
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if !bytes.Equal(m.Root, want) {
+				t.Errorf("root of the data chunks mismatch, got %x, want %x", m.Root, want)
+			}
+		})
+	}
+}
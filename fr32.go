@@ -0,0 +1,254 @@
+package merkletree
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const (
+	// Fr32UnpaddedChunkSize is the number of raw bytes in one Fr32 chunk (4 * 254 bits).
+	Fr32UnpaddedChunkSize = 127
+	// Fr32PaddedChunkSize is the number of bytes in one Fr32 padded chunk (4 * 256 bits).
+	Fr32PaddedChunkSize = 128
+	// Fr32LeafSize is the size of a Fr32 padded leaf, i.e. one field element.
+	Fr32LeafSize = 32
+	// MaxPaddedPieceSize is the largest padded piece size supported by the Merkle Tree, 64 GiB.
+	MaxPaddedPieceSize = uint64(Fr32LeafSize) << MaxDepth
+
+	// fr32BatchChunks is the number of chunks processed by the Fr32 readers in one batch.
+	fr32BatchChunks = 32
+)
+
+// PaddedPieceSize returns the padded piece size for the given unpadded data size.
+// The data size is rounded up to the next power-of-two piece size, whose unpadded size is
+// 127 * 2^k bytes and padded size is 128 * 2^k bytes. The minimum padded piece size is 128 bytes.
+// If the data does not fit into a piece of MaxPaddedPieceSize, it returns math.MaxUint64,
+// which is larger than any valid padded piece size.
+func PaddedPieceSize(unpaddedSize uint64) uint64 {
+	if unpaddedSize > UnpaddedPieceSize(MaxPaddedPieceSize) {
+		return math.MaxUint64
+	}
+	chunks := (unpaddedSize + Fr32UnpaddedChunkSize - 1) / Fr32UnpaddedChunkSize
+	if chunks <= 1 {
+		return Fr32PaddedChunkSize
+	}
+	return Fr32PaddedChunkSize << bits.Len64(chunks-1)
+}
+
+// UnpaddedPieceSize returns the number of raw bytes that fit into a piece of the given padded size.
+func UnpaddedPieceSize(paddedSize uint64) uint64 {
+	return paddedSize - paddedSize/Fr32PaddedChunkSize
+}
+
+// Fr32Pad pads the raw bytes in src into dst, inserting two zero bits after every 254 bits.
+// The length of src must be a multiple of 127 bytes, and dst must hold len(src) / 127 * 128 bytes.
+func Fr32Pad(dst, src []byte) {
+	chunks := len(src) / Fr32UnpaddedChunkSize
+	for c := 0; c < chunks; c++ {
+		fr32PadChunk(
+			dst[c*Fr32PaddedChunkSize:(c+1)*Fr32PaddedChunkSize],
+			src[c*Fr32UnpaddedChunkSize:(c+1)*Fr32UnpaddedChunkSize],
+		)
+	}
+}
+
+// fr32PadChunk pads a single 127-byte chunk into 128 bytes.
+func fr32PadChunk(out, in []byte) {
+	copy(out[:31], in[:31])
+	t := in[31] >> 6
+	out[31] = in[31] & 0x3f
+	var v byte
+	for i := 32; i < 64; i++ {
+		v = in[i]
+		out[i] = v<<2 | t
+		t = v >> 6
+	}
+	t = v >> 4
+	out[63] &= 0x3f
+	for i := 64; i < 96; i++ {
+		v = in[i]
+		out[i] = v<<4 | t
+		t = v >> 4
+	}
+	t = v >> 2
+	out[95] &= 0x3f
+	for i := 96; i < 127; i++ {
+		v = in[i]
+		out[i] = v<<6 | t
+		t = v >> 2
+	}
+	out[127] = t & 0x3f
+}
+
+// Fr32Unpad removes the Fr32 padding from src into dst. It is the inverse of Fr32Pad.
+// The length of src must be a multiple of 128 bytes, and dst must hold len(src) / 128 * 127 bytes.
+func Fr32Unpad(dst, src []byte) {
+	chunks := len(src) / Fr32PaddedChunkSize
+	for c := 0; c < chunks; c++ {
+		fr32UnpadChunk(
+			dst[c*Fr32UnpaddedChunkSize:(c+1)*Fr32UnpaddedChunkSize],
+			src[c*Fr32PaddedChunkSize:(c+1)*Fr32PaddedChunkSize],
+		)
+	}
+}
+
+// fr32UnpadChunk unpads a single 128-byte chunk into 127 bytes.
+func fr32UnpadChunk(out, in []byte) {
+	copy(out[:31], in[:31])
+	out[31] = in[31]&0x3f | in[32]<<6
+	for i := 32; i < 63; i++ {
+		out[i] = in[i]>>2 | in[i+1]<<6
+	}
+	out[63] = (in[63]&0x3f)>>2 | in[64]<<4
+	for i := 64; i < 95; i++ {
+		out[i] = in[i]>>4 | in[i+1]<<4
+	}
+	out[95] = (in[95]&0x3f)>>4 | in[96]<<2
+	for i := 96; i < 127; i++ {
+		out[i] = in[i]>>6 | in[i+1]<<2
+	}
+}
+
+// fr32Reader is the reader returned by NewFr32Reader.
+type fr32Reader struct {
+	src io.Reader
+	in  []byte
+	out []byte
+	// buf is the padded data that has not been read yet.
+	buf []byte
+	eof bool
+}
+
+// NewFr32Reader returns a reader that Fr32 pads the data read from src.
+// Every 127 bytes of src are padded into 128 bytes. If the length of src is not
+// a multiple of 127 bytes, the last chunk is filled with zeros before padding.
+func NewFr32Reader(src io.Reader) io.Reader {
+	return &fr32Reader{
+		src: src,
+		in:  make([]byte, fr32BatchChunks*Fr32UnpaddedChunkSize),
+		out: make([]byte, fr32BatchChunks*Fr32PaddedChunkSize),
+	}
+}
+
+// Read implements io.Reader.
+func (r *fr32Reader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.src, r.in)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, err
+		}
+		if n == 0 {
+			r.eof = true
+			return 0, io.EOF
+		}
+		if n < len(r.in) {
+			r.eof = true
+			// Zero fill the last partial chunk.
+			chunks := (n + Fr32UnpaddedChunkSize - 1) / Fr32UnpaddedChunkSize
+			for i := n; i < chunks*Fr32UnpaddedChunkSize; i++ {
+				r.in[i] = 0
+			}
+			n = chunks * Fr32UnpaddedChunkSize
+		}
+		Fr32Pad(r.out, r.in[:n])
+		r.buf = r.out[:n/Fr32UnpaddedChunkSize*Fr32PaddedChunkSize]
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fr32UnpadReader is the reader returned by NewFr32UnpadReader.
+type fr32UnpadReader struct {
+	src io.Reader
+	in  []byte
+	out []byte
+	// buf is the unpadded data that has not been read yet.
+	buf []byte
+	eof bool
+}
+
+// NewFr32UnpadReader returns a reader that removes the Fr32 padding from the data read from src.
+// Every 128 bytes of src are unpadded into 127 bytes. If the length of src is not a multiple of
+// 128 bytes, io.ErrUnexpectedEOF is returned.
+func NewFr32UnpadReader(src io.Reader) io.Reader {
+	return &fr32UnpadReader{
+		src: src,
+		in:  make([]byte, fr32BatchChunks*Fr32PaddedChunkSize),
+		out: make([]byte, fr32BatchChunks*Fr32UnpaddedChunkSize),
+	}
+}
+
+// Read implements io.Reader.
+func (r *fr32UnpadReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.src, r.in)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, err
+		}
+		if n == 0 {
+			r.eof = true
+			return 0, io.EOF
+		}
+		if n%Fr32PaddedChunkSize != 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if n < len(r.in) {
+			r.eof = true
+		}
+		Fr32Unpad(r.out, r.in[:n])
+		r.buf = r.out[:n/Fr32PaddedChunkSize*Fr32UnpaddedChunkSize]
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Fr32DataBlocks reads unpaddedSize bytes of raw data from src and turns them into the
+// leaf data blocks of a piece tree. The data is zero filled up to the unpadded size of
+// the next power-of-two piece (see PaddedPieceSize), Fr32 padded, and split into 32-byte
+// leaves. The returned data blocks can be passed to New with the config from NewCommPConfig
+// to compute the piece commitment.
+// The raw data is read and padded in batches of chunks, so only the padded piece is kept in memory.
+// If src has less than unpaddedSize bytes, io.ErrUnexpectedEOF is returned.
+func Fr32DataBlocks(src io.Reader, unpaddedSize uint64) ([]DataBlock, error) {
+	if unpaddedSize == 0 || unpaddedSize > UnpaddedPieceSize(MaxPaddedPieceSize) {
+		return nil, ErrInvalidPieceSize
+	}
+	paddedSize := PaddedPieceSize(unpaddedSize)
+	// The padding of the zero filled data is zeros, so the rest of the padded piece is left as allocated.
+	padded := make([]byte, paddedSize)
+	in := make([]byte, fr32BatchChunks*Fr32UnpaddedChunkSize)
+	for offset := uint64(0); offset < unpaddedSize; {
+		n := uint64(len(in))
+		if rest := unpaddedSize - offset; rest < n {
+			n = rest
+		}
+		if _, err := io.ReadFull(src, in[:n]); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		// Zero fill the last partial chunk.
+		chunks := (n + Fr32UnpaddedChunkSize - 1) / Fr32UnpaddedChunkSize
+		for i := n; i < chunks*Fr32UnpaddedChunkSize; i++ {
+			in[i] = 0
+		}
+		Fr32Pad(padded[offset/Fr32UnpaddedChunkSize*Fr32PaddedChunkSize:], in[:chunks*Fr32UnpaddedChunkSize])
+		offset += n
+	}
+	blocks := make([]DataBlock, paddedSize/Fr32LeafSize)
+	for i := range blocks {
+		blocks[i] = BytesBlock(padded[i*Fr32LeafSize : (i+1)*Fr32LeafSize : (i+1)*Fr32LeafSize])
+	}
+	return blocks, nil
+}
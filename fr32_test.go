@@ -0,0 +1,241 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+// fr32PadReference pads the data bit by bit, as a reference for Fr32Pad.
+func fr32PadReference(src []byte) []byte {
+	chunks := len(src) / Fr32UnpaddedChunkSize
+	dst := make([]byte, chunks*Fr32PaddedChunkSize)
+	for i := 0; i < chunks*4*254; i++ {
+		bit := src[i/8] >> (i % 8) & 1
+		j := i/254*256 + i%254
+		dst[j/8] |= bit << (j % 8)
+	}
+	return dst
+}
+
+func TestPaddedPieceSize(t *testing.T) {
+	tests := []struct {
+		unpaddedSize uint64
+		want         uint64
+	}{
+		{unpaddedSize: 1, want: 128},
+		{unpaddedSize: 127, want: 128},
+		{unpaddedSize: 128, want: 256},
+		{unpaddedSize: 254, want: 256},
+		{unpaddedSize: 255, want: 512},
+		{unpaddedSize: 1000, want: 1024},
+		{unpaddedSize: 2032, want: 2048},
+		{unpaddedSize: 2033, want: 4096},
+		{unpaddedSize: 34091302912, want: 32 << 30},
+		{unpaddedSize: UnpaddedPieceSize(MaxPaddedPieceSize), want: MaxPaddedPieceSize},
+	}
+	for _, tt := range tests {
+		if got := PaddedPieceSize(tt.unpaddedSize); got != tt.want {
+			t.Errorf("PaddedPieceSize(%d) = %d, want %d", tt.unpaddedSize, got, tt.want)
+		}
+		if got := UnpaddedPieceSize(tt.want); got < tt.unpaddedSize || got/127*128 != tt.want {
+			t.Errorf("UnpaddedPieceSize(%d) = %d", tt.want, got)
+		}
+	}
+	for _, size := range []uint64{UnpaddedPieceSize(MaxPaddedPieceSize) + 1, math.MaxUint64} {
+		if got := PaddedPieceSize(size); got <= MaxPaddedPieceSize {
+			t.Errorf("PaddedPieceSize(%d) = %d, want a size larger than the maximum piece size", size, got)
+		}
+	}
+}
+
+func TestFr32Pad(t *testing.T) {
+	ones := bytes.Repeat([]byte{0xff}, Fr32UnpaddedChunkSize)
+	padded := make([]byte, Fr32PaddedChunkSize)
+	Fr32Pad(padded, ones)
+	for i := 0; i < 4; i++ {
+		element := padded[i*32 : (i+1)*32]
+		want := append(bytes.Repeat([]byte{0xff}, 31), 0x3f)
+		if !bytes.Equal(element, want) {
+			t.Errorf("element %d = %x, want %x", i, element, want)
+		}
+	}
+
+	src := make([]byte, 16*Fr32UnpaddedChunkSize)
+	if _, err := rand.Read(src); err != nil {
+		t.Fatal(err)
+	}
+	padded = make([]byte, 16*Fr32PaddedChunkSize)
+	Fr32Pad(padded, src)
+	if want := fr32PadReference(src); !bytes.Equal(padded, want) {
+		t.Fatalf("Fr32Pad() = %x, want %x", padded, want)
+	}
+	for i := 31; i < len(padded); i += 32 {
+		if padded[i]&0xc0 != 0 {
+			t.Fatalf("padded byte %d has the top two bits set", i)
+		}
+	}
+	unpadded := make([]byte, len(src))
+	Fr32Unpad(unpadded, padded)
+	if !bytes.Equal(unpadded, src) {
+		t.Errorf("Fr32Unpad() = %x, want %x", unpadded, src)
+	}
+}
+
+func TestFr32Reader(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "test_empty", size: 0},
+		{name: "test_partial_chunk", size: 100},
+		{name: "test_one_chunk", size: Fr32UnpaddedChunkSize},
+		{name: "test_one_batch", size: fr32BatchChunks * Fr32UnpaddedChunkSize},
+		{name: "test_multiple_batches_partial", size: 3*fr32BatchChunks*Fr32UnpaddedChunkSize + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := make([]byte, tt.size)
+			if _, err := rand.Read(src); err != nil {
+				t.Fatal(err)
+			}
+			padded, err := io.ReadAll(NewFr32Reader(bytes.NewReader(src)))
+			if err != nil {
+				t.Fatalf("read padded error = %v", err)
+			}
+			chunks := (tt.size + Fr32UnpaddedChunkSize - 1) / Fr32UnpaddedChunkSize
+			if len(padded) != chunks*Fr32PaddedChunkSize {
+				t.Fatalf("padded length = %d, want %d", len(padded), chunks*Fr32PaddedChunkSize)
+			}
+			zeroFilled := make([]byte, chunks*Fr32UnpaddedChunkSize)
+			copy(zeroFilled, src)
+			if want := fr32PadReference(zeroFilled); !bytes.Equal(padded, want) {
+				t.Fatalf("padded data mismatch")
+			}
+			unpadded, err := io.ReadAll(NewFr32UnpadReader(bytes.NewReader(padded)))
+			if err != nil {
+				t.Fatalf("read unpadded error = %v", err)
+			}
+			if !bytes.Equal(unpadded, zeroFilled) {
+				t.Errorf("unpadded data mismatch")
+			}
+		})
+	}
+}
+
+func TestFr32UnpadReader_unexpectedEOF(t *testing.T) {
+	_, err := io.ReadAll(NewFr32UnpadReader(bytes.NewReader(make([]byte, Fr32PaddedChunkSize+1))))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestFr32DataBlocks(t *testing.T) {
+	random := make([]byte, 10000)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		data         []byte
+		unpaddedSize uint64
+		wantBlocks   int
+		pieceCID     string
+		wantErr      bool
+	}{
+		{
+			name:         "test_zero_piece_2KiB",
+			data:         make([]byte, 2032),
+			unpaddedSize: 2032,
+			wantBlocks:   64,
+			pieceCID:     "baga6ea4seaqpy7usqklokfx2vxuynmupslkeutzexe2uqurdg5vhtebhxqmpqmy",
+		},
+		{
+			name:         "test_zero_data_rounded_to_2KiB",
+			data:         make([]byte, 1500),
+			unpaddedSize: 1500,
+			wantBlocks:   64,
+			pieceCID:     "baga6ea4seaqpy7usqklokfx2vxuynmupslkeutzexe2uqurdg5vhtebhxqmpqmy",
+		},
+		{
+			name:         "test_random_data",
+			data:         random[:3000],
+			unpaddedSize: 3000,
+			wantBlocks:   128,
+		},
+		{
+			name:         "test_random_data_multiple_batches",
+			data:         random,
+			unpaddedSize: 10000,
+			wantBlocks:   512,
+		},
+		{
+			name:         "test_zero_size",
+			unpaddedSize: 0,
+			wantErr:      true,
+		},
+		{
+			name:         "test_short_data",
+			data:         make([]byte, 10),
+			unpaddedSize: 20,
+			wantErr:      true,
+		},
+		{
+			name:         "test_short_data_at_batch_boundary",
+			data:         random[:fr32BatchChunks*Fr32UnpaddedChunkSize],
+			unpaddedSize: 10000,
+			wantErr:      true,
+		},
+		{
+			name:         "test_size_exceeds_max_piece",
+			unpaddedSize: math.MaxUint64,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks, err := Fr32DataBlocks(bytes.NewReader(tt.data), tt.unpaddedSize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fr32DataBlocks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(blocks) != tt.wantBlocks {
+				t.Fatalf("number of blocks = %d, want %d", len(blocks), tt.wantBlocks)
+			}
+			var padded []byte
+			for _, block := range blocks {
+				data, err := block.Serialize()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(data) != Fr32LeafSize {
+					t.Fatalf("block size = %d, want %d", len(data), Fr32LeafSize)
+				}
+				padded = append(padded, data...)
+			}
+			unpadded := make([]byte, UnpaddedPieceSize(uint64(len(padded))))
+			Fr32Unpad(unpadded, padded)
+			if !bytes.Equal(unpadded[:tt.unpaddedSize], tt.data) {
+				t.Errorf("unpadded data mismatch")
+			}
+			if !bytes.Equal(unpadded[tt.unpaddedSize:], make([]byte, len(unpadded)-int(tt.unpaddedSize))) {
+				t.Errorf("data is not zero filled")
+			}
+			if tt.pieceCID == "" {
+				return
+			}
+			m, err := New(NewCommPConfig(false), blocks)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if want := pieceCIDToCommitment(t, tt.pieceCID); !bytes.Equal(m.Root, want) {
+				t.Errorf("root mismatch, got %x, want %x", m.Root, want)
+			}
+		})
+	}
+}
@@ -4,12 +4,17 @@ go 1.19
 
 require (
 	github.com/agiledragon/gomonkey/v2 v2.9.0
+	github.com/iden3/go-iden3-crypto v0.0.17
+	github.com/stretchr/testify v1.8.4
 	github.com/txaty/gool v0.1.4
+	golang.org/x/crypto v0.24.0
+	lukechampine.com/blake3 v1.3.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,155 @@
+package merkletree
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"lukechampine.com/blake3"
+)
+
+var (
+	// ErrUnknownHashName is the error for a hash function name which is not registered.
+	ErrUnknownHashName = errors.New("hash function name is not registered")
+	// ErrHashNameRegistered is the error for registering a hash function with a name already in use.
+	ErrHashNameRegistered = errors.New("hash function name is already registered")
+	// ErrHashFuncIsNil is the error for registering a nil hash function.
+	ErrHashFuncIsNil = errors.New("hash function is nil")
+	// ErrHashNameMismatch is the error for a configuration whose HashFunc is not the hash function of its HashName.
+	ErrHashNameMismatch = errors.New("hash function does not match the hash function name")
+)
+
+// The names of the built-in hash functions in the registry.
+const (
+	// HashNameSHA256 is the name of SHA-256, the default hash function.
+	HashNameSHA256 = "sha256"
+	// HashNameKeccak256 is the name of Keccak-256 used by Ethereum.
+	HashNameKeccak256 = "keccak256"
+	// HashNameSHA256Trunc254 is the name of SHA256-trunc254-padded used by Filecoin.
+	HashNameSHA256Trunc254 = "sha256-trunc254"
+	// HashNamePoseidonBN254 is the name of Poseidon over the BN254 scalar field with the circomlib parameters.
+	HashNamePoseidonBN254 = "poseidon-bn254"
+	// HashNameBLAKE2b256 is the name of BLAKE2b with 256-bit output.
+	HashNameBLAKE2b256 = "blake2b-256"
+	// HashNameBLAKE2s256 is the name of BLAKE2s with 256-bit output.
+	HashNameBLAKE2s256 = "blake2s-256"
+	// HashNameBLAKE3 is the name of BLAKE3 with 256-bit output.
+	HashNameBLAKE3 = "blake3"
+)
+
+// hashRegistry maps the names to the registered hash functions, which are all safe for concurrent use.
+var hashRegistry = struct {
+	sync.RWMutex
+	funcs map[string]TypeHashFunc
+}{
+	funcs: map[string]TypeHashFunc{
+		HashNameSHA256:         DefaultHashFuncParallel,
+		HashNameKeccak256:      Keccak256HashFuncParallel,
+		HashNameSHA256Trunc254: SHA256Trunc254HashFuncParallel,
+		HashNamePoseidonBN254:  PoseidonHashFunc,
+		HashNameBLAKE2b256:     BLAKE2b256HashFunc,
+		HashNameBLAKE2s256:     BLAKE2s256HashFunc,
+		HashNameBLAKE3:         BLAKE3HashFunc,
+	},
+}
+
+// BLAKE2b256HashFunc implements the BLAKE2b hash function with 256-bit output.
+// It does not keep any state, so it is safe for concurrent use.
+func BLAKE2b256HashFunc(data []byte) ([]byte, error) {
+	hash := blake2b.Sum256(data)
+	return hash[:], nil
+}
+
+// BLAKE2s256HashFunc implements the BLAKE2s hash function with 256-bit output.
+// It does not keep any state, so it is safe for concurrent use.
+func BLAKE2s256HashFunc(data []byte) ([]byte, error) {
+	hash := blake2s.Sum256(data)
+	return hash[:], nil
+}
+
+// BLAKE3HashFunc implements the BLAKE3 hash function with 256-bit output.
+// It does not keep any state, so it is safe for concurrent use.
+func BLAKE3HashFunc(data []byte) ([]byte, error) {
+	hash := blake3.Sum256(data)
+	return hash[:], nil
+}
+
+// RegisterHashFunc registers the hash function with the name, so that it can be selected by HashName
+// in the configuration. The hash function must be safe for concurrent use, as the registered hash functions
+// are also used with RunInParallel. The built-in names cannot be registered again.
+func RegisterHashFunc(name string, hashFunc TypeHashFunc) error {
+	if hashFunc == nil {
+		return ErrHashFuncIsNil
+	}
+	hashRegistry.Lock()
+	defer hashRegistry.Unlock()
+	if _, ok := hashRegistry.funcs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrHashNameRegistered, name)
+	}
+	hashRegistry.funcs[name] = hashFunc
+	return nil
+}
+
+// HashFuncByName returns the hash function registered with the name.
+func HashFuncByName(name string) (TypeHashFunc, error) {
+	hashRegistry.RLock()
+	defer hashRegistry.RUnlock()
+	hashFunc, ok := hashRegistry.funcs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHashName, name)
+	}
+	return hashFunc, nil
+}
+
+// HashNames returns the sorted names of the registered hash functions.
+func HashNames() []string {
+	hashRegistry.RLock()
+	defer hashRegistry.RUnlock()
+	names := make([]string, 0, len(hashRegistry.funcs))
+	for name := range hashRegistry.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namedHashFunc returns the hash function registered with the name. If the name is not registered,
+// it returns a hash function which fails with ErrUnknownHashName, so that the error is reported
+// by the first hash computation.
+func namedHashFunc(name string) TypeHashFunc {
+	hashFunc, err := HashFuncByName(name)
+	if err != nil {
+		return failingHashFunc(err)
+	}
+	return hashFunc
+}
+
+// checkHashName checks that the HashFunc of the configuration is the hash function registered with
+// its HashName if both are set. The built-in hash functions match their names, e.g. DefaultHashFunc
+// matches "sha256" although DefaultHashFuncParallel is the registered one. The hash functions are compared
+// by their code pointers, so the closures sharing the code of the registered one are not told apart.
+func checkHashName(config *Config) error {
+	if config.HashName == "" || config.HashFunc == nil {
+		return nil
+	}
+	hashFunc, err := HashFuncByName(config.HashName)
+	if err != nil {
+		return err
+	}
+	pointer := reflect.ValueOf(config.HashFunc).Pointer()
+	if pointer == reflect.ValueOf(hashFunc).Pointer() || builtinHashFuncNames[pointer] == config.HashName {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrHashNameMismatch, config.HashName)
+}
+
+// failingHashFunc returns a hash function which fails with the error.
+func failingHashFunc(err error) TypeHashFunc {
+	return func([]byte) ([]byte, error) {
+		return nil, err
+	}
+}
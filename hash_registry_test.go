@@ -0,0 +1,211 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestHashFuncByName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{
+			name: HashNameSHA256,
+			want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		{
+			name: HashNameKeccak256,
+			want: "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+		},
+		{
+			name: HashNameBLAKE2b256,
+			want: "0e5751c026e543b2e8ab2eb06099daa1d1e5df47778f7787faab45cdf12fe3a8",
+		},
+		{
+			name: HashNameBLAKE2s256,
+			want: "69217a3079908094e11121d042354a7c1f55b6482ca1a51e1b250dfd1ed0eef9",
+		},
+		{
+			name: HashNameBLAKE3,
+			want: "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashFunc, err := HashFuncByName(tt.name)
+			if err != nil {
+				t.Fatalf("HashFuncByName() error = %v", err)
+			}
+			got, err := hashFunc(nil)
+			if err != nil {
+				t.Fatalf("hashFunc() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("hash of the empty input = %x, want %s", got, tt.want)
+			}
+		})
+	}
+	if _, err := HashFuncByName("md5"); !errors.Is(err, ErrUnknownHashName) {
+		t.Errorf("HashFuncByName() error = %v, want %v", err, ErrUnknownHashName)
+	}
+}
+
+func TestRegisterHashFunc(t *testing.T) {
+	if err := RegisterHashFunc(HashNameBLAKE3, DefaultHashFuncParallel); !errors.Is(err, ErrHashNameRegistered) {
+		t.Errorf("RegisterHashFunc() error = %v, want %v", err, ErrHashNameRegistered)
+	}
+	if err := RegisterHashFunc("nil", nil); !errors.Is(err, ErrHashFuncIsNil) {
+		t.Errorf("RegisterHashFunc() error = %v, want %v", err, ErrHashFuncIsNil)
+	}
+	if err := RegisterHashFunc("test-sha256-trunc254", SHA256Trunc254HashFuncParallel); err != nil {
+		t.Fatalf("RegisterHashFunc() error = %v", err)
+	}
+	t.Cleanup(func() {
+		unregisterHashFunc("test-sha256-trunc254")
+	})
+	hashFunc, err := HashFuncByName("test-sha256-trunc254")
+	if err != nil || reflect.ValueOf(hashFunc).Pointer() != reflect.ValueOf(SHA256Trunc254HashFuncParallel).Pointer() {
+		t.Fatalf("HashFuncByName() = %v, %v", hashFunc, err)
+	}
+	found := false
+	for _, name := range HashNames() {
+		found = found || name == "test-sha256-trunc254"
+	}
+	if !found {
+		t.Errorf("HashNames() = %v, missing the registered name", HashNames())
+	}
+}
+
+func TestMerkleTree_hashName(t *testing.T) {
+	blocks := generatedTestDataBlocks(100)
+	for _, name := range []string{HashNameBLAKE2b256, HashNameBLAKE2s256, HashNameBLAKE3} {
+		hashFunc, err := HashFuncByName(name)
+		if err != nil {
+			t.Fatalf("HashFuncByName() error = %v", err)
+		}
+		want, err := New(&Config{HashFunc: hashFunc}, blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		m, err := New(&Config{HashName: name, RunInParallel: true, NumRoutines: 4}, blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if !bytes.Equal(m.Root, want.Root) {
+			t.Fatalf("%s: root = %x, want %x", name, m.Root, want.Root)
+		}
+		// The name is enough to verify the proofs without the tree.
+		for i, block := range blocks {
+			if ok, err := Verify(block, m.Proofs[i], m.Root, &Config{HashName: name}); err != nil || !ok {
+				t.Fatalf("%s: Verify(%d) = %v, %v", name, i, ok, err)
+			}
+		}
+	}
+	if _, err := New(&Config{HashName: "md5"}, blocks); !errors.Is(err, ErrUnknownHashName) {
+		t.Errorf("New() error = %v, want %v", err, ErrUnknownHashName)
+	}
+}
+
+func TestZeroPaddingTable_hashName(t *testing.T) {
+	// The registered closures are cached by their names, unlike the unnamed ones.
+	for _, prefix := range []byte{1, 2} {
+		name := fmt.Sprintf("test-prefixed-%d", prefix)
+		if err := RegisterHashFunc(name, prefixedHashFunc(prefix)); err != nil {
+			t.Fatalf("RegisterHashFunc() error = %v", err)
+		}
+		t.Cleanup(func() {
+			unregisterHashFunc(name)
+		})
+	}
+	tables := make([][MaxDepth][]byte, 2)
+	for i, name := range []string{"test-prefixed-1", "test-prefixed-2"} {
+		want, err := ZeroPaddingTable(&Config{HashFunc: prefixedHashFunc(byte(i + 1))})
+		if err != nil {
+			t.Fatalf("ZeroPaddingTable() error = %v", err)
+		}
+		if tables[i], err = ZeroPaddingTable(&Config{HashName: name}); err != nil {
+			t.Fatalf("ZeroPaddingTable() error = %v", err)
+		}
+		if !reflect.DeepEqual(tables[i], want) {
+			t.Fatalf("%s: ZeroPaddingTable() = %x, want %x", name, tables[i], want)
+		}
+		if _, ok := zeroPaddingCache.Load(zeroPaddingKey{hashName: name, arity: 2}); !ok {
+			t.Errorf("%s: the table is not cached", name)
+		}
+		// The hash function resolved from the name, e.g. by New, has the same key.
+		config := copyConfig(&Config{HashName: name})
+		if key, ok := hashCacheKey(config); !ok || key != name {
+			t.Errorf("hashCacheKey() = %s, %v, want %s", key, ok, name)
+		}
+	}
+	if bytes.Equal(tables[0][1], tables[1][1]) {
+		t.Errorf("the tables of different hash functions are equal")
+	}
+	// The tables of a HashFunc which is not the hash function of the HashName are neither cached nor derived.
+	if _, ok := hashCacheKey(&Config{HashFunc: BLAKE3HashFunc, HashName: "test-prefixed-1"}); ok {
+		t.Errorf("hashCacheKey() of a mismatched hash function is cacheable")
+	}
+	if _, err := ZeroPaddingTable(&Config{HashFunc: BLAKE3HashFunc, HashName: "test-prefixed-1"}); !errors.Is(err, ErrHashNameMismatch) {
+		t.Errorf("ZeroPaddingTable() error = %v, want %v", err, ErrHashNameMismatch)
+	}
+	if _, ok := hashCacheKey(&Config{HashFunc: prefixedHashFunc(3), HashName: HashNameBLAKE3}); ok {
+		t.Errorf("hashCacheKey() of a closure is cacheable")
+	}
+}
+
+func TestCheckHashName(t *testing.T) {
+	blocks := generatedTestDataBlocks(10)
+	// The built-in hash functions match their names, whichever variant is registered.
+	for _, config := range []*Config{
+		{HashFunc: DefaultHashFunc, HashName: HashNameSHA256},
+		{HashFunc: DefaultHashFuncParallel, HashName: HashNameSHA256},
+		{HashFunc: Keccak256HashFunc, HashName: HashNameKeccak256},
+		{HashFunc: BLAKE3HashFunc, HashName: HashNameBLAKE3},
+	} {
+		if _, err := New(config, blocks); err != nil {
+			t.Errorf("%s: New() error = %v", config.HashName, err)
+		}
+	}
+
+	config := &Config{HashFunc: BLAKE3HashFunc, HashName: HashNameSHA256}
+	if _, err := New(config, blocks); !errors.Is(err, ErrHashNameMismatch) {
+		t.Errorf("New() error = %v, want %v", err, ErrHashNameMismatch)
+	}
+	if _, err := NewFromNodeStore(config, NewMemoryNodeStore(), len(blocks)); !errors.Is(err, ErrHashNameMismatch) {
+		t.Errorf("NewFromNodeStore() error = %v, want %v", err, ErrHashNameMismatch)
+	}
+	if _, err := NewSparseMerkleTree(config); !errors.Is(err, ErrHashNameMismatch) {
+		t.Errorf("NewSparseMerkleTree() error = %v, want %v", err, ErrHashNameMismatch)
+	}
+	m, err := New(&Config{HashFunc: BLAKE3HashFunc}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err = Verify(blocks[0], m.Proofs[0], m.Root, config); !errors.Is(err, ErrHashNameMismatch) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrHashNameMismatch)
+	}
+	// An unregistered name is not matched by any hash function.
+	if _, err = New(&Config{HashFunc: BLAKE3HashFunc, HashName: "md5"}, blocks); !errors.Is(err, ErrUnknownHashName) {
+		t.Errorf("New() error = %v, want %v", err, ErrUnknownHashName)
+	}
+}
+
+// unregisterHashFunc removes the hash function registered with the name, and the tables cached for it.
+// It lets the tests register temporary hash functions.
+func unregisterHashFunc(name string) {
+	hashRegistry.Lock()
+	delete(hashRegistry.funcs, name)
+	hashRegistry.Unlock()
+	zeroPaddingCache.Range(func(key, _ any) bool {
+		if key.(zeroPaddingKey).hashName == name {
+			zeroPaddingCache.Delete(key)
+		}
+		return true
+	})
+	sparseEmptyCache.Delete(name)
+}
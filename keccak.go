@@ -0,0 +1,43 @@
+package merkletree
+
+import (
+	"hash"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// keccak256DigestPool is the pool of reusable digests for Keccak256HashFunc.
+// It is used to avoid creating a new hash digest for every call to Keccak256HashFunc.
+var keccak256DigestPool = sync.Pool{
+	New: func() any {
+		return sha3.NewLegacyKeccak256()
+	},
+}
+
+// Keccak256HashFunc implements the Keccak-256 hash function used by Ethereum and Solidity's keccak256.
+// Together with SortSiblingPairs, it builds the trees verified by MerkleProof of OpenZeppelin.
+// It reuses the digests in keccak256DigestPool to reduce memory allocations, and it is safe for concurrent use.
+func Keccak256HashFunc(data []byte) ([]byte, error) {
+	return pooledSum(&keccak256DigestPool, data), nil
+}
+
+// Keccak256HashFuncParallel is the version of Keccak256HashFunc for parallel algorithms.
+// It creates a new hash digest for each call instead of reusing the pooled digests.
+func Keccak256HashFuncParallel(data []byte) ([]byte, error) {
+	return keccak256(data), nil
+}
+
+// keccak256 computes the Keccak-256 hash of the concatenated data with a new hash digest.
+func keccak256(data ...[]byte) []byte {
+	return keccak256Sum(sha3.NewLegacyKeccak256(), data...)
+}
+
+// keccak256Sum computes the hash of the concatenated data with the digest, and resets the digest.
+func keccak256Sum(digest hash.Hash, data ...[]byte) []byte {
+	defer digest.Reset()
+	for _, d := range data {
+		digest.Write(d)
+	}
+	return digest.Sum(make([]byte, 0, digest.Size()))
+}
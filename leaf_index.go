@@ -0,0 +1,86 @@
+package merkletree
+
+import "sort"
+
+// leafIndex maps the leaves (converted to strings) to their indices in the tree.
+// A leaf occurring once is mapped to its index directly, and only the leaves occurring more than once,
+// e.g. the zero chunks of a piece, have a slice of indices.
+type leafIndex struct {
+	// single maps each leaf occurring once to its index.
+	single map[string]int
+	// multiple maps each leaf occurring more than once to its indices in ascending order.
+	multiple map[string][]int
+}
+
+// newLeafIndex creates an empty leafIndex with the space for size leaves.
+func newLeafIndex(size int) *leafIndex {
+	return &leafIndex{
+		single:   make(map[string]int, size),
+		multiple: make(map[string][]int),
+	}
+}
+
+// add adds the index of the leaf.
+func (l *leafIndex) add(key string, idx int) {
+	if indices, ok := l.multiple[key]; ok {
+		l.multiple[key] = insertIndex(indices, idx)
+		return
+	}
+	first, ok := l.single[key]
+	if !ok {
+		l.single[key] = idx
+		return
+	}
+	if first != idx {
+		delete(l.single, key)
+		l.multiple[key] = insertIndex([]int{first}, idx)
+	}
+}
+
+// remove removes the index of the leaf.
+func (l *leafIndex) remove(key string, idx int) {
+	if indices, ok := l.multiple[key]; ok {
+		if indices = removeIndex(indices, idx); len(indices) == 1 {
+			delete(l.multiple, key)
+			l.single[key] = indices[0]
+		} else {
+			l.multiple[key] = indices
+		}
+		return
+	}
+	if first, ok := l.single[key]; ok && first == idx {
+		delete(l.single, key)
+	}
+}
+
+// indices returns the indices of the leaf in ascending order, which must not be modified.
+func (l *leafIndex) indices(leaf []byte) []int {
+	if indices, ok := l.multiple[string(leaf)]; ok {
+		return indices
+	}
+	if idx, ok := l.single[string(leaf)]; ok {
+		return []int{idx}
+	}
+	return nil
+}
+
+// removeIndex removes the index from the ascending indices.
+func removeIndex(indices []int, idx int) []int {
+	i := sort.SearchInts(indices, idx)
+	if i == len(indices) || indices[i] != idx {
+		return indices
+	}
+	return append(indices[:i], indices[i+1:]...)
+}
+
+// insertIndex inserts the index into the ascending indices.
+func insertIndex(indices []int, idx int) []int {
+	i := sort.SearchInts(indices, idx)
+	if i < len(indices) && indices[i] == idx {
+		return indices
+	}
+	indices = append(indices, 0)
+	copy(indices[i+1:], indices[i:])
+	indices[i] = idx
+	return indices
+}
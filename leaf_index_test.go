@@ -0,0 +1,130 @@
+package merkletree
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/txaty/go-merkletree/mock"
+)
+
+// duplicateDataBlocks returns data blocks where the zero block occurs at every third index.
+func duplicateDataBlocks(num int) []DataBlock {
+	blocks := generatedTestDataBlocks(num)
+	for i := 0; i < num; i += 3 {
+		blocks[i] = &mock.DataBlock{Data: make([]byte, 32)}
+	}
+	return blocks
+}
+
+func TestMerkleTree_ProofByIndex(t *testing.T) {
+	blocks := duplicateDataBlocks(10)
+	for _, mode := range []TypeConfigMode{ModeProofGen, ModeTreeBuild, ModeProofGenAndTreeBuild} {
+		m, err := New(&Config{Mode: mode}, blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		for i, block := range blocks {
+			proof, err := m.ProofByIndex(i)
+			if err != nil {
+				t.Fatalf("ProofByIndex() error = %v", err)
+			}
+			ok, err := m.VerifyByIndex(block, i, proof)
+			if err != nil || !ok {
+				t.Fatalf("mode %d: VerifyByIndex(%d) = %v, %v", mode, i, ok, err)
+			}
+			if i%3 == 0 && i > 0 {
+				// The proof of another occurrence of the same block is valid, but not at this index.
+				if ok, err = m.VerifyByIndex(block, 0, proof); err != nil || ok {
+					t.Errorf("VerifyByIndex() at another index = %v, %v", ok, err)
+				}
+				if ok, err = m.Verify(block, proof); err != nil || !ok {
+					t.Errorf("Verify() = %v, %v", ok, err)
+				}
+			}
+		}
+		if _, err = m.ProofByIndex(len(blocks)); !errors.Is(err, ErrLeafIndexOutOfRange) {
+			t.Errorf("ProofByIndex() error = %v, want %v", err, ErrLeafIndexOutOfRange)
+		}
+		// The returned proof is a copy, so modifying it does not change the tree.
+		proof, err := m.ProofByIndex(1)
+		if err != nil {
+			t.Fatalf("ProofByIndex() error = %v", err)
+		}
+		proof.Siblings[0] = nil
+		if proof, err = m.ProofByIndex(1); err != nil || proof.Siblings[0] == nil {
+			t.Errorf("mode %d: ProofByIndex() after modifying the returned proof = %v, %v", mode, proof, err)
+		}
+	}
+}
+
+func TestLeafIndex(t *testing.T) {
+	l := newLeafIndex(0)
+	for _, idx := range []int{5, 1, 3, 1} {
+		l.add("a", idx)
+	}
+	l.add("b", 2)
+	if got := l.indices([]byte("a")); !reflect.DeepEqual(got, []int{1, 3, 5}) {
+		t.Errorf("indices() = %v, want [1 3 5]", got)
+	}
+	l.remove("a", 3)
+	l.remove("a", 4)
+	l.remove("a", 1)
+	// A leaf left with one index is moved back to the single indices.
+	if _, ok := l.multiple["a"]; ok || l.single["a"] != 5 {
+		t.Errorf("leafIndex after removing the duplicates = %v, %v", l.single, l.multiple)
+	}
+	l.remove("b", 0)
+	l.remove("b", 2)
+	if got := l.indices([]byte("b")); got != nil {
+		t.Errorf("indices() of the removed leaf = %v, want nil", got)
+	}
+	if got := l.indices([]byte("a")); !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("indices() = %v, want [5]", got)
+	}
+}
+
+func TestMerkleTree_LeafIndices(t *testing.T) {
+	blocks := duplicateDataBlocks(10)
+	for _, mode := range []TypeConfigMode{ModeProofGen, ModeTreeBuild, ModeProofGenAndTreeBuild} {
+		m, err := New(&Config{Mode: mode}, blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		got, err := m.LeafIndices(blocks[0])
+		if err != nil {
+			t.Fatalf("LeafIndices() error = %v", err)
+		}
+		if want := []int{0, 3, 6, 9}; !reflect.DeepEqual(got, want) {
+			t.Errorf("LeafIndices() = %v, want %v", got, want)
+		}
+		if got, err = m.LeafIndices(blocks[4]); err != nil || !reflect.DeepEqual(got, []int{4}) {
+			t.Errorf("LeafIndices() = %v, %v, want [4]", got, err)
+		}
+		if _, err = m.LeafIndices(&mock.DataBlock{Data: []byte("missing")}); !errors.Is(err, ErrProofInvalidDataBlock) {
+			t.Errorf("LeafIndices() error = %v, want %v", err, ErrProofInvalidDataBlock)
+		}
+		if mode == ModeProofGen {
+			continue
+		}
+		// Proof keeps returning the proof of the last occurrence.
+		proof, err := m.Proof(blocks[0])
+		if err != nil {
+			t.Fatalf("Proof() error = %v", err)
+		}
+		if ok, err := m.VerifyByIndex(blocks[0], 9, proof); err != nil || !ok {
+			t.Errorf("VerifyByIndex() = %v, %v", ok, err)
+		}
+	}
+}
+
+func TestVerifyByIndex_errors(t *testing.T) {
+	blocks := generatedTestDataBlocks(4)
+	if _, err := VerifyByIndex(blocks[0], 0, nil, nil, nil); !errors.Is(err, ErrProofIsNil) {
+		t.Errorf("VerifyByIndex() error = %v, want %v", err, ErrProofIsNil)
+	}
+	proof := &Proof{Siblings: make([][]byte, 2)}
+	if _, err := VerifyByIndex(blocks[0], 4, proof, nil, nil); !errors.Is(err, ErrLeafIndexOutOfRange) {
+		t.Errorf("VerifyByIndex() error = %v, want %v", err, ErrLeafIndexOutOfRange)
+	}
+}
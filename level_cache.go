@@ -13,12 +13,18 @@ type LevelCache struct {
 	leafMapMu sync.Mutex
 	// Nodes contains the Merkle Tree's internal node structure.
 	Nodes [][][]byte
+	// FlatNodes contains the levels in the contiguous node layout, each level being one byte slice
+	// of NodeWidth-byte nodes. It is used instead of Nodes for a tree built with ContiguousNodes.
+	FlatNodes [][]byte
+	// NodeWidth is the width of the nodes in FlatNodes, or 0 if Nodes is used.
+	NodeWidth int
 	// Start is the level of the cache Merkle Tree. leaf level is 0.
 	Start int
 	// Level is the Levels of the cache Merkle Tree.
 	Level int
 }
 
+// RFC 6962 mode is not supported, as the promoted nodes have no siblings.
 // start range:[0, depth-1]
 // level range:[1, depth]
 func NewLevelCache(m *MerkleTree, start int, level int) (*LevelCache, error) {
@@ -26,6 +32,15 @@ func NewLevelCache(m *MerkleTree, start int, level int) (*LevelCache, error) {
 	if m == nil {
 		return nil, ErrMerkleTreeIsNil
 	}
+	if m.NodeStore == nil {
+		return nil, ErrProofInvalidModeTreeNotBuilt
+	}
+	if m.Arity > 2 {
+		return nil, ErrUnsupportedArity
+	}
+	if m.RFC6962 {
+		return nil, ErrUnsupportedRFC6962
+	}
 	if m.Depth <= start || start < 0 {
 		return nil, ErrLevelCacheStart
 	}
@@ -34,26 +49,66 @@ func NewLevelCache(m *MerkleTree, start int, level int) (*LevelCache, error) {
 	}
 
 	lc := LevelCache{Start: start, Level: level}
+	if m.ContiguousNodes && m.nodeWidth > 0 {
+		lc.NodeWidth = m.nodeWidth
+		lc.FlatNodes = make([][]byte, level)
+		for i := 0; i < level; i++ {
+			nodes, err := getContiguousLevel(m.NodeStore, start+i, m.nodeWidth)
+			if err != nil {
+				return nil, err
+			}
+			lc.FlatNodes[i] = append([]byte(nil), nodes...)
+		}
+	} else {
+		lc.Nodes = make([][][]byte, level)
+		for i := 0; i < level; i++ {
+			nodes, err := getLevel(m.NodeStore, start+i)
+			if err != nil {
+				return nil, err
+			}
+			lc.Nodes[i] = append(lc.Nodes[i], nodes...)
+		}
+	}
 
-	finishMap := make(chan struct{})
-	lc.LeafMap = make(map[string]int)
-	go func() {
-		lc.leafMapMu.Lock()
-		defer lc.leafMapMu.Unlock()
-		for i := 0; i < len(m.nodes[start]); i++ {
-			lc.LeafMap[string(m.nodes[start][i])] = i
+	lc.LeafMap = make(map[string]int, lc.levelLen(0))
+	var keys string
+	if lc.NodeWidth > 0 {
+		// The keys are substrings of one string instead of one string per node.
+		keys = string(lc.FlatNodes[0])
+	}
+	for i := 0; i < lc.levelLen(0); i++ {
+		if lc.NodeWidth > 0 {
+			lc.LeafMap[keys[i*lc.NodeWidth:(i+1)*lc.NodeWidth]] = i
+		} else {
+			lc.LeafMap[string(lc.Nodes[0][i])] = i
 		}
-		finishMap <- struct{}{} // empty channel to serve as a wait group for map generation
-	}()
+	}
+	return &lc, nil
+}
 
-	lc.Nodes = make([][][]byte, level)
+// numLevels returns the number of the cached levels.
+func (lc *LevelCache) numLevels() int {
+	if lc.NodeWidth > 0 {
+		return len(lc.FlatNodes)
+	}
+	return len(lc.Nodes)
+}
 
-	for i := 0; i < level; i++ {
-		lc.Nodes[i] = append(lc.Nodes[i], m.nodes[start+i]...)
+// levelLen returns the number of nodes at the cached level i.
+func (lc *LevelCache) levelLen(i int) int {
+	if lc.NodeWidth > 0 {
+		return len(lc.FlatNodes[i]) / lc.NodeWidth
 	}
+	return len(lc.Nodes[i])
+}
 
-	<-finishMap
-	return &lc, nil
+// node returns the node at the index of the cached level i.
+func (lc *LevelCache) node(i, idx int) []byte {
+	if lc.NodeWidth > 0 {
+		w := lc.NodeWidth
+		return lc.FlatNodes[i][idx*w : (idx+1)*w : (idx+1)*w]
+	}
+	return lc.Nodes[i][idx]
 }
 
 func NewLevelCacheFromFile(filePath string) (*LevelCache, error) {
@@ -95,6 +150,8 @@ func (lc *LevelCache) StoreToFile(filePath string) error {
 }
 
 func (lc *LevelCache) Prove(dataBlock DataBlock, config *Config) (*Proof, []byte, error) {
+	// Work on a copy so that the configuration shared by other goroutines is not modified.
+	config = copyConfig(config)
 
 	leaf, err := dataBlockToLeaf(dataBlock, config)
 	if err != nil {
@@ -112,31 +169,21 @@ func (lc *LevelCache) Prove(dataBlock DataBlock, config *Config) (*Proof, []byte
 	// Compute the path and siblings for the proof.
 	var (
 		path     uint32
-		siblings = make([][]byte, lc.Level)
+		siblings = make([][]byte, 0, lc.Level)
 	)
 	for i := 0; i < lc.Level; i++ {
 		if idx&1 == 1 {
-			siblings[i] = lc.Nodes[i][idx-1]
+			siblings = append(siblings, lc.node(i, idx-1))
 		} else {
 			// Absolute path
-			path += 1 << (i + lc.Start)
-			siblings[i] = lc.Nodes[i][idx+1]
+			path += 1 << (len(siblings) + lc.Start)
+			siblings = append(siblings, lc.node(i, idx+1))
 		}
 		idx >>= 1
 	}
 
-	if config == nil {
-		config = new(Config)
-	}
-	if config.HashFunc == nil {
-		config.HashFunc = DefaultHashFunc
-	}
-
 	// Determine the concatenation function based on the configuration.
-	concatFunc := concatHash
-	if config.SortSiblingPairs {
-		concatFunc = concatSortHash
-	}
+	concatFunc := concatFuncOf(config)
 	// Traverse the Merkle proof and compute the root hash.
 	// Copy the slice so that the original leaf won't be modified.
 	root := make([]byte, len(leaf))
@@ -71,7 +71,7 @@ func TestAppendProof(t *testing.T) {
 	}
 
 	block := &mock.DataBlock{
-		Data: m.nodes[0][5],
+		Data: m.Leaves[5],
 	}
 	proof, err := m.Proof(block)
 	if err != nil {
@@ -43,8 +43,6 @@ const (
 	MaxDepth = uint(31) // result of log2( 64 GiB / 32 )
 )
 
-var stackedNulPadding [MaxDepth][]byte
-
 var (
 	// ErrInvalidNumOfDataBlocks is the error for an invalid number of data blocks.
 	ErrInvalidNumOfDataBlocks = errors.New("the number of data blocks must be greater than 1")
@@ -65,6 +63,76 @@ var (
 	ErrLevelCacheStart = errors.New("LevelCache start over depth or invalid")
 	// ErrLevelCacheLevel is the error LevelCache level over depth
 	ErrLevelCacheLevel = errors.New("LevelCache level over depth or invalid")
+	// ErrInvalidPieceSize is the error for a piece size that is zero or exceeds the maximum piece size.
+	ErrInvalidPieceSize = errors.New("piece size is zero or exceeds the maximum piece size")
+	// ErrTooManyDataBlocks is the error for a number of data blocks exceeding 2^MaxDepth.
+	ErrTooManyDataBlocks = errors.New("the number of data blocks exceeds the maximum")
+	// ErrInvalidBlockSize is the error for a data block size that is not positive.
+	ErrInvalidBlockSize = errors.New("data block size must be positive")
+	// ErrNodeNotFound is the error for a node that is not in the node store.
+	ErrNodeNotFound = errors.New("node is not found in the node store")
+	// ErrInvalidNodeSize is the error for a node size that does not match the node store.
+	ErrInvalidNodeSize = errors.New("invalid node size for the node store")
+	// ErrNodeStoreReadOnly is the error for writing to a read-only node store.
+	ErrNodeStoreReadOnly = errors.New("node store is read-only")
+	// ErrNodeStoreIsNil is the error for a nil node store.
+	ErrNodeStoreIsNil = errors.New("node store is nil")
+	// ErrInvalidLeafSize is the error for a leaf whose size differs from the hash size in the contiguous node layout.
+	ErrInvalidLeafSize = errors.New("leaf size does not match the hash size")
+	// ErrInvalidSubtreeDepth is the error for a subtree depth out of range.
+	ErrInvalidSubtreeDepth = errors.New("subtree depth is zero or exceeds the maximum depth")
+	// ErrSubtreeDepthMismatch is the error for subtrees of different depths.
+	ErrSubtreeDepthMismatch = errors.New("subtrees must have the same depth")
+	// ErrSubtreeIndexOutOfRange is the error for a subtree index out of range.
+	ErrSubtreeIndexOutOfRange = errors.New("subtree index is out of range")
+	// ErrPieceSizeNotPowerOfTwo is the error for a padded piece size that is not a power of two.
+	ErrPieceSizeNotPowerOfTwo = errors.New("padded piece size must be a power of two of at least 128 bytes")
+	// ErrAggregateTooLarge is the error for pieces not fitting into the aggregate size.
+	ErrAggregateTooLarge = errors.New("pieces do not fit into the aggregate size")
+	// ErrPieceIndexOutOfRange is the error for a piece index out of range.
+	ErrPieceIndexOutOfRange = errors.New("piece index is out of range")
+	// ErrInvalidNodeLevel is the error for a node level out of range.
+	ErrInvalidNodeLevel = errors.New("node level is out of range")
+	// ErrLeafIndexOutOfRange is the error for a leaf index out of range.
+	ErrLeafIndexOutOfRange = errors.New("leaf index is out of range")
+	// ErrMultiProofUnsortedPairs is the error for multiproofs of a tree without sorted sibling pairs.
+	ErrMultiProofUnsortedPairs = errors.New("multiproofs require SortSiblingPairs to be true")
+	// ErrMultiProofNoLeaves is the error for a multiproof without leaves.
+	ErrMultiProofNoLeaves = errors.New("multiproof must prove at least one leaf")
+	// ErrMultiProofDuplicateLeaf is the error for a multiproof proving a leaf more than once.
+	ErrMultiProofDuplicateLeaf = errors.New("multiproof must prove each leaf at most once")
+	// ErrInvalidRFC6962Config is the error for the options which cannot be used in RFC 6962 mode.
+	ErrInvalidRFC6962Config = errors.New("RFC 6962 mode cannot be used with odd node padding, sorted sibling pairs or contiguous nodes")
+	// ErrRFC6962TreeSizeRequired is the error for verifying a leaf index without the tree size in RFC 6962 mode.
+	ErrRFC6962TreeSizeRequired = errors.New("tree size is required to verify a leaf index in RFC 6962 mode, use VerifyRFC6962Inclusion")
+	// ErrTreeNotBuilt is the error for modifying a tree which is not built, i.e. in ModeProofGen.
+	ErrTreeNotBuilt = errors.New("merkle tree is not built, the operation requires ModeTreeBuild or ModeProofGenAndTreeBuild")
+	// ErrUpdateMismatch is the error for different numbers of indices and data blocks to update.
+	ErrUpdateMismatch = errors.New("the numbers of indices and data blocks to update do not match")
+	// ErrSparseKeyNotFound is the error for a key which is not in the sparse Merkle tree.
+	ErrSparseKeyNotFound = errors.New("key is not in the sparse merkle tree")
+	// ErrSparseValueIsNil is the error for a nil value in the sparse Merkle tree.
+	ErrSparseValueIsNil = errors.New("sparse merkle tree value is nil")
+	// ErrInvalidSparseProof is the error for a sparse Merkle tree proof with a wrong number of siblings.
+	ErrInvalidSparseProof = errors.New("sparse merkle tree proof has a wrong number of siblings")
+	// ErrMMRIsEmpty is the error for the root of an MMR without leaves.
+	ErrMMRIsEmpty = errors.New("MMR has no leaves")
+	// ErrInvalidMMRState is the error for an MMR state whose number of peaks does not match the number of leaves.
+	ErrInvalidMMRState = errors.New("MMR state is invalid")
+	// ErrInvalidMMRProof is the error for an MMR proof with a wrong number of siblings or peaks.
+	ErrInvalidMMRProof = errors.New("MMR proof has a wrong number of siblings or peaks")
+	// ErrInvalidArity is the error for an arity less than 2 or used with an option only supporting binary trees.
+	ErrInvalidArity = errors.New("arity must be 0 or at least 2, and a higher arity cannot be used with RFC6962, SortSiblingPairs or ContiguousNodes")
+	// ErrInvalidArityProof is the error for a proof whose siblings or positions do not match the arity.
+	ErrInvalidArityProof = errors.New("proof does not match the arity of the tree")
+	// ErrUnsupportedArity is the error for an operation only supporting binary trees.
+	ErrUnsupportedArity = errors.New("the operation only supports binary trees")
+	// ErrUnsupportedRFC6962 is the error for an operation which is not available in RFC 6962 mode.
+	ErrUnsupportedRFC6962 = errors.New("the operation is not available in RFC 6962 mode")
+	// ErrNotRFC6962 is the error for an operation only available in RFC 6962 mode.
+	ErrNotRFC6962 = errors.New("the operation is only available in RFC 6962 mode")
+	// ErrInvalidTreeSize is the error for a tree size out of range.
+	ErrInvalidTreeSize = errors.New("tree size is out of range")
 )
 
 // DataBlock is the interface for input data blocks used to generate the Merkle Tree.
@@ -76,14 +144,24 @@ type DataBlock interface {
 	Serialize() ([]byte, error)
 }
 
+// BytesBlock is a DataBlock backed by a byte slice, which is returned as-is by Serialize.
+type BytesBlock []byte
+
+// Serialize returns the underlying byte slice of the BytesBlock.
+func (b BytesBlock) Serialize() ([]byte, error) {
+	return b, nil
+}
+
 // workerArgs is used as the arguments for the worker functions when performing parallel computations.
 // Each worker function has its own dedicated argument struct embedded within workerArgs,
 // which eliminates the need for interface conversion overhead and provides clear separation of concerns.
 type workerArgs struct {
-	generateProofs   *workerArgsGenerateProofs
-	updateProofs     *workerArgsUpdateProofs
-	generateLeaves   *workerArgsGenerateLeaves
-	computeTreeNodes *workerArgsComputeTreeNodes
+	generateProofs    *workerArgsGenerateProofs
+	updateProofs      *workerArgsUpdateProofs
+	generateLeaves    *workerArgsGenerateLeaves
+	computeTreeNodes  *workerArgsComputeTreeNodes
+	hashContiguous    *workerArgsHashContiguous
+	computeGroupNodes *workerArgsComputeGroupNodes
 }
 
 // TypeConfigMode is the type in the Merkle Tree configuration indicating what operations are performed.
@@ -98,6 +176,11 @@ type typeConcatHashFunc func([]byte, []byte) []byte
 type Config struct {
 	// Customizable hash function used for tree generation.
 	HashFunc TypeHashFunc
+	// HashName is the name of a registered hash function, e.g. "blake3", used if HashFunc is nil.
+	// Unlike HashFunc, it can be serialized with the proofs. The registered hash functions are safe
+	// for concurrent use. An unregistered name fails with ErrUnknownHashName, and a HashFunc which is not
+	// the hash function of the name fails with ErrHashNameMismatch.
+	HashName string
 	// Number of goroutines run in parallel.
 	// If RunInParallel is true and NumRoutine is set to 0, use number of CPU as the number of goroutines.
 	NumRoutines int
@@ -109,19 +192,45 @@ type Config struct {
 	// If false, generate a dummy node with specified hash value.
 	// Otherwise, then the odd node situation is handled by duplicating the previous node.
 	Duplicates bool
+	// Padding is the table of dummy nodes used when Duplicates is false.
+	// Padding[i] is appended to the tree level i if the level has an odd number of nodes.
+	// If the table is empty, the previous node is duplicated.
+	Padding [MaxDepth][]byte
+	// If true and Duplicates is false, an empty Padding table is derived from HashFunc by ZeroPaddingTable,
+	// so that the dummy nodes are the roots of all-zero subtrees.
+	// It is opt-in: with the zero value of Config, Duplicates false alone still duplicates the odd nodes,
+	// as deriving the padding by default would change the roots of the existing trees. NewCommPConfig sets it.
+	ZeroPadding bool
 	// SortSiblingPairs is the parameter for OpenZeppelin compatibility.
 	// If set to `true`, the hashing sibling pairs are sorted.
 	SortSiblingPairs bool
 	// If true, the leaf nodes are NOT hashed before being added to the Merkle Tree.
 	DisableLeafHashing bool
+	// If true, each tree level is kept in one contiguous byte slice of fixed-width nodes instead of
+	// a separate byte slice per node, which reduces the allocations and the GC pressure for large trees.
+	// The node width is the output size of HashFunc, and all the leaves must have this size.
+	// The proofs of all the leaves share their allocations, and LevelCache keeps its levels in the same layout.
+	// The leaves and the proof siblings are views into the levels, which Update overwrites in place,
+	// so the nodes of a proof must be copied to keep them after an update.
+	ContiguousNodes bool
+	// If true, the tree follows RFC 6962 (Certificate Transparency): the leaves are hashed with the 0x00 prefix,
+	// the internal nodes with the 0x01 prefix, and the odd node of a level is promoted to the next level
+	// without padding. The proofs only contain the existing siblings, with one path bit per sibling.
+	// It cannot be used with Duplicates, Padding, ZeroPadding, SortSiblingPairs or ContiguousNodes.
+	RFC6962 bool
+	// Arity is the number of children of each internal node. If it is 0 or 2, the tree is binary.
+	// In a tree of a higher arity, each level is padded to a multiple of the arity, and the proofs have
+	// the other nodes of each sibling group and the position of the node in the group per level.
+	// It cannot be used with RFC6962, SortSiblingPairs or ContiguousNodes.
+	Arity int
 }
 
 // MerkleTree implements the Merkle Tree data structure.
 type MerkleTree struct {
 	Config
-	// leafMap maps the data (converted to string) of each leaf node to its index in the Tree slice.
-	// It is only available when the configuration mode is set to ModeTreeBuild or ModeProofGenAndTreeBuild.
-	leafMap map[string]int
+	// leafMap maps the data (converted to string) of each leaf node to all its indices in the Tree slice.
+	// It is built with the tree in ModeTreeBuild and ModeProofGenAndTreeBuild, or on the first lookup otherwise.
+	leafMap *leafIndex
 	// leafMapMu is a mutex that protects concurrent access to the leafMap.
 	leafMapMu sync.Mutex
 	// wp is the worker pool used for parallel computation in the tree building process.
@@ -131,9 +240,11 @@ type MerkleTree struct {
 	// supporting the OpenZeppelin Merkle Tree protocol.
 	// Otherwise, the sibling pairs are concatenated directly.
 	concatHashFunc typeConcatHashFunc
-	// nodes contains the Merkle Tree's internal node structure.
-	// It is only available when the configuration mode is set to ModeTreeBuild or ModeProofGenAndTreeBuild.
-	nodes [][][]byte
+	// nodeWidth is the output size of the hash function, which is only set if ContiguousNodes in Config is true.
+	nodeWidth int
+	// leafData is the contiguous byte slice of the leaves, which is only set if ContiguousNodes in Config is true.
+	// The Leaves are views into it.
+	leafData []byte
 	// Root is the hash of the Merkle root node.
 	Root []byte
 	// Leaves are the hashes of the data blocks that form the Merkle Tree's leaves.
@@ -142,21 +253,43 @@ type MerkleTree struct {
 	Leaves [][]byte
 	// Proofs are the proofs to the data blocks generated during the tree building process.
 	Proofs []*Proof
+	// NodeStore is the storage of the tree nodes in ModeTreeBuild and ModeProofGenAndTreeBuild.
+	// New keeps the nodes in a MemoryNodeStore, or a FlatNodeStore if ContiguousNodes is true.
+	// The trees created by NewWithNodeStore, NewFromNodeStore and StreamBuilder.Tree use the given node store.
+	NodeStore NodeStore
 	// Depth is the depth of the Merkle Tree.
 	Depth int
 	// NumLeaves is the number of leaves in the Merkle Tree.
-	// This value is fixed once the tree is built.
+	// This value is fixed once the tree is built, unless the leaves are added by Append.
 	NumLeaves int
 }
 
 // Proof represents a Merkle Tree proof.
 type Proof struct {
-	Siblings [][]byte // Sibling nodes to the Merkle Tree path of the data block.
-	Path     uint32   // Path variable indicating whether the neighbor is on the left or right.
+	Siblings  [][]byte // Sibling nodes to the Merkle Tree path of the data block.
+	Path      uint32   // Path variable indicating whether the neighbor is on the left or right.
+	Positions []int    // Positions of the node in its sibling group per level, only used by trees of a higher arity.
 }
 
 // New generates a new Merkle Tree with the specified configuration and data blocks.
-func New(config *Config, blocks []DataBlock) (m *MerkleTree, err error) {
+func New(config *Config, blocks []DataBlock) (*MerkleTree, error) {
+	return newTree(config, blocks, nil)
+}
+
+// NewWithNodeStore generates a new Merkle Tree like New, but stores the nodes in the node store,
+// e.g. a FileNodeStore for a tree which does not fit in memory. The leaves are kept in level 0 of the store,
+// so the Leaves of the returned tree are nil. Each tree needs its own node store.
+// ModeProofGen, the default mode, is replaced by ModeTreeBuild, as it does not store the nodes.
+// To build a tree from data blocks which do not fit in memory either, use NewStreamBuilderWithNodeStore.
+func NewWithNodeStore(config *Config, blocks []DataBlock, store NodeStore) (*MerkleTree, error) {
+	if store == nil {
+		return nil, ErrNodeStoreIsNil
+	}
+	return newTree(config, blocks, store)
+}
+
+// newTree generates a new Merkle Tree. If the node store is nil, the default node store is used.
+func newTree(config *Config, blocks []DataBlock, store NodeStore) (m *MerkleTree, err error) {
 	// Check if there are enough data blocks to build the tree.
 	if len(blocks) <= 1 {
 		return nil, ErrInvalidNumOfDataBlocks
@@ -174,23 +307,8 @@ func New(config *Config, blocks []DataBlock) (m *MerkleTree, err error) {
 		Depth:     bits.Len(uint(len(blocks) - 1)),
 	}
 
-	// Initialize the hash function.
-	if m.HashFunc == nil {
-		if m.RunInParallel {
-			// Use a concurrent safe hash function for parallel execution.
-			m.HashFunc = DefaultHashFuncParallel
-		} else {
-			m.HashFunc = DefaultHashFunc
-		}
-	}
-
-	// Hash concatenation function initialization.
-	if m.concatHashFunc == nil {
-		if m.SortSiblingPairs {
-			m.concatHashFunc = concatSortHash
-		} else {
-			m.concatHashFunc = concatHash
-		}
+	if err = m.initConfig(); err != nil {
+		return nil, err
 	}
 
 	// Configure parallelization settings.
@@ -213,56 +331,196 @@ func New(config *Config, blocks []DataBlock) (m *MerkleTree, err error) {
 		}
 	}
 
+	// Pack the leaves into one contiguous byte slice for the contiguous node layout.
+	if m.ContiguousNodes {
+		if err = m.initContiguous(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Perform actions based on the configured mode.
-	// Set the mode to ModeProofGen by default if not specified.
+	// Set the mode to ModeProofGen by default if not specified, or ModeTreeBuild if the node store is given.
 	if m.Mode == 0 {
 		m.Mode = ModeProofGen
 	}
+	if m.Mode == ModeProofGen && store != nil {
+		m.Mode = ModeTreeBuild
+	}
 
 	// Generate proofs in ModeProofGen.
 	if m.Mode == ModeProofGen {
 		err = m.generateProofs()
 		return
 	}
-	// Initialize the leafMap for ModeTreeBuild and ModeProofGenAndTreeBuild.
-	m.leafMap = make(map[string]int)
+	if m.Mode != ModeTreeBuild && m.Mode != ModeProofGenAndTreeBuild {
+		// Return an error if the configuration mode is invalid.
+		return nil, ErrInvalidConfigMode
+	}
 
-	// Build the tree in ModeTreeBuild.
-	if m.Mode == ModeTreeBuild {
-		err = m.buildTree()
-		return
+	// Initialize the leafMap and the node store for ModeTreeBuild and ModeProofGenAndTreeBuild.
+	// With a given node store, the leafMap is built from the store on the first lookup instead.
+	m.NodeStore = store
+	if m.NodeStore == nil {
+		m.leafMap = newLeafIndex(m.NumLeaves)
+		if m.ContiguousNodes {
+			m.NodeStore = NewFlatNodeStore(m.nodeWidth)
+		} else {
+			m.NodeStore = NewMemoryNodeStore()
+		}
 	}
 
-	// Build the tree and generate proofs in ModeProofGenAndTreeBuild.
+	// In ModeProofGenAndTreeBuild, the proofs are updated with each tree level during the tree building.
 	if m.Mode == ModeProofGenAndTreeBuild {
-		if err = m.buildTree(); err != nil {
-			return
-		}
 		m.initProofs()
-		if m.RunInParallel {
-			for i := 0; i < len(m.nodes); i++ {
-				m.updateProofsInParallel(m.nodes[i], len(m.nodes[i]), i)
-			}
-			return
+	}
+	if err = m.buildTree(); err != nil {
+		return nil, err
+	}
+	if store != nil {
+		// The leaves are in level 0 of the node store.
+		m.Leaves, m.leafData = nil, nil
+	}
+	return m, nil
+}
+
+// NewFromNodeStore restores a Merkle Tree from a node store filled by a previous tree building with numLeaves
+// data blocks, e.g. a FileNodeStore opened by OpenFileNodeStore, so that proofs can be generated without
+// rebuilding the tree. The configuration must be the same as the one used to build the tree.
+// The restored tree is in ModeTreeBuild, and its Leaves are not loaded into memory.
+func NewFromNodeStore(config *Config, store NodeStore, numLeaves int) (m *MerkleTree, err error) {
+	if store == nil {
+		return nil, ErrNodeStoreIsNil
+	}
+	if numLeaves <= 1 {
+		return nil, ErrInvalidNumOfDataBlocks
+	}
+	if config == nil {
+		config = new(Config)
+	}
+	m = &MerkleTree{
+		Config:    *config,
+		NumLeaves: numLeaves,
+		Depth:     bits.Len(uint(numLeaves - 1)),
+	}
+	m.Mode = ModeTreeBuild
+	m.NodeStore = store
+	if err = m.initConfig(); err != nil {
+		return nil, err
+	}
+	if store.Len(0) < numLeaves || store.Len(m.Depth-1) != arityOf(&m.Config) {
+		return nil, ErrNodeNotFound
+	}
+	if m.Arity > 2 {
+		top, err := getLevel(store, m.Depth-1)
+		if err != nil {
+			return nil, err
 		}
-		for i := 0; i < len(m.nodes); i++ {
-			m.updateProofs(m.nodes[i], len(m.nodes[i]), i)
+		if m.Root, err = m.HashFunc(concatGroup(top)); err != nil {
+			return nil, err
 		}
-		return
+		return m, nil
 	}
 
-	// Return an error if the configuration mode is invalid.
-	return nil, ErrInvalidConfigMode
+	// Compute the root from the two nodes of the top level.
+	left, err := store.Get(m.Depth-1, 0)
+	if err != nil {
+		return nil, err
+	}
+	right, err := store.Get(m.Depth-1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if m.Root, err = m.HashFunc(m.concatHashFunc(left, right)); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-// New generates a new Merkle Tree with the specified configuration and data blocks.
-func NewWithPadding(config *Config, blocks []DataBlock, Padding [MaxDepth][]byte) (m *MerkleTree, err error) {
+// initConfig checks the configuration of the tree with NumLeaves leaves, and derives the hash functions,
+// the depth of a tree of a higher arity and the zero padding table from it.
+func (m *MerkleTree) initConfig() (err error) {
+	if err = checkHashName(&m.Config); err != nil {
+		return err
+	}
+	m.initHashFuncs()
+	if m.RFC6962 {
+		if err = checkRFC6962Config(&m.Config); err != nil {
+			return err
+		}
+	}
+	if err = checkArityConfig(&m.Config); err != nil {
+		return err
+	}
+	if m.Arity > 2 {
+		m.Depth = aryDepth(m.NumLeaves, m.Arity)
+	}
+
+	// Derive the padding of odd nodes from the hash function if no padding table is provided.
+	if !m.Duplicates && m.ZeroPadding && m.Padding[0] == nil {
+		if m.Padding, err = ZeroPaddingTable(&m.Config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initHashFuncs initializes the hash function and the hash concatenation function.
+func (m *MerkleTree) initHashFuncs() {
+	if m.HashFunc == nil && m.HashName != "" {
+		m.HashFunc = namedHashFunc(m.HashName)
+	}
+	if m.HashFunc == nil {
+		if m.RunInParallel {
+			// Use a concurrent safe hash function for parallel execution.
+			m.HashFunc = DefaultHashFuncParallel
+		} else {
+			m.HashFunc = DefaultHashFunc
+		}
+	}
+	if m.concatHashFunc == nil {
+		m.concatHashFunc = concatFuncOf(&m.Config)
+	}
+}
 
-	if config != nil && !config.Duplicates {
-		// Init padding value
-		stackedNulPadding = Padding
+// NewWithPadding generates a new Merkle Tree with the specified configuration, data blocks and padding table.
+// It is equivalent to New with the Padding in the configuration set to the padding table.
+// The provided configuration is not modified.
+func NewWithPadding(config *Config, blocks []DataBlock, padding [MaxDepth][]byte) (m *MerkleTree, err error) {
+	var paddedConfig Config
+	if config != nil {
+		paddedConfig = *config
+	}
+	paddedConfig.Padding = padding
+	return New(&paddedConfig, blocks)
+}
+
+// copyConfig returns a copy of the configuration with the named or the default hash function set
+// if it is not specified. If the hash function does not match the name, the hash function of the copy
+// fails with ErrHashNameMismatch.
+func copyConfig(config *Config) *Config {
+	copied := new(Config)
+	if config != nil {
+		*copied = *config
+	}
+	if copied.HashFunc == nil && copied.HashName != "" {
+		copied.HashFunc = namedHashFunc(copied.HashName)
+	} else if err := checkHashName(copied); err != nil {
+		copied.HashFunc = failingHashFunc(err)
+	}
+	if copied.HashFunc == nil {
+		copied.HashFunc = DefaultHashFunc
+	}
+	return copied
+}
+
+// hashSize returns the output size of the hash function. It hashes a zero block of 32 bytes instead of
+// the empty input, which is rejected by the hash functions of field elements such as PoseidonHashFunc.
+func hashSize(hashFunc TypeHashFunc) (int, error) {
+	hash, err := hashFunc(make([]byte, 32))
+	if err != nil {
+		return 0, err
 	}
-	return New(config, blocks)
+	return len(hash), nil
 }
 
 // concatSortHash concatenates two byte slices, b1 and b2, in a sorted order.
@@ -284,9 +542,30 @@ func concatSortHash(b1 []byte, b2 []byte) []byte {
 	return concatHash(b2, b1)
 }
 
+// concatFuncOf returns the function for concatenating two hashes of the configuration.
+func concatFuncOf(config *Config) typeConcatHashFunc {
+	switch {
+	case config.RFC6962:
+		return concatRFC6962Node
+	case config.SortSiblingPairs:
+		return concatSortHash
+	}
+	return concatHash
+}
+
 // initProofs initializes the MerkleTree's Proofs with the appropriate size and depth.
+// With the contiguous node layout, the proofs and their siblings are allocated in two slices for all the leaves.
 func (m *MerkleTree) initProofs() {
 	m.Proofs = make([]*Proof, m.NumLeaves)
+	if m.ContiguousNodes {
+		proofs := make([]Proof, m.NumLeaves)
+		siblings := make([][]byte, m.NumLeaves*m.Depth)
+		for i := range proofs {
+			proofs[i].Siblings = siblings[i*m.Depth : i*m.Depth : (i+1)*m.Depth]
+			m.Proofs[i] = &proofs[i]
+		}
+		return
+	}
 	for i := 0; i < m.NumLeaves; i++ {
 		m.Proofs[i] = new(Proof)
 		m.Proofs[i].Siblings = make([][]byte, 0, m.Depth)
@@ -296,6 +575,15 @@ func (m *MerkleTree) initProofs() {
 // generateProofs constructs the Merkle Tree and generates the Merkle proofs for each leaf.
 // It returns an error if there is an issue during the generation process.
 func (m *MerkleTree) generateProofs() error {
+	if m.RFC6962 {
+		return m.generateProofsRFC6962()
+	}
+	if m.Arity > 2 {
+		return m.generateProofsNary()
+	}
+	if m.ContiguousNodes {
+		return m.generateProofsContiguous()
+	}
 	m.initProofs()
 	buffer := make([][]byte, m.NumLeaves)
 	copy(buffer, m.Leaves)
@@ -417,11 +705,11 @@ func (m *MerkleTree) fixOddLength(buffer [][]byte, bufferLength int, depth int)
 	}
 
 	var appendNode []byte
-	if m.Duplicates || stackedNulPadding[0] == nil {
+	if m.Duplicates || m.Padding[0] == nil {
 		// Determine the node to append.
 		appendNode = buffer[bufferLength-1]
 	} else {
-		appendNode = stackedNulPadding[depth]
+		appendNode = m.Padding[depth]
 	}
 
 	bufferLength++
@@ -522,10 +810,10 @@ func min(a, b int) int {
 // generateLeaves generates the leaves slice from the data blocks.
 func (m *MerkleTree) generateLeaves(blocks []DataBlock) ([][]byte, error) {
 	var (
-		leaves = make([][]byte, m.NumLeaves)
+		leaves = make([][]byte, len(blocks))
 		err    error
 	)
-	for i := 0; i < m.NumLeaves; i++ {
+	for i := range blocks {
 		if leaves[i], err = dataBlockToLeaf(blocks[i], &m.Config); err != nil {
 			return nil, err
 		}
@@ -546,6 +834,9 @@ func dataBlockToLeaf(block DataBlock, config *Config) ([]byte, error) {
 		copy(leaf, blockBytes)
 		return leaf, nil
 	}
+	if config.RFC6962 {
+		return config.HashFunc(rfc6962Leaf(blockBytes))
+	}
 	return config.HashFunc(blockBytes)
 }
 
@@ -611,105 +902,154 @@ func (m *MerkleTree) generateLeavesInParallel(blocks []DataBlock) ([][]byte, err
 	return leaves, nil
 }
 
-// buildTree builds the Merkle Tree.
+// buildTree builds the Merkle Tree and stores the nodes of each level in the node store.
+// If the proofs are initialized, i.e. in ModeProofGenAndTreeBuild, they are updated with each level as well.
+// If the leafMap is initialized, it is filled concurrently with the tree building.
 func (m *MerkleTree) buildTree() (err error) {
-	finishMap := make(chan struct{})
-	go func() {
-		m.leafMapMu.Lock()
-		defer m.leafMapMu.Unlock()
-		for i := 0; i < m.NumLeaves; i++ {
-			m.leafMap[string(m.Leaves[i])] = i
-		}
-		finishMap <- struct{}{} // empty channel to serve as a wait group for map generation
-	}()
-	m.nodes = make([][][]byte, m.Depth)
-	m.nodes[0] = make([][]byte, m.NumLeaves)
-	copy(m.nodes[0], m.Leaves)
-	var bufferLength int
-	m.nodes[0], bufferLength = m.fixOddLength(m.nodes[0], m.NumLeaves, 0)
-	if m.RunInParallel {
-		if err := m.computeTreeNodesInParallel(bufferLength); err != nil {
-			return err
-		}
-	}
-	for i := 0; i < m.Depth-1; i++ {
-		m.nodes[i+1] = make([][]byte, bufferLength>>1)
-		for j := 0; j < bufferLength; j += 2 {
-			if m.nodes[i+1][j>>1], err = m.HashFunc(
-				m.concatHashFunc(m.nodes[i][j], m.nodes[i][j+1]),
-			); err != nil {
-				return
+	// The channel is buffered so that the goroutine does not leak if the tree building fails.
+	finishMap := make(chan struct{}, 1)
+	if m.leafMap == nil {
+		finishMap <- struct{}{}
+	} else {
+		go func() {
+			m.leafMapMu.Lock()
+			defer m.leafMapMu.Unlock()
+			var keys string
+			if m.ContiguousNodes {
+				// The keys are substrings of one string instead of one string per leaf.
+				keys = string(m.contiguousLeaves())
 			}
-		}
-		m.nodes[i+1], bufferLength = m.fixOddLength(m.nodes[i+1], len(m.nodes[i+1]), i+1)
+			for i := 0; i < m.NumLeaves; i++ {
+				var key string
+				if m.ContiguousNodes {
+					key = keys[i*m.nodeWidth : (i+1)*m.nodeWidth]
+				} else {
+					key = string(m.Leaves[i])
+				}
+				m.leafMap.add(key, i)
+			}
+			finishMap <- struct{}{} // empty channel to serve as a wait group for map generation
+		}()
+	}
+	switch {
+	case m.RFC6962:
+		err = m.buildLevelsRFC6962(m.NodeStore)
+	case m.Arity > 2:
+		err = m.buildLevelsNary(m.NodeStore)
+	case m.ContiguousNodes:
+		err = m.buildLevelsContiguous()
+	default:
+		err = m.buildLevels()
 	}
-	if m.Root, err = m.HashFunc(m.concatHashFunc(
-		m.nodes[m.Depth-1][0], m.nodes[m.Depth-1][1],
-	)); err != nil {
+	if err != nil {
 		return
 	}
 	<-finishMap
 	return
 }
 
+// buildLevels computes the nodes of each level and stores them in the node store.
+func (m *MerkleTree) buildLevels() (err error) {
+	buffer := make([][]byte, m.NumLeaves)
+	copy(buffer, m.Leaves)
+	bufferLength := m.NumLeaves
+	for i := 0; i < m.Depth; i++ {
+		buffer, bufferLength = m.fixOddLength(buffer, bufferLength, i)
+		if err = putLevel(m.NodeStore, i, buffer[:bufferLength]); err != nil {
+			return
+		}
+		if m.Proofs != nil {
+			if m.RunInParallel {
+				m.updateProofsInParallel(buffer, bufferLength, i)
+			} else {
+				m.updateProofs(buffer, bufferLength, i)
+			}
+		}
+		if i == m.Depth-1 {
+			break
+		}
+		if buffer, err = m.computeTreeNodes(buffer, bufferLength); err != nil {
+			return
+		}
+		bufferLength = len(buffer)
+	}
+	m.Root, err = m.HashFunc(m.concatHashFunc(buffer[0], buffer[1]))
+	return
+}
+
+// computeTreeNodes computes the nodes of the next tree level from the nodes in the buffer.
+func (m *MerkleTree) computeTreeNodes(buffer [][]byte, bufferLength int) ([][]byte, error) {
+	nodes := make([][]byte, bufferLength>>1)
+	if m.RunInParallel {
+		return nodes, m.computeTreeNodesInParallel(buffer, nodes, bufferLength)
+	}
+	var err error
+	for j := 0; j < bufferLength; j += 2 {
+		if nodes[j>>1], err = m.HashFunc(m.concatHashFunc(buffer[j], buffer[j+1])); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
 // workerArgsComputeTreeNodes contains arguments for the workerComputeTreeNodes function.
 type workerArgsComputeTreeNodes struct {
-	tree         *MerkleTree
-	startIdx     int
-	bufferLength int
-	numRoutines  int
-	depth        int
+	hashFunc       TypeHashFunc
+	concatHashFunc typeConcatHashFunc
+	buffer         [][]byte
+	nodes          [][]byte
+	startIdx       int
+	bufferLength   int
+	numRoutines    int
 }
 
 // workerBuildTree is the worker function that builds the Merkle tree in parallel.
 func workerBuildTree(args workerArgs) error {
 	chosenArgs := args.computeTreeNodes
 	var (
-		tree         = chosenArgs.tree
+		hashFunc     = chosenArgs.hashFunc
+		concatFunc   = chosenArgs.concatHashFunc
+		buffer       = chosenArgs.buffer
+		nodes        = chosenArgs.nodes
 		start        = chosenArgs.startIdx
 		bufferLength = chosenArgs.bufferLength
 		numRoutines  = chosenArgs.numRoutines
-		depth        = chosenArgs.depth
 	)
 	for i := start; i < bufferLength; i += numRoutines << 1 {
-		newHash, err := tree.HashFunc(tree.concatHashFunc(
-			tree.nodes[depth][i], tree.nodes[depth][i+1],
-		))
+		newHash, err := hashFunc(concatFunc(buffer[i], buffer[i+1]))
 		if err != nil {
 			return err
 		}
-		tree.nodes[depth+1][i>>1] = newHash
+		nodes[i>>1] = newHash
 	}
 	return nil
 }
 
-// computeTreeNodesInParallel computes the tree nodes in parallel.
-func (m *MerkleTree) computeTreeNodesInParallel(bufferLength int) error {
-	for i := 0; i < m.Depth-1; i++ {
-		m.nodes[i+1] = make([][]byte, bufferLength>>1)
-		numRoutines := m.NumRoutines
-		if numRoutines > bufferLength {
-			numRoutines = bufferLength
-		}
-		argList := make([]workerArgs, numRoutines)
-		for j := 0; j < numRoutines; j++ {
-			argList[j] = workerArgs{
-				computeTreeNodes: &workerArgsComputeTreeNodes{
-					tree:         m,
-					startIdx:     j << 1,
-					bufferLength: bufferLength,
-					numRoutines:  m.NumRoutines,
-					depth:        i,
-				},
-			}
+// computeTreeNodesInParallel computes the nodes of the next tree level in parallel.
+func (m *MerkleTree) computeTreeNodesInParallel(buffer, nodes [][]byte, bufferLength int) error {
+	numRoutines := m.NumRoutines
+	if numRoutines > bufferLength {
+		numRoutines = bufferLength
+	}
+	argList := make([]workerArgs, numRoutines)
+	for j := 0; j < numRoutines; j++ {
+		argList[j] = workerArgs{
+			computeTreeNodes: &workerArgsComputeTreeNodes{
+				hashFunc:       m.HashFunc,
+				concatHashFunc: m.concatHashFunc,
+				buffer:         buffer,
+				nodes:          nodes,
+				startIdx:       j << 1,
+				bufferLength:   bufferLength,
+				numRoutines:    numRoutines,
+			},
 		}
-		errList := m.wp.Map(workerBuildTree, argList)
-		for _, err := range errList {
-			if err != nil {
-				return err
-			}
+	}
+	errList := m.wp.Map(workerBuildTree, argList)
+	for _, err := range errList {
+		if err != nil {
+			return err
 		}
-		m.nodes[i+1], bufferLength = m.fixOddLength(m.nodes[i+1], len(m.nodes[i+1]), i+1)
 	}
 	return nil
 }
@@ -719,31 +1059,39 @@ func (m *MerkleTree) Verify(dataBlock DataBlock, proof *Proof) (bool, error) {
 	return Verify(dataBlock, proof, m.Root, &m.Config)
 }
 
-// Verify checks if the data blocks are valid using the Merkle Tree proof and the cached Merkle root hash.
-// Note: Verify Proofs must use same []DataBlock sequence！
-func (m *MerkleTree) MultiVerify(dataBlocks []DataBlock, proof *Proof) (bool, error) {
-	for _, dataBlock := range dataBlocks {
-		rst, err := Verify(dataBlock, proof, m.Root, &m.Config)
-		if err != nil || !rst {
-			return false, err
-		}
+// VerifyByIndex checks if the data block is valid at the given leaf index using the Merkle Tree proof
+// and the cached Merkle root hash.
+func (m *MerkleTree) VerifyByIndex(dataBlock DataBlock, idx int, proof *Proof) (bool, error) {
+	if m.RFC6962 {
+		return VerifyRFC6962Inclusion(dataBlock, idx, m.NumLeaves, proof, m.Root, &m.Config)
+	}
+	if proof != nil && len(proof.Siblings) != m.Depth*(arityOf(&m.Config)-1) {
+		return false, nil
 	}
+	return VerifyByIndex(dataBlock, idx, proof, m.Root, &m.Config)
+}
 
-	return true, nil
+// MultiVerify checks if all the data blocks are valid using the same Merkle Tree proof and the cached
+// Merkle root hash.
+//
+// Deprecated: a single proof is only valid for the data blocks with the same leaf. Verify each proof returned by
+// MultiProof with Verify, or use CompactMultiProof and CompactMultiVerify for a multiproof.
+func (m *MerkleTree) MultiVerify(dataBlocks []DataBlock, proof *Proof) (bool, error) {
+	return MultiVerify(dataBlocks, proof, m.Root, &m.Config)
 }
 
-// Verify checks if the data blocks are valid using the Merkle Tree proof and the provided Merkle root hash.
-// It returns true if the data block is valid, false otherwise. An error is returned in case of any issues
-// during the verification process.
-// Note: Verify Proofs must use same []DataBlock sequence！
+// MultiVerify checks if all the data blocks are valid using the same Merkle Tree proof and the provided
+// Merkle root hash. It returns true if all the data blocks are valid, false otherwise.
+//
+// Deprecated: a single proof is only valid for the data blocks with the same leaf. Verify each proof returned by
+// MultiProof with Verify, or use CompactMultiProof and CompactMultiVerify for a multiproof.
 func MultiVerify(dataBlocks []DataBlock, proof *Proof, root []byte, config *Config) (bool, error) {
 	for _, dataBlock := range dataBlocks {
-		rst, err := Verify(dataBlock, proof, root, config)
-		if err != nil || !rst {
+		ok, err := Verify(dataBlock, proof, root, config)
+		if err != nil || !ok {
 			return false, err
 		}
 	}
-
 	return true, nil
 }
 
@@ -758,18 +1106,8 @@ func Verify(dataBlock DataBlock, proof *Proof, root []byte, config *Config) (boo
 	if proof == nil {
 		return false, ErrProofIsNil
 	}
-	if config == nil {
-		config = new(Config)
-	}
-	if config.HashFunc == nil {
-		config.HashFunc = DefaultHashFunc
-	}
-
-	// Determine the concatenation function based on the configuration.
-	concatFunc := concatHash
-	if config.SortSiblingPairs {
-		concatFunc = concatSortHash
-	}
+	// Work on a copy so that the configuration shared by other goroutines is not modified.
+	config = copyConfig(config)
 
 	// Convert the data block to a leaf.
 	leaf, err := dataBlockToLeaf(dataBlock, config)
@@ -777,10 +1115,52 @@ func Verify(dataBlock DataBlock, proof *Proof, root []byte, config *Config) (boo
 		return false, err
 	}
 
-	// Traverse the Merkle proof and compute the resulting hash.
-	// Copy the slice so that the original leaf won't be modified.
-	result := make([]byte, len(leaf))
-	copy(result, leaf)
+	result, err := proofRoot(leaf, proof, config)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(result, root), nil
+}
+
+// VerifyByIndex checks if the data block is valid at the given leaf index using the Merkle Tree proof
+// and the Merkle root hash. Besides Verify, it checks that the proof path leads from the leaf index,
+// so that a proof of another occurrence of the same data block is rejected.
+func VerifyByIndex(dataBlock DataBlock, idx int, proof *Proof, root []byte, config *Config) (bool, error) {
+	if proof == nil {
+		return false, ErrProofIsNil
+	}
+	if config != nil && config.RFC6962 {
+		return false, ErrRFC6962TreeSizeRequired
+	}
+	if config != nil && config.Arity > 2 {
+		return verifyByIndexNary(dataBlock, idx, proof, root, config)
+	}
+	depth := len(proof.Siblings)
+	if idx < 0 || depth > int(MaxDepth) || idx >= 1<<depth {
+		return false, ErrLeafIndexOutOfRange
+	}
+	// The path bit is set if the node is the left child, i.e. the index bit is not set.
+	if proof.Path != ^uint32(idx)&(1<<depth-1) {
+		return false, nil
+	}
+	return Verify(dataBlock, proof, root, config)
+}
+
+// proofRoot traverses the Merkle proof from the node and computes the resulting root hash.
+// The bit i of the proof path is 1 if the node at the i-th step of the proof is the left child.
+func proofRoot(node []byte, proof *Proof, config *Config) ([]byte, error) {
+	if config.Arity > 2 {
+		return proofRootNary(node, proof, config)
+	}
+	// Determine the concatenation function based on the configuration.
+	concatFunc := concatFuncOf(config)
+
+	// Copy the slice so that the original node won't be modified.
+	var (
+		result = make([]byte, len(node))
+		err    error
+	)
+	copy(result, node)
 	path := proof.Path
 	for _, sib := range proof.Siblings {
 		if path&1 == 1 {
@@ -789,11 +1169,11 @@ func Verify(dataBlock DataBlock, proof *Proof, root []byte, config *Config) (boo
 			result, err = config.HashFunc(concatFunc(sib, result))
 		}
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 		path >>= 1
 	}
-	return bytes.Equal(result, root), nil
+	return result, nil
 }
 
 // Proof generates the Merkle proof for a data block using the previously generated Merkle Tree structure.
@@ -812,45 +1192,95 @@ func (m *MerkleTree) Proof(dataBlock DataBlock) (*Proof, error) {
 	}
 
 	// Retrieve the index of the leaf in the Merkle Tree.
-	m.leafMapMu.Lock()
-	idx, ok := m.leafMap[string(leaf)]
-	m.leafMapMu.Unlock()
-	if !ok {
+	// If the leaf occurs more than once, the proof of the last occurrence is generated.
+	indices, err := m.leafIndices(leaf)
+	if err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
 		return nil, ErrProofInvalidDataBlock
 	}
 
-	// Compute the path and siblings for the proof.
-	var (
-		path     uint32
-		siblings = make([][]byte, m.Depth)
-	)
-	for i := 0; i < m.Depth; i++ {
-		if idx&1 == 1 {
-			siblings[i] = m.nodes[i][idx-1]
-		} else {
-			path += 1 << i
-			siblings[i] = m.nodes[i][idx+1]
-		}
-		idx >>= 1
-	}
-	return &Proof{
-		Path:     path,
-		Siblings: siblings,
-	}, nil
+	return m.nodeProof(0, indices[len(indices)-1])
 }
 
-// Proofs generates the Merkle proof for some data blocks using the previously generated Merkle Tree structure.
-// Note: Verify Proofs must use same []DataBlock sequence！
+// MultiProof generates the Merkle proofs for the data blocks, in the order of the data blocks.
+// Each proof is generated by Proof and is independent of the others.
+//
+// Deprecated: use Proof for each data block, or CompactMultiProof for a multiproof sharing the siblings.
 func (m *MerkleTree) MultiProof(dataBlocks []DataBlock) (*[]Proof, error) {
-	proofs := []Proof{}
-
+	proofs := make([]Proof, 0, len(dataBlocks))
 	for _, dataBlock := range dataBlocks {
 		proof, err := m.Proof(dataBlock)
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
 		proofs = append(proofs, *proof)
 	}
-
 	return &proofs, nil
 }
+
+// ProofByIndex returns the Merkle proof for the leaf at the given index, regardless of the leaf content.
+// It is available in all the configuration modes.
+func (m *MerkleTree) ProofByIndex(idx int) (*Proof, error) {
+	if idx < 0 || idx >= m.NumLeaves {
+		return nil, ErrLeafIndexOutOfRange
+	}
+	if m.Mode == ModeProofGen {
+		// Copy the cached proof so that the caller won't modify it.
+		return copyProof(m.Proofs[idx]), nil
+	}
+	return m.nodeProof(0, idx)
+}
+
+// copyProof returns a copy of the proof which does not share the slices with it.
+func copyProof(proof *Proof) *Proof {
+	return &Proof{
+		Siblings:  append([][]byte(nil), proof.Siblings...),
+		Path:      proof.Path,
+		Positions: append([]int(nil), proof.Positions...),
+	}
+}
+
+// LeafIndices returns the indices of all the leaves of the data block in ascending order.
+// It returns ErrProofInvalidDataBlock if the data block is not a member of the Merkle Tree.
+func (m *MerkleTree) LeafIndices(dataBlock DataBlock) ([]int, error) {
+	leaf, err := dataBlockToLeaf(dataBlock, &m.Config)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := m.leafIndices(leaf)
+	if err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
+		return nil, ErrProofInvalidDataBlock
+	}
+	// Copy the indices so that the leafMap won't be modified.
+	return append([]int(nil), indices...), nil
+}
+
+// leafIndices returns the indices of the leaf in the Merkle Tree.
+// The leafMap is built on the first call if it is not built with the tree, i.e. in ModeProofGen
+// or for a tree restored by NewFromNodeStore.
+func (m *MerkleTree) leafIndices(leaf []byte) ([]int, error) {
+	m.leafMapMu.Lock()
+	defer m.leafMapMu.Unlock()
+	if m.leafMap == nil {
+		leafMap := newLeafIndex(m.NumLeaves)
+		for i := 0; i < m.NumLeaves; i++ {
+			var node []byte
+			if len(m.Leaves) == m.NumLeaves {
+				node = m.Leaves[i]
+			} else {
+				var err error
+				if node, err = m.NodeStore.Get(0, i); err != nil {
+					return nil, err
+				}
+			}
+			leafMap.add(string(node), i)
+		}
+		m.leafMap = leafMap
+	}
+	return m.leafMap.indices(leaf), nil
+}
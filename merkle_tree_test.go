@@ -1267,3 +1267,34 @@ func BenchmarkMerkleTreeNew_modeTreeBuildRunInParallel(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkMerkleTreeNew_contiguousNodes(b *testing.B) {
+	config := &Config{
+		ContiguousNodes: true,
+	}
+	testCases := generatedTestDataBlocks(benchSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := New(config, testCases)
+		if err != nil {
+			b.Errorf("Build() error = %v", err)
+		}
+	}
+}
+
+func BenchmarkMerkleTreeNew_modeTreeBuildContiguousNodes(b *testing.B) {
+	config := &Config{
+		Mode:            ModeTreeBuild,
+		ContiguousNodes: true,
+	}
+	testCases := generatedTestDataBlocks(benchSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := New(config, testCases)
+		if err != nil {
+			b.Errorf("Build() error = %v", err)
+		}
+	}
+}
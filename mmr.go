@@ -0,0 +1,303 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/bits"
+	"os"
+)
+
+// MMR is a Merkle Mountain Range, an append-only accumulator made of perfect binary trees, the mountains,
+// whose sizes are the powers of two in the binary representation of the number of leaves.
+// Appending a leaf merges the mountains of the same height, which takes O(log n) hashes, and the root
+// is computed by bagging the peaks of the mountains from right to left.
+// The nodes are kept in a node store, addressed by their height and index as in the levels of a Merkle Tree,
+// so the proofs can be generated for the leaves appended to the node store.
+// An MMR is not safe for concurrent use.
+type MMR struct {
+	config Config
+	// concatHashFunc is the function for concatenating two hashes, as in MerkleTree.
+	concatHashFunc typeConcatHashFunc
+	// store is the storage of the nodes.
+	store NodeStore
+	// peaks are the roots of the mountains from the highest one on the left to the lowest one on the right.
+	peaks [][]byte
+	// NumLeaves is the number of leaves in the MMR.
+	NumLeaves int
+}
+
+// MMRState is the state of an MMR needed to resume appending, e.g. after a restart.
+type MMRState struct {
+	// NumLeaves is the number of leaves in the MMR.
+	NumLeaves int
+	// Peaks are the roots of the mountains from the highest one on the left to the lowest one on the right.
+	Peaks [][]byte
+}
+
+// MMRProof is the proof of a leaf in an MMR of a given size.
+// It can be updated by UpdateProof to a larger size of the same MMR.
+type MMRProof struct {
+	// Index is the index of the leaf.
+	Index int
+	// Size is the number of leaves of the MMR when the proof was generated.
+	Size int
+	// Siblings are the siblings of the path from the leaf to the peak of its mountain.
+	Siblings [][]byte
+	// Peaks are all the peaks of the MMR, including the peak of the mountain of the leaf.
+	Peaks [][]byte
+}
+
+// NewMMR creates an empty MMR whose nodes are kept in a MemoryNodeStore.
+// The leaves and the nodes are hashed as in a MerkleTree with the configuration.
+func NewMMR(config *Config) *MMR {
+	return NewMMRWithNodeStore(config, nil)
+}
+
+// NewMMRWithNodeStore creates an empty MMR whose nodes are kept in the node store, e.g. a FileNodeStore.
+// If the node store is nil, the nodes are kept in a MemoryNodeStore. Each MMR needs its own node store.
+func NewMMRWithNodeStore(config *Config, store NodeStore) *MMR {
+	if store == nil {
+		store = NewMemoryNodeStore()
+	}
+	m := &MMR{
+		config: *copyConfig(config),
+		store:  store,
+	}
+	m.concatHashFunc = concatFuncOf(&m.config)
+	return m
+}
+
+// NewMMRFromState resumes an MMR from its state, keeping the nodes in the node store as NewMMRWithNodeStore.
+// The peaks are stored in the node store, so the proofs can be generated for the leaves appended after
+// resuming, but not for the leaves appended before, whose other nodes are not in the node store.
+func NewMMRFromState(config *Config, state *MMRState, store NodeStore) (*MMR, error) {
+	if state == nil || state.NumLeaves < 0 || len(state.Peaks) != bits.OnesCount(uint(state.NumLeaves)) {
+		return nil, ErrInvalidMMRState
+	}
+	m := NewMMRWithNodeStore(config, store)
+	// Store the peaks at their heights, as the leaves appended later are merged with them.
+	start := 0
+	for i, height := 0, bits.Len(uint(state.NumLeaves))-1; height >= 0; height-- {
+		if state.NumLeaves&(1<<height) == 0 {
+			continue
+		}
+		if err := m.store.Put(height, start>>height, state.Peaks[i]); err != nil {
+			return nil, err
+		}
+		start += 1 << height
+		i++
+	}
+	m.NumLeaves = state.NumLeaves
+	m.peaks = append([][]byte(nil), state.Peaks...)
+	return m, nil
+}
+
+// NewMMRStateFromFile loads the MMR state stored by StoreToFile.
+func NewMMRStateFromFile(filePath string) (*MMRState, error) {
+	readFile, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer readFile.Close()
+	decoder := gob.NewDecoder(readFile)
+
+	loaded := MMRState{}
+	if err = decoder.Decode(&loaded); err != nil {
+		return nil, err
+	}
+	return &loaded, nil
+}
+
+// StoreToFile stores the MMR state in the file.
+func (s *MMRState) StoreToFile(filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := gob.NewEncoder(file)
+	return encoder.Encode(s)
+}
+
+// State returns the state of the MMR, which can be persisted to resume appending by NewMMRFromState.
+func (m *MMR) State() *MMRState {
+	return &MMRState{
+		NumLeaves: m.NumLeaves,
+		Peaks:     append([][]byte(nil), m.peaks...),
+	}
+}
+
+// Peaks returns the roots of the mountains from the highest one on the left to the lowest one on the right.
+func (m *MMR) Peaks() [][]byte {
+	return append([][]byte(nil), m.peaks...)
+}
+
+// Append appends the data blocks as new leaves.
+func (m *MMR) Append(blocks ...DataBlock) error {
+	for _, block := range blocks {
+		if block == nil {
+			return ErrDataBlockIsNil
+		}
+		leaf, err := dataBlockToLeaf(block, &m.config)
+		if err != nil {
+			return err
+		}
+		if err = m.appendLeaf(leaf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendLeaf stores the leaf and merges the mountains of the same height on the right.
+// A node with an odd index is the right child, so it is merged with the peak on its left.
+func (m *MMR) appendLeaf(leaf []byte) (err error) {
+	node, idx := leaf, m.NumLeaves
+	if err = m.store.Put(0, idx, node); err != nil {
+		return err
+	}
+	for height := 0; idx&1 == 1; height++ {
+		left := m.peaks[len(m.peaks)-1]
+		m.peaks = m.peaks[:len(m.peaks)-1]
+		if node, err = m.config.HashFunc(m.concatHashFunc(left, node)); err != nil {
+			return err
+		}
+		idx >>= 1
+		if err = m.store.Put(height+1, idx, node); err != nil {
+			return err
+		}
+	}
+	m.peaks = append(m.peaks, node)
+	m.NumLeaves++
+	return nil
+}
+
+// Root returns the root of the MMR, which bags the peaks from right to left.
+// It returns ErrMMRIsEmpty if the MMR has no leaves.
+func (m *MMR) Root() ([]byte, error) {
+	return bagPeaks(m.peaks, &m.config)
+}
+
+// bagPeaks computes the root from the peaks. The root of a single mountain is its peak.
+func bagPeaks(peaks [][]byte, config *Config) (root []byte, err error) {
+	if len(peaks) == 0 {
+		return nil, ErrMMRIsEmpty
+	}
+	concatFunc := concatFuncOf(config)
+	root = peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		if root, err = config.HashFunc(concatFunc(peaks[i], root)); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// mmrMountain returns the position of the mountain of the leaf in an MMR of size leaves,
+// and the height of the mountain.
+func mmrMountain(idx, size int) (pos, height int) {
+	start := 0
+	for height = bits.Len(uint(size)) - 1; height >= 0; height-- {
+		if size&(1<<height) == 0 {
+			continue
+		}
+		if idx < start+1<<height {
+			return pos, height
+		}
+		start += 1 << height
+		pos++
+	}
+	return -1, -1
+}
+
+// Proof generates the proof of the leaf at the index.
+func (m *MMR) Proof(idx int) (*MMRProof, error) {
+	return m.UpdateProof(&MMRProof{Index: idx, Size: idx + 1})
+}
+
+// UpdateProof updates the proof generated for a smaller size of the MMR to the current size.
+// The siblings in the proof are kept, and the siblings of the merged mountains are appended.
+func (m *MMR) UpdateProof(proof *MMRProof) (*MMRProof, error) {
+	if proof == nil {
+		return nil, ErrProofIsNil
+	}
+	if proof.Index < 0 || proof.Index >= proof.Size || proof.Size > m.NumLeaves {
+		return nil, ErrLeafIndexOutOfRange
+	}
+	_, height := mmrMountain(proof.Index, m.NumLeaves)
+	if len(proof.Siblings) > height {
+		return nil, ErrInvalidMMRProof
+	}
+	siblings := make([][]byte, len(proof.Siblings), height)
+	copy(siblings, proof.Siblings)
+	for i := len(siblings); i < height; i++ {
+		sibling, err := m.store.Get(i, proof.Index>>i^1)
+		if err != nil {
+			return nil, err
+		}
+		if sibling == nil {
+			return nil, ErrNodeNotFound
+		}
+		siblings = append(siblings, sibling)
+	}
+	return &MMRProof{
+		Index:    proof.Index,
+		Size:     m.NumLeaves,
+		Siblings: siblings,
+		Peaks:    m.Peaks(),
+	}, nil
+}
+
+// Verify checks if the data block is valid using the proof and the root of the MMR.
+func (m *MMR) Verify(dataBlock DataBlock, proof *MMRProof) (bool, error) {
+	root, err := m.Root()
+	if err != nil {
+		return false, err
+	}
+	return VerifyMMR(dataBlock, proof, root, &m.config)
+}
+
+// VerifyMMR checks if the data block is valid using the proof and the root of an MMR,
+// which are hashed with the configuration.
+func VerifyMMR(dataBlock DataBlock, proof *MMRProof, root []byte, config *Config) (bool, error) {
+	if dataBlock == nil {
+		return false, ErrDataBlockIsNil
+	}
+	if proof == nil {
+		return false, ErrProofIsNil
+	}
+	if proof.Index < 0 || proof.Index >= proof.Size {
+		return false, ErrLeafIndexOutOfRange
+	}
+	pos, height := mmrMountain(proof.Index, proof.Size)
+	if len(proof.Siblings) != height || len(proof.Peaks) != bits.OnesCount(uint(proof.Size)) {
+		return false, ErrInvalidMMRProof
+	}
+	// Work on a copy so that the configuration shared by other goroutines is not modified.
+	config = copyConfig(config)
+	concatFunc := concatFuncOf(config)
+
+	node, err := dataBlockToLeaf(dataBlock, config)
+	if err != nil {
+		return false, err
+	}
+	for i, sibling := range proof.Siblings {
+		if proof.Index>>i&1 == 0 {
+			node, err = config.HashFunc(concatFunc(node, sibling))
+		} else {
+			node, err = config.HashFunc(concatFunc(sibling, node))
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if !bytes.Equal(node, proof.Peaks[pos]) {
+		return false, nil
+	}
+	bagged, err := bagPeaks(proof.Peaks, config)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(bagged, root), nil
+}
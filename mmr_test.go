@@ -0,0 +1,180 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMMR(t *testing.T) {
+	blocks := generatedTestDataBlocks(70)
+	data := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		data[i], _ = block.Serialize()
+	}
+	for _, config := range []*Config{nil, {RFC6962: true}, {SortSiblingPairs: true}} {
+		m := NewMMR(config)
+		if _, err := m.Root(); !errors.Is(err, ErrMMRIsEmpty) {
+			t.Fatalf("Root() error = %v, want %v", err, ErrMMRIsEmpty)
+		}
+		var proofs []*MMRProof
+		for n := 1; n <= len(blocks); n++ {
+			if err := m.Append(blocks[n-1]); err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+			root, err := m.Root()
+			if err != nil {
+				t.Fatalf("Root() error = %v", err)
+			}
+			// Bagging the peaks from right to left gives the RFC 6962 Merkle Tree Hash,
+			// and a single mountain is a perfect Merkle Tree.
+			if config != nil && config.RFC6962 && !bytes.Equal(root, rfc6962ReferenceRoot(data[:n])) {
+				t.Fatalf("size %d: root = %x, want the RFC 6962 root", n, root)
+			}
+			if n > 1 && n&(n-1) == 0 {
+				tree, err := New(config, blocks[:n])
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				if !bytes.Equal(root, tree.Root) {
+					t.Fatalf("size %d: root = %x, want %x", n, root, tree.Root)
+				}
+			}
+			proof, err := m.Proof(n - 1)
+			if err != nil {
+				t.Fatalf("Proof() error = %v", err)
+			}
+			proofs = append(proofs, proof)
+
+			// The proofs of the earlier sizes are updated to the current size.
+			for i := 0; i < n; i += 7 {
+				if proofs[i], err = m.UpdateProof(proofs[i]); err != nil {
+					t.Fatalf("UpdateProof() error = %v", err)
+				}
+				if ok, err := m.Verify(blocks[i], proofs[i]); err != nil || !ok {
+					t.Fatalf("size %d: Verify(%d) = %v, %v", n, i, ok, err)
+				}
+				if ok, _ := VerifyMMR(blocks[(i+1)%n], proofs[i], root, config); ok && n > 1 {
+					t.Fatalf("size %d: VerifyMMR() of a wrong block = true", n)
+				}
+			}
+		}
+		for i, block := range blocks {
+			proof, err := m.Proof(i)
+			if err != nil {
+				t.Fatalf("Proof() error = %v", err)
+			}
+			if ok, err := m.Verify(block, proof); err != nil || !ok {
+				t.Fatalf("Verify(%d) = %v, %v", i, ok, err)
+			}
+		}
+	}
+}
+
+func TestMMR_resume(t *testing.T) {
+	blocks := generatedTestDataBlocks(45)
+	want := NewMMR(nil)
+	if err := want.Append(blocks...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	m := NewMMR(nil)
+	if err := m.Append(blocks[:27]...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "mmr.gob")
+	if err := m.State().StoreToFile(path); err != nil {
+		t.Fatalf("StoreToFile() error = %v", err)
+	}
+	state, err := NewMMRStateFromFile(path)
+	if err != nil {
+		t.Fatalf("NewMMRStateFromFile() error = %v", err)
+	}
+	resumed, err := NewMMRFromState(nil, state, nil)
+	if err != nil {
+		t.Fatalf("NewMMRFromState() error = %v", err)
+	}
+	if err = resumed.Append(blocks[27:]...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	root, _ := resumed.Root()
+	wantRoot, _ := want.Root()
+	if !bytes.Equal(root, wantRoot) || resumed.NumLeaves != len(blocks) {
+		t.Fatalf("resumed root = %x, want %x", root, wantRoot)
+	}
+
+	// The leaves appended after resuming are merged with the restored peaks, e.g. the leaf 27 with the leaf 26.
+	for _, idx := range []int{27, 28, 31, 44} {
+		proof, err := resumed.Proof(idx)
+		if err != nil {
+			t.Fatalf("Proof(%d) error = %v", idx, err)
+		}
+		if ok, err := VerifyMMR(blocks[idx], proof, wantRoot, nil); err != nil || !ok {
+			t.Fatalf("VerifyMMR(%d) = %v, %v", idx, ok, err)
+		}
+	}
+	// The nodes below the peaks of the leaves appended before resuming are not in the new node store.
+	if _, err = resumed.Proof(0); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Proof() error = %v, want %v", err, ErrNodeNotFound)
+	}
+
+	// The skipped nodes of the other node stores are not found either, instead of reading back as zeros.
+	dir := t.TempDir()
+	fileStore, err := NewFileNodeStore(dir, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, store := range []NodeStore{fileStore, NewFlatNodeStore(32)} {
+		if resumed, err = NewMMRFromState(nil, state, store); err != nil {
+			t.Fatalf("NewMMRFromState() error = %v", err)
+		}
+		if err = resumed.Append(blocks[27:]...); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		for _, idx := range []int{0, 20, 24} {
+			if _, err = resumed.Proof(idx); !errors.Is(err, ErrNodeNotFound) {
+				t.Errorf("Proof(%d) error = %v, want %v", idx, err, ErrNodeNotFound)
+			}
+		}
+		if _, err = resumed.Proof(31); err != nil {
+			t.Errorf("Proof() error = %v", err)
+		}
+	}
+	if err = fileStore.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenFileNodeStore(dir, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, err = reopened.Get(0, 0); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Get() of a skipped node error = %v, want %v", err, ErrNodeNotFound)
+	}
+}
+
+func TestMMR_errors(t *testing.T) {
+	if _, err := NewMMRFromState(nil, &MMRState{NumLeaves: 3, Peaks: [][]byte{{1}}}, nil); !errors.Is(err, ErrInvalidMMRState) {
+		t.Errorf("NewMMRFromState() error = %v, want %v", err, ErrInvalidMMRState)
+	}
+	blocks := generatedTestDataBlocks(5)
+	m := NewMMR(nil)
+	if err := m.Append(blocks...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if _, err := m.Proof(5); !errors.Is(err, ErrLeafIndexOutOfRange) {
+		t.Errorf("Proof() error = %v, want %v", err, ErrLeafIndexOutOfRange)
+	}
+	proof, err := m.Proof(1)
+	if err != nil {
+		t.Fatalf("Proof() error = %v", err)
+	}
+	proof.Peaks = proof.Peaks[1:]
+	if _, err = m.Verify(blocks[1], proof); !errors.Is(err, ErrInvalidMMRProof) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrInvalidMMRProof)
+	}
+	if err = m.Append(nil); !errors.Is(err, ErrDataBlockIsNil) {
+		t.Errorf("Append() error = %v, want %v", err, ErrDataBlockIsNil)
+	}
+}
@@ -0,0 +1,203 @@
+package merkletree
+
+import (
+	"bytes"
+	"sort"
+)
+
+// CompactMultiProof is the compact proof of multiple leaves, compatible with MerkleProof.multiProofVerify
+// of OpenZeppelin. The siblings shared by the leaves are included only once.
+type CompactMultiProof struct {
+	// Indices are the indices of the proven leaves in ascending order.
+	Indices []int
+	// Leaves are the proven leaves in the order of Indices.
+	Leaves [][]byte
+	// Proof are the sibling nodes which cannot be computed from the leaves, in the order they are consumed.
+	Proof [][]byte
+	// ProofFlags indicates for each hash computed during the verification whether the second operand is
+	// the next leaf or computed hash (true), or the next node of Proof (false).
+	ProofFlags []bool
+}
+
+// CompactMultiProof generates the multiproof for the data blocks using the previously generated Merkle Tree structure.
+// If a data block occurs more than once in the tree, the last occurrence is proven, so the same data block
+// cannot be given twice. The data blocks can be in any order, and CompactMultiVerify must be called with them
+// in the order of the proof Indices.
+// This method is only available when SortSiblingPairs is true, and the configuration mode is ModeTreeBuild
+// or ModeProofGenAndTreeBuild.
+func (m *MerkleTree) CompactMultiProof(dataBlocks []DataBlock) (*CompactMultiProof, error) {
+	indices := make([]int, len(dataBlocks))
+	for i, dataBlock := range dataBlocks {
+		leaf, err := dataBlockToLeaf(dataBlock, &m.Config)
+		if err != nil {
+			return nil, err
+		}
+		leafIndices, err := m.leafIndices(leaf)
+		if err != nil {
+			return nil, err
+		}
+		if len(leafIndices) == 0 {
+			return nil, ErrProofInvalidDataBlock
+		}
+		indices[i] = leafIndices[len(leafIndices)-1]
+	}
+	return m.CompactMultiProofByIndices(indices)
+}
+
+// CompactMultiProofByIndices generates the multiproof for the leaves at the given indices.
+// As in StandardMerkleTree.getMultiProof of OpenZeppelin, duplicate indices are rejected with
+// ErrMultiProofDuplicateLeaf. The indices of the proof are sorted in ascending order.
+func (m *MerkleTree) CompactMultiProofByIndices(indices []int) (*CompactMultiProof, error) {
+	if m.Mode != ModeTreeBuild && m.Mode != ModeProofGenAndTreeBuild {
+		return nil, ErrProofInvalidModeTreeNotBuilt
+	}
+	if !m.SortSiblingPairs {
+		return nil, ErrMultiProofUnsortedPairs
+	}
+	if len(indices) == 0 {
+		return nil, ErrMultiProofNoLeaves
+	}
+
+	known := append([]int(nil), indices...)
+	sort.Ints(known)
+	for i, idx := range known {
+		if idx < 0 || idx >= m.NumLeaves {
+			return nil, ErrLeafIndexOutOfRange
+		}
+		if i > 0 && idx == known[i-1] {
+			return nil, ErrMultiProofDuplicateLeaf
+		}
+	}
+
+	proof := &CompactMultiProof{
+		Indices: append([]int(nil), known...),
+		Leaves:  make([][]byte, len(known)),
+	}
+	for i, idx := range known {
+		leaf, err := m.NodeStore.Get(0, idx)
+		if err != nil {
+			return nil, err
+		}
+		proof.Leaves[i] = leaf
+	}
+
+	// Walk up the tree level by level. The known nodes of each level are processed in ascending order,
+	// which is the order in which the verification consumes the leaves and the computed hashes.
+	for level := 0; level < m.Depth; level++ {
+		parents := make([]int, 0, len(known))
+		for i := 0; i < len(known); i++ {
+			idx := known[i]
+			if idx&1 == 0 && i+1 < len(known) && known[i+1] == idx+1 {
+				proof.ProofFlags = append(proof.ProofFlags, true)
+				i++
+			} else {
+				sibling, err := m.NodeStore.Get(level, idx^1)
+				if err != nil {
+					return nil, err
+				}
+				proof.Proof = append(proof.Proof, sibling)
+				proof.ProofFlags = append(proof.ProofFlags, false)
+			}
+			parents = append(parents, idx>>1)
+		}
+		known = parents
+	}
+	return proof, nil
+}
+
+// CompactMultiVerify checks if the data blocks are valid using the multiproof and the cached Merkle root hash.
+// The data blocks must be in the order of the proof Indices.
+func (m *MerkleTree) CompactMultiVerify(dataBlocks []DataBlock, proof *CompactMultiProof) (bool, error) {
+	return CompactMultiVerify(dataBlocks, proof, m.Root, &m.Config)
+}
+
+// CompactMultiVerify checks if the data blocks are valid using the multiproof and the provided Merkle root hash.
+// The data blocks must be in the order of the proof Indices. It returns true if the data blocks are valid,
+// false otherwise. An error is returned in case of any issues during the verification process.
+func CompactMultiVerify(dataBlocks []DataBlock, proof *CompactMultiProof, root []byte, config *Config) (bool, error) {
+	if proof == nil {
+		return false, ErrProofIsNil
+	}
+	// Work on a copy so that the configuration shared by other goroutines is not modified.
+	config = copyConfig(config)
+	leaves := make([][]byte, len(dataBlocks))
+	for i, dataBlock := range dataBlocks {
+		if dataBlock == nil {
+			return false, ErrDataBlockIsNil
+		}
+		leaf, err := dataBlockToLeaf(dataBlock, config)
+		if err != nil {
+			return false, err
+		}
+		leaves[i] = leaf
+	}
+	return CompactMultiVerifyLeaves(leaves, proof, root, config)
+}
+
+// CompactMultiVerifyLeaves checks if the leaves are valid using the multiproof and the provided Merkle root hash,
+// in the same way as MerkleProof.multiProofVerify of OpenZeppelin. The leaves are used as-is without hashing.
+func CompactMultiVerifyLeaves(leaves [][]byte, proof *CompactMultiProof, root []byte, config *Config) (bool, error) {
+	if proof == nil {
+		return false, ErrProofIsNil
+	}
+	config = copyConfig(config)
+	if !config.SortSiblingPairs {
+		return false, ErrMultiProofUnsortedPairs
+	}
+	if len(leaves) == 0 {
+		return false, ErrMultiProofNoLeaves
+	}
+	totalHashes := len(proof.ProofFlags)
+	if len(leaves)+len(proof.Proof) != totalHashes+1 {
+		return false, nil
+	}
+
+	var (
+		hashes                     = make([][]byte, totalHashes)
+		leafPos, hashPos, proofPos int
+		err                        error
+	)
+	// next returns the next leaf, or the next computed hash once all the leaves are consumed.
+	next := func() []byte {
+		if leafPos < len(leaves) {
+			leafPos++
+			return leaves[leafPos-1]
+		}
+		hashPos++
+		return hashes[hashPos-1]
+	}
+	for i := 0; i < totalHashes; i++ {
+		if leafPos == len(leaves) && hashPos >= i {
+			return false, nil
+		}
+		a := next()
+		var b []byte
+		if proof.ProofFlags[i] {
+			if leafPos == len(leaves) && hashPos >= i {
+				return false, nil
+			}
+			b = next()
+		} else {
+			if proofPos == len(proof.Proof) {
+				return false, nil
+			}
+			b = proof.Proof[proofPos]
+			proofPos++
+		}
+		if hashes[i], err = config.HashFunc(concatSortHash(a, b)); err != nil {
+			return false, err
+		}
+	}
+
+	var result []byte
+	switch {
+	case totalHashes > 0:
+		if proofPos != len(proof.Proof) {
+			return false, nil
+		}
+		result = hashes[totalHashes-1]
+	default:
+		result = leaves[0]
+	}
+	return bytes.Equal(result, root), nil
+}
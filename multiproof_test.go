@@ -0,0 +1,174 @@
+package merkletree
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/txaty/go-merkletree/mock"
+)
+
+func TestMerkleTree_CompactMultiProof(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, config := range []*Config{
+		{Mode: ModeTreeBuild, SortSiblingPairs: true},
+		{Mode: ModeProofGenAndTreeBuild, SortSiblingPairs: true, ZeroPadding: true},
+	} {
+		for _, numBlocks := range []int{2, 5, 8, 13, 32} {
+			blocks := generatedTestDataBlocks(numBlocks)
+			m, err := New(config, blocks)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			subsets := [][]int{{0}, {numBlocks - 1}, {0, 1}, {numBlocks - 1, 0}}
+			all := make([]int, numBlocks)
+			for i := range all {
+				all[i] = i
+			}
+			subsets = append(subsets, all)
+			for i := 0; i < 10; i++ {
+				subsets = append(subsets, r.Perm(numBlocks)[:1+r.Intn(numBlocks)])
+			}
+			for _, subset := range subsets {
+				proof, err := m.CompactMultiProofByIndices(subset)
+				if err != nil {
+					t.Fatalf("CompactMultiProofByIndices() error = %v", err)
+				}
+				proven := make([]DataBlock, len(proof.Indices))
+				for i, idx := range proof.Indices {
+					proven[i] = blocks[idx]
+				}
+				ok, err := m.CompactMultiVerify(proven, proof)
+				if err != nil || !ok {
+					t.Fatalf("blocks %d subset %v: CompactMultiVerify() = %v, %v", numBlocks, subset, ok, err)
+				}
+				if len(proof.Indices) == numBlocks && len(proof.Proof) != 0 && numBlocks&(numBlocks-1) == 0 {
+					t.Errorf("proof of all the leaves has %d siblings", len(proof.Proof))
+				}
+
+				// Replacing a proven block with an unproven one must fail.
+				if len(proof.Indices) < numBlocks {
+					tampered := append([]DataBlock(nil), proven...)
+					for idx := 0; idx < numBlocks; idx++ {
+						if !containsInt(proof.Indices, idx) {
+							tampered[0] = blocks[idx]
+							break
+						}
+					}
+					if ok, err = m.CompactMultiVerify(tampered, proof); err != nil || ok {
+						t.Errorf("CompactMultiVerify() with a tampered block = %v, %v", ok, err)
+					}
+				}
+			}
+
+			// The data blocks can be proven by content as well.
+			proof, err := m.CompactMultiProof([]DataBlock{blocks[numBlocks-1], blocks[0]})
+			if err != nil {
+				t.Fatalf("CompactMultiProof() error = %v", err)
+			}
+			ok, err := CompactMultiVerify([]DataBlock{blocks[0], blocks[numBlocks-1]}, proof, m.Root, config)
+			if err != nil || !ok {
+				t.Fatalf("CompactMultiVerify() = %v, %v", ok, err)
+			}
+		}
+	}
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMerkleTree_CompactMultiProof_sharedSiblings(t *testing.T) {
+	m, err := New(&Config{Mode: ModeTreeBuild, SortSiblingPairs: true}, generatedTestDataBlocks(16))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	proof, err := m.CompactMultiProofByIndices([]int{0, 1, 2, 3})
+	if err != nil {
+		t.Fatalf("CompactMultiProofByIndices() error = %v", err)
+	}
+	// The leaves form a complete subtree, so only its two uncles are needed.
+	if len(proof.Proof) != 2 || len(proof.ProofFlags) != 5 {
+		t.Errorf("len(Proof) = %d, len(ProofFlags) = %d, want 2 and 5", len(proof.Proof), len(proof.ProofFlags))
+	}
+}
+
+func TestMerkleTree_CompactMultiProof_errors(t *testing.T) {
+	blocks := generatedTestDataBlocks(5)
+	m, err := New(&Config{Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err = m.CompactMultiProofByIndices([]int{0}); !errors.Is(err, ErrMultiProofUnsortedPairs) {
+		t.Errorf("CompactMultiProofByIndices() error = %v, want %v", err, ErrMultiProofUnsortedPairs)
+	}
+	m, err = New(&Config{Mode: ModeTreeBuild, SortSiblingPairs: true}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err = m.CompactMultiProofByIndices(nil); !errors.Is(err, ErrMultiProofNoLeaves) {
+		t.Errorf("CompactMultiProofByIndices() error = %v, want %v", err, ErrMultiProofNoLeaves)
+	}
+	if _, err = m.CompactMultiProofByIndices([]int{5}); !errors.Is(err, ErrLeafIndexOutOfRange) {
+		t.Errorf("CompactMultiProofByIndices() error = %v, want %v", err, ErrLeafIndexOutOfRange)
+	}
+	if _, err = m.CompactMultiProofByIndices([]int{4, 0, 4}); !errors.Is(err, ErrMultiProofDuplicateLeaf) {
+		t.Errorf("CompactMultiProofByIndices() error = %v, want %v", err, ErrMultiProofDuplicateLeaf)
+	}
+	if _, err = m.CompactMultiProof([]DataBlock{blocks[2], blocks[2]}); !errors.Is(err, ErrMultiProofDuplicateLeaf) {
+		t.Errorf("CompactMultiProof() error = %v, want %v", err, ErrMultiProofDuplicateLeaf)
+	}
+	if _, err = m.CompactMultiVerify(blocks[:1], nil); !errors.Is(err, ErrProofIsNil) {
+		t.Errorf("CompactMultiVerify() error = %v, want %v", err, ErrProofIsNil)
+	}
+	proof, err := m.CompactMultiProofByIndices([]int{1, 3})
+	if err != nil {
+		t.Fatalf("CompactMultiProofByIndices() error = %v", err)
+	}
+	proof.ProofFlags = append(proof.ProofFlags, true)
+	if ok, err := m.CompactMultiVerify([]DataBlock{blocks[1], blocks[3]}, proof); err != nil || ok {
+		t.Errorf("CompactMultiVerify() with invalid flags = %v, %v", ok, err)
+	}
+	proof.ProofFlags = proof.ProofFlags[:len(proof.ProofFlags)-1]
+	for i := range proof.ProofFlags {
+		proof.ProofFlags[i] = true
+	}
+	proof.Proof = proof.Proof[:1]
+	proof.Leaves = nil
+	if ok, err := m.CompactMultiVerify([]DataBlock{blocks[1], blocks[3]}, proof); err != nil || ok {
+		t.Errorf("CompactMultiVerify() with invalid flags = %v, %v", ok, err)
+	}
+}
+
+func TestMerkleTree_MultiProof(t *testing.T) {
+	blocks := duplicateDataBlocks(10)
+	m, err := New(&Config{Mode: ModeProofGenAndTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	proofs, err := m.MultiProof([]DataBlock{blocks[4], blocks[0], blocks[7]})
+	if err != nil {
+		t.Fatalf("MultiProof() error = %v", err)
+	}
+	// Each proof is the independent proof of its data block.
+	for i, idx := range []int{4, 9, 7} {
+		if ok, err := m.VerifyByIndex(blocks[idx], idx, &(*proofs)[i]); err != nil || !ok {
+			t.Errorf("VerifyByIndex(%d) = %v, %v", idx, ok, err)
+		}
+	}
+	// The same proof is used for all the data blocks, so it only verifies the occurrences of one leaf.
+	if ok, err := m.MultiVerify([]DataBlock{blocks[0], blocks[3]}, &(*proofs)[1]); err != nil || !ok {
+		t.Errorf("MultiVerify() = %v, %v", ok, err)
+	}
+	if ok, err := MultiVerify(blocks[:2], &(*proofs)[1], m.Root, nil); err != nil || ok {
+		t.Errorf("MultiVerify() of different blocks = %v, %v", ok, err)
+	}
+	if _, err = m.MultiProof([]DataBlock{&mock.DataBlock{Data: []byte("missing")}}); !errors.Is(err, ErrProofInvalidDataBlock) {
+		t.Errorf("MultiProof() error = %v, want %v", err, ErrProofInvalidDataBlock)
+	}
+}
@@ -0,0 +1,88 @@
+package merkletree
+
+import "bytes"
+
+// ProveNode generates the proof of the node at the given level and index, such as a sub-piece commitment.
+// Level 0 holds the leaves. As in the proofs of LevelCache, the path bits start from the node level,
+// so that the proof of a leaf in the subtree under the node can be combined with it by AppendProof.
+// This method is only available when the configuration mode is ModeTreeBuild or ModeProofGenAndTreeBuild.
+func (m *MerkleTree) ProveNode(level, idx int) (*Proof, error) {
+	if m.Mode != ModeTreeBuild && m.Mode != ModeProofGenAndTreeBuild {
+		return nil, ErrProofInvalidModeTreeNotBuilt
+	}
+	if level < 0 || level >= m.Depth {
+		return nil, ErrInvalidNodeLevel
+	}
+	if idx < 0 || idx >= m.NodeStore.Len(level) {
+		return nil, ErrNodeNotFound
+	}
+	return m.nodeProof(level, idx)
+}
+
+// nodeProof computes the path and siblings for the proof of the node at the given level and index.
+func (m *MerkleTree) nodeProof(level, idx int) (*Proof, error) {
+	return m.nodeProofFrom(m.NodeStore, level, idx)
+}
+
+// nodeProofFrom computes the proof of the node from the levels in the node store.
+// The last node of an odd level has no sibling in RFC 6962 mode, so it is promoted without a path bit.
+func (m *MerkleTree) nodeProofFrom(store NodeStore, level, idx int) (*Proof, error) {
+	if m.Arity > 2 {
+		return m.nodeProofNary(store, level, idx)
+	}
+	var (
+		path     uint32
+		siblings = make([][]byte, 0, m.Depth-level)
+	)
+	for i := level; i < m.Depth; i++ {
+		if idx^1 >= store.Len(i) {
+			idx >>= 1
+			continue
+		}
+		if idx&1 == 0 {
+			path += 1 << (level + len(siblings))
+		}
+		sibling, err := store.Get(i, idx^1)
+		if err != nil {
+			return nil, err
+		}
+		siblings = append(siblings, sibling)
+		idx >>= 1
+	}
+	return &Proof{
+		Path:     path,
+		Siblings: siblings,
+	}, nil
+}
+
+// VerifyNode checks if the node at the given level is valid using the proof generated by ProveNode
+// and the cached Merkle root hash.
+func (m *MerkleTree) VerifyNode(node []byte, level int, proof *Proof) (bool, error) {
+	return VerifyNode(node, level, proof, m.Root, &m.Config)
+}
+
+// VerifyNode checks if the node at the given level is valid using the proof generated by ProveNode
+// and the Merkle root hash. The node is used as-is without leaf hashing.
+func VerifyNode(node []byte, level int, proof *Proof, root []byte, config *Config) (bool, error) {
+	if node == nil {
+		return false, ErrDataBlockIsNil
+	}
+	if proof == nil {
+		return false, ErrProofIsNil
+	}
+	if level < 0 || level >= int(MaxDepth) {
+		return false, ErrInvalidNodeLevel
+	}
+	// Work on a copy so that the configuration shared by other goroutines is not modified.
+	config = copyConfig(config)
+
+	result, err := proofRoot(node, &Proof{
+		Path:      proof.Path >> uint(level),
+		Siblings:  proof.Siblings,
+		Positions: proof.Positions,
+	}, config)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(result, root), nil
+}
@@ -0,0 +1,111 @@
+package merkletree
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMerkleTree_ProveNode(t *testing.T) {
+	for _, config := range []*Config{
+		{Mode: ModeTreeBuild},
+		{Mode: ModeProofGenAndTreeBuild, ZeroPadding: true},
+		{Mode: ModeTreeBuild, SortSiblingPairs: true, ContiguousNodes: true},
+	} {
+		m, err := New(config, generatedTestDataBlocks(21))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		for level := 0; level < m.Depth; level++ {
+			for idx := 0; idx < m.NodeStore.Len(level); idx++ {
+				node, err := m.NodeStore.Get(level, idx)
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				proof, err := m.ProveNode(level, idx)
+				if err != nil {
+					t.Fatalf("ProveNode() error = %v", err)
+				}
+				ok, err := m.VerifyNode(node, level, proof)
+				if err != nil || !ok {
+					t.Fatalf("VerifyNode(%d, %d) = %v, %v", level, idx, ok, err)
+				}
+				sibling, err := m.NodeStore.Get(level, idx^1)
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				if ok, _ = m.VerifyNode(sibling, level, proof); ok && !config.SortSiblingPairs && !reflect.DeepEqual(sibling, node) {
+					t.Errorf("VerifyNode() with the sibling node = true")
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleTree_ProveNode_appendProof(t *testing.T) {
+	blocks := generatedTestDataBlocks(32)
+	m, err := New(&Config{Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	const level = 3
+	for subtreeIdx := 0; subtreeIdx < len(blocks)>>level; subtreeIdx++ {
+		subtree, err := New(nil, blocks[subtreeIdx<<level:(subtreeIdx+1)<<level])
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		ok, err := m.VerifyNode(subtree.Root, level, mustProveNode(t, m, level, subtreeIdx))
+		if err != nil || !ok {
+			t.Fatalf("VerifyNode() = %v, %v", ok, err)
+		}
+		for i, subProof := range subtree.Proofs {
+			proof, err := AppendProof(subProof, *mustProveNode(t, m, level, subtreeIdx))
+			if err != nil {
+				t.Fatalf("AppendProof() error = %v", err)
+			}
+			want, err := m.Proof(blocks[subtreeIdx<<level+i])
+			if err != nil {
+				t.Fatalf("Proof() error = %v", err)
+			}
+			if !reflect.DeepEqual(proof, want) {
+				t.Fatalf("proof mismatch, got %v, want %v", proof, want)
+			}
+		}
+	}
+}
+
+func mustProveNode(t *testing.T, m *MerkleTree, level, idx int) *Proof {
+	t.Helper()
+	proof, err := m.ProveNode(level, idx)
+	if err != nil {
+		t.Fatalf("ProveNode() error = %v", err)
+	}
+	return proof
+}
+
+func TestMerkleTree_ProveNode_errors(t *testing.T) {
+	m, err := New(&Config{Mode: ModeTreeBuild}, generatedTestDataBlocks(5))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err = m.ProveNode(m.Depth, 0); !errors.Is(err, ErrInvalidNodeLevel) {
+		t.Errorf("ProveNode() error = %v, want %v", err, ErrInvalidNodeLevel)
+	}
+	if _, err = m.ProveNode(1, 4); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("ProveNode() error = %v, want %v", err, ErrNodeNotFound)
+	}
+	if _, err = m.VerifyNode(nil, 0, &Proof{}); !errors.Is(err, ErrDataBlockIsNil) {
+		t.Errorf("VerifyNode() error = %v, want %v", err, ErrDataBlockIsNil)
+	}
+	if _, err = m.VerifyNode([]byte{}, 0, nil); !errors.Is(err, ErrProofIsNil) {
+		t.Errorf("VerifyNode() error = %v, want %v", err, ErrProofIsNil)
+	}
+
+	m, err = New(nil, generatedTestDataBlocks(5))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err = m.ProveNode(0, 0); !errors.Is(err, ErrProofInvalidModeTreeNotBuilt) {
+		t.Errorf("ProveNode() error = %v, want %v", err, ErrProofInvalidModeTreeNotBuilt)
+	}
+}
@@ -0,0 +1,213 @@
+package merkletree
+
+import "sort"
+
+// NodeStore is the storage of the Merkle Tree nodes, which are addressed by their level and index.
+// Level 0 holds the leaves, and each level holds an even number of nodes after the odd node handling.
+// The root is not stored.
+type NodeStore interface {
+	// Get returns the node at the given level and index.
+	// It returns ErrNodeNotFound for an index which was skipped by a Put beyond the end of the level.
+	// The returned slice must not be modified by the caller.
+	Get(level, idx int) ([]byte, error)
+	// Put stores the node at the given level and index, extending the level if needed.
+	Put(level, idx int, node []byte) error
+	// Len returns the number of nodes stored at the given level.
+	Len(level int) int
+}
+
+// MemoryNodeStore is the NodeStore keeping the nodes in memory. It is the default NodeStore.
+type MemoryNodeStore struct {
+	levels [][][]byte
+}
+
+// NewMemoryNodeStore creates an empty MemoryNodeStore.
+func NewMemoryNodeStore() *MemoryNodeStore {
+	return new(MemoryNodeStore)
+}
+
+// Get returns the node at the given level and index.
+func (s *MemoryNodeStore) Get(level, idx int) ([]byte, error) {
+	if level < 0 || level >= len(s.levels) || idx < 0 || idx >= len(s.levels[level]) {
+		return nil, ErrNodeNotFound
+	}
+	// The skipped nodes are nil.
+	if s.levels[level][idx] == nil {
+		return nil, ErrNodeNotFound
+	}
+	return s.levels[level][idx], nil
+}
+
+// Put stores the node at the given level and index, extending the level if needed.
+func (s *MemoryNodeStore) Put(level, idx int, node []byte) error {
+	if level < 0 || idx < 0 {
+		return ErrNodeNotFound
+	}
+	for len(s.levels) <= level {
+		s.levels = append(s.levels, nil)
+	}
+	for len(s.levels[level]) <= idx {
+		s.levels[level] = append(s.levels[level], nil)
+	}
+	s.levels[level][idx] = node
+	return nil
+}
+
+// Len returns the number of nodes stored at the given level.
+func (s *MemoryNodeStore) Len(level int) int {
+	if level < 0 || level >= len(s.levels) {
+		return 0
+	}
+	return len(s.levels[level])
+}
+
+// setLevel replaces all the nodes at the given level without copying them.
+func (s *MemoryNodeStore) setLevel(level int, nodes [][]byte) {
+	for len(s.levels) <= level {
+		s.levels = append(s.levels, nil)
+	}
+	s.levels[level] = nodes
+}
+
+// putLevel stores all the nodes of a level in the node store.
+func putLevel(store NodeStore, level int, nodes [][]byte) error {
+	if memStore, ok := store.(*MemoryNodeStore); ok {
+		memStore.setLevel(level, nodes)
+		return nil
+	}
+	for i, node := range nodes {
+		if err := store.Put(level, i, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getLevel returns all the nodes of a level in the node store.
+// The nodes of a MemoryNodeStore are returned without copying, so they must not be modified.
+func getLevel(store NodeStore, level int) ([][]byte, error) {
+	if memStore, ok := store.(*MemoryNodeStore); ok {
+		if level < 0 || level >= len(memStore.levels) {
+			return nil, ErrNodeNotFound
+		}
+		return memStore.levels[level], nil
+	}
+	nodes := make([][]byte, store.Len(level))
+	for i := range nodes {
+		var err error
+		if nodes[i], err = store.Get(level, i); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// FlatNodeStore is the NodeStore keeping each tree level in one contiguous byte slice of fixed-width nodes.
+// It is the default NodeStore if ContiguousNodes in Config is true.
+type FlatNodeStore struct {
+	width  int
+	levels [][]byte
+	// holes are the skipped nodes of each level, which are zero bytes in the level slice.
+	holes []nodeHoles
+}
+
+// NewFlatNodeStore creates an empty FlatNodeStore for nodes of width bytes.
+func NewFlatNodeStore(width int) *FlatNodeStore {
+	return &FlatNodeStore{width: width}
+}
+
+// Get returns the node at the given level and index, which is a view into the level slice.
+func (s *FlatNodeStore) Get(level, idx int) ([]byte, error) {
+	if level < 0 || level >= len(s.levels) || idx < 0 || idx >= s.Len(level) {
+		return nil, ErrNodeNotFound
+	}
+	if level < len(s.holes) && s.holes[level].contains(idx) {
+		return nil, ErrNodeNotFound
+	}
+	return s.levels[level][idx*s.width : (idx+1)*s.width : (idx+1)*s.width], nil
+}
+
+// Put stores the node at the given level and index, extending the level if needed.
+func (s *FlatNodeStore) Put(level, idx int, node []byte) error {
+	if len(node) != s.width {
+		return ErrInvalidNodeSize
+	}
+	if level < 0 || idx < 0 {
+		return ErrNodeNotFound
+	}
+	for len(s.levels) <= level {
+		s.levels = append(s.levels, nil)
+		s.holes = append(s.holes, nil)
+	}
+	if length := s.Len(level); idx > length {
+		s.holes[level].add(length, idx)
+	} else if idx < length {
+		s.holes[level].fill(idx)
+	}
+	if end := (idx + 1) * s.width; end > len(s.levels[level]) {
+		s.levels[level] = append(s.levels[level], make([]byte, end-len(s.levels[level]))...)
+	}
+	copy(s.levels[level][idx*s.width:], node)
+	return nil
+}
+
+// Len returns the number of nodes stored at the given level.
+func (s *FlatNodeStore) Len(level int) int {
+	if level < 0 || level >= len(s.levels) {
+		return 0
+	}
+	return len(s.levels[level]) / s.width
+}
+
+// setLevel replaces all the nodes at the given level without copying them.
+func (s *FlatNodeStore) setLevel(level int, data []byte) {
+	for len(s.levels) <= level {
+		s.levels = append(s.levels, nil)
+		s.holes = append(s.holes, nil)
+	}
+	s.levels[level] = data
+	s.holes[level] = nil
+}
+
+// nodeHoles are the ranges [start, end) of the indices skipped by the Puts beyond the end of a level,
+// in ascending order. The stores of fixed-width nodes keep them to tell the skipped nodes from the written ones.
+type nodeHoles [][2]int
+
+// add adds the range of the skipped indices, which is after all the other ranges.
+func (h *nodeHoles) add(start, end int) {
+	*h = append(*h, [2]int{start, end})
+}
+
+// fill removes the written index from its range.
+func (h *nodeHoles) fill(idx int) {
+	i := h.search(idx)
+	if i < 0 {
+		return
+	}
+	start, end := (*h)[i][0], (*h)[i][1]
+	switch {
+	case start == idx && end == idx+1:
+		*h = append((*h)[:i], (*h)[i+1:]...)
+	case start == idx:
+		(*h)[i][0]++
+	case end == idx+1:
+		(*h)[i][1]--
+	default:
+		*h = append((*h)[:i+1], (*h)[i:]...)
+		(*h)[i][1], (*h)[i+1][0] = idx, idx+1
+	}
+}
+
+// contains reports whether the index is skipped.
+func (h nodeHoles) contains(idx int) bool {
+	return h.search(idx) >= 0
+}
+
+// search returns the position of the range containing the index, or -1 if the index is not skipped.
+func (h nodeHoles) search(idx int) int {
+	i := sort.Search(len(h), func(i int) bool { return h[i][1] > idx })
+	if i < len(h) && h[i][0] <= idx {
+		return i
+	}
+	return -1
+}
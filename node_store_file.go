@@ -0,0 +1,287 @@
+package merkletree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileNodeStoreLevel is a tree level of FileNodeStore.
+type fileNodeStoreLevel struct {
+	file *os.File
+	// writer buffers the nodes appended to the end of the level.
+	writer *bufio.Writer
+	// length is the number of nodes in the level, including the buffered ones.
+	length int
+	// data is the memory-mapped file content, which is only available for stores opened by OpenFileNodeStore.
+	data []byte
+	// holes are the skipped nodes, which read back as zero bytes from the file.
+	holes nodeHoles
+}
+
+// FileNodeStore is the NodeStore keeping the nodes in files, one file per tree level.
+// All the nodes must have the same size, so that a node is located by its index.
+// The ranges of the nodes skipped by a Put beyond the end of a level are kept in another file per level.
+// A tree can be built once into a FileNodeStore created by NewFileNodeStore, and the proofs
+// can later be served from the memory-mapped files by a FileNodeStore opened with OpenFileNodeStore.
+type FileNodeStore struct {
+	dir      string
+	nodeSize int
+	readOnly bool
+	levels   []*fileNodeStoreLevel
+	// mu protects the levels and the buffered writers.
+	mu sync.Mutex
+}
+
+// NewFileNodeStore creates a writable FileNodeStore in the directory for nodes of nodeSize bytes.
+// The directory is created if it does not exist, and the level files in it are truncated when
+// they are first written.
+func NewFileNodeStore(dir string, nodeSize int) (*FileNodeStore, error) {
+	if nodeSize <= 0 {
+		return nil, ErrInvalidNodeSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileNodeStore{
+		dir:      dir,
+		nodeSize: nodeSize,
+	}, nil
+}
+
+// OpenFileNodeStore opens a read-only FileNodeStore previously written to the directory.
+// The level files are memory-mapped if it is supported by the platform, otherwise the nodes
+// are read from the files on demand.
+func OpenFileNodeStore(dir string, nodeSize int) (*FileNodeStore, error) {
+	if nodeSize <= 0 {
+		return nil, ErrInvalidNodeSize
+	}
+	s := &FileNodeStore{
+		dir:      dir,
+		nodeSize: nodeSize,
+		readOnly: true,
+	}
+	for level := 0; ; level++ {
+		file, err := os.Open(s.levelPath(level))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		l := &fileNodeStoreLevel{file: file}
+		s.levels = append(s.levels, l)
+		info, err := file.Stat()
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		if info.Size()%int64(nodeSize) != 0 {
+			s.Close()
+			return nil, ErrInvalidNodeSize
+		}
+		l.length = int(info.Size() / int64(nodeSize))
+		if l.holes, err = s.readHoles(level); err != nil {
+			s.Close()
+			return nil, err
+		}
+		if l.length > 0 {
+			// Fall back to reading from the file if memory mapping is not available.
+			l.data, _ = mmapFile(file, int(info.Size()))
+		}
+	}
+	if len(s.levels) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return s, nil
+}
+
+// levelPath returns the path of the file storing the given level.
+func (s *FileNodeStore) levelPath(level int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("level_%02d.bin", level))
+}
+
+// holesPath returns the path of the file storing the skipped nodes of the given level.
+func (s *FileNodeStore) holesPath(level int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("level_%02d.holes", level))
+}
+
+// readHoles reads the skipped nodes of the level, which are the big-endian uint64 pairs of the ranges.
+func (s *FileNodeStore) readHoles(level int) (nodeHoles, error) {
+	data, err := os.ReadFile(s.holesPath(level))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%16 != 0 {
+		return nil, ErrInvalidNodeSize
+	}
+	holes := make(nodeHoles, len(data)/16)
+	for i := range holes {
+		holes[i][0] = int(binary.BigEndian.Uint64(data[i*16:]))
+		holes[i][1] = int(binary.BigEndian.Uint64(data[i*16+8:]))
+	}
+	return holes, nil
+}
+
+// writeHoles writes the skipped nodes of the levels, and removes the files of the levels without them.
+func (s *FileNodeStore) writeHoles() error {
+	for level, l := range s.levels {
+		if len(l.holes) == 0 {
+			if err := os.Remove(s.holesPath(level)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		data := make([]byte, 0, len(l.holes)*16)
+		for _, hole := range l.holes {
+			data = binary.BigEndian.AppendUint64(data, uint64(hole[0]))
+			data = binary.BigEndian.AppendUint64(data, uint64(hole[1]))
+		}
+		if err := os.WriteFile(s.holesPath(level), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns a copy of the node at the given level and index.
+func (s *FileNodeStore) Get(level, idx int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if level < 0 || level >= len(s.levels) || idx < 0 || idx >= s.levels[level].length {
+		return nil, ErrNodeNotFound
+	}
+	l := s.levels[level]
+	if l.holes.contains(idx) {
+		return nil, ErrNodeNotFound
+	}
+	node := make([]byte, s.nodeSize)
+	if l.data != nil {
+		copy(node, l.data[idx*s.nodeSize:])
+		return node, nil
+	}
+	if l.writer != nil && l.writer.Buffered() > 0 {
+		if err := l.writer.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := l.file.ReadAt(node, int64(idx)*int64(s.nodeSize)); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Put stores the node at the given level and index, extending the level if needed.
+// The nodes appended to the end of a level are buffered, call Flush or Close to write them to the files.
+func (s *FileNodeStore) Put(level, idx int, node []byte) error {
+	if s.readOnly {
+		return ErrNodeStoreReadOnly
+	}
+	if len(node) != s.nodeSize {
+		return ErrInvalidNodeSize
+	}
+	if level < 0 || idx < 0 {
+		return ErrNodeNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.levels) <= level {
+		file, err := os.Create(s.levelPath(len(s.levels)))
+		if err != nil {
+			return err
+		}
+		s.levels = append(s.levels, &fileNodeStoreLevel{
+			file:   file,
+			writer: bufio.NewWriter(file),
+		})
+	}
+	l := s.levels[level]
+	if idx > l.length {
+		l.holes.add(l.length, idx)
+	} else if idx < l.length {
+		l.holes.fill(idx)
+	}
+	if idx == l.length {
+		if _, err := l.writer.Write(node); err != nil {
+			return err
+		}
+		l.length++
+		return nil
+	}
+
+	// Overwrite an existing node or leave a gap, then move the file offset back to the end of the level.
+	if err := l.writer.Flush(); err != nil {
+		return err
+	}
+	if _, err := l.file.WriteAt(node, int64(idx)*int64(s.nodeSize)); err != nil {
+		return err
+	}
+	if idx > l.length {
+		l.length = idx + 1
+	}
+	_, err := l.file.Seek(int64(l.length)*int64(s.nodeSize), io.SeekStart)
+	return err
+}
+
+// Len returns the number of nodes stored at the given level.
+func (s *FileNodeStore) Len(level int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if level < 0 || level >= len(s.levels) {
+		return 0
+	}
+	return s.levels[level].length
+}
+
+// Flush writes the buffered nodes and the skipped nodes to the files.
+func (s *FileNodeStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readOnly {
+		return nil
+	}
+	for _, l := range s.levels {
+		if err := l.writer.Flush(); err != nil {
+			return err
+		}
+	}
+	return s.writeHoles()
+}
+
+// Close flushes the buffered nodes, unmaps the memory-mapped files and closes the files.
+// The nodes returned by Get remain valid after Close.
+func (s *FileNodeStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	if !s.readOnly {
+		firstErr = s.writeHoles()
+	}
+	for _, l := range s.levels {
+		if l.writer != nil {
+			if err := l.writer.Flush(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if l.data != nil {
+			if err := munmapFile(l.data); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			l.data = nil
+		}
+		if err := l.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.levels = nil
+	return firstErr
+}
@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package merkletree
+
+import (
+	"errors"
+	"os"
+)
+
+// errMmapUnsupported is the error for memory mapping on unsupported platforms.
+var errMmapUnsupported = errors.New("memory mapping is not supported on this platform")
+
+// mmapFile is not supported on this platform, the nodes are read from the file instead.
+func mmapFile(*os.File, int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+// munmapFile is not supported on this platform.
+func munmapFile([]byte) error {
+	return errMmapUnsupported
+}
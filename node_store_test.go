@@ -0,0 +1,278 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryNodeStore(t *testing.T) {
+	s := NewMemoryNodeStore()
+	if err := s.Put(1, 2, []byte("node_1_2")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if got := s.Len(1); got != 3 {
+		t.Errorf("Len() = %d, want 3", got)
+	}
+	if got := s.Len(0); got != 0 {
+		t.Errorf("Len() = %d, want 0", got)
+	}
+	got, err := s.Get(1, 2)
+	if err != nil || !bytes.Equal(got, []byte("node_1_2")) {
+		t.Errorf("Get() = %s, %v", got, err)
+	}
+	if _, err := s.Get(1, 3); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, ErrNodeNotFound)
+	}
+	if err := s.Put(-1, 0, nil); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Put() error = %v, want %v", err, ErrNodeNotFound)
+	}
+}
+
+func TestNodeStore_skippedNodes(t *testing.T) {
+	for _, s := range []NodeStore{NewMemoryNodeStore(), NewFlatNodeStore(4)} {
+		// The level 0 is [hole, 1, hole, hole, 4, hole, 6] after the Puts.
+		for _, idx := range []int{6, 1, 4} {
+			if err := s.Put(0, idx, []byte{byte(idx), 0, 0, 0}); err != nil {
+				t.Fatalf("%T Put() error = %v", s, err)
+			}
+		}
+		if got := s.Len(0); got != 7 {
+			t.Errorf("%T Len() = %d, want 7", s, got)
+		}
+		for idx := 0; idx < 7; idx++ {
+			got, err := s.Get(0, idx)
+			if idx == 1 || idx == 4 || idx == 6 {
+				if err != nil || got[0] != byte(idx) {
+					t.Errorf("%T Get(0, %d) = %v, %v", s, idx, got, err)
+				}
+				continue
+			}
+			if !errors.Is(err, ErrNodeNotFound) {
+				t.Errorf("%T Get(0, %d) error = %v, want %v", s, idx, err, ErrNodeNotFound)
+			}
+		}
+	}
+}
+
+func TestNodeHoles(t *testing.T) {
+	var h nodeHoles
+	h.add(0, 5)
+	h.add(7, 9)
+	for _, idx := range []int{2, 0, 4, 7, 8, 5} {
+		h.fill(idx)
+	}
+	want := nodeHoles{{1, 2}, {3, 4}}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("holes = %v, want %v", h, want)
+	}
+	for idx, want := range []bool{false, true, false, true, false, false} {
+		if got := h.contains(idx); got != want {
+			t.Errorf("contains(%d) = %v, want %v", idx, got, want)
+		}
+	}
+}
+
+func TestFileNodeStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileNodeStore(dir, 4)
+	if err != nil {
+		t.Fatalf("NewFileNodeStore() error = %v", err)
+	}
+	nodes := [][]byte{[]byte("aaaa"), []byte("bbbb"), []byte("cccc")}
+	for i, node := range nodes {
+		if err := s.Put(0, i, node); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	// Overwrite an existing node and leave a gap in another level.
+	if err := s.Put(0, 1, []byte("dddd")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Put(1, 2, []byte("eeee")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Put(1, 3, []byte("ffff")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Put(1, 6, []byte("hhhh")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Put(1, 4, []byte("iiii")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := s.Get(1, 5); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Get() of a skipped node error = %v, want %v", err, ErrNodeNotFound)
+	}
+	if err := s.Put(0, 3, []byte("too_long")); !errors.Is(err, ErrInvalidNodeSize) {
+		t.Errorf("Put() error = %v, want %v", err, ErrInvalidNodeSize)
+	}
+	if got, err := s.Get(0, 1); err != nil || !bytes.Equal(got, []byte("dddd")) {
+		t.Errorf("Get() = %s, %v", got, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	s, err = OpenFileNodeStore(dir, 4)
+	if err != nil {
+		t.Fatalf("OpenFileNodeStore() error = %v", err)
+	}
+	defer s.Close()
+	// The skipped nodes are empty, and they are not found after reopening the store.
+	want := [][]string{{"aaaa", "dddd", "cccc"}, {"", "", "eeee", "ffff", "iiii", "", "hhhh"}}
+	for level := range want {
+		if got := s.Len(level); got != len(want[level]) {
+			t.Fatalf("Len(%d) = %d, want %d", level, got, len(want[level]))
+		}
+		for idx := range want[level] {
+			got, err := s.Get(level, idx)
+			if want[level][idx] == "" {
+				if !errors.Is(err, ErrNodeNotFound) {
+					t.Errorf("Get(%d, %d) error = %v, want %v", level, idx, err, ErrNodeNotFound)
+				}
+				continue
+			}
+			if err != nil || string(got) != want[level][idx] {
+				t.Errorf("Get(%d, %d) = %q, %v, want %q", level, idx, got, err, want[level][idx])
+			}
+		}
+	}
+	if _, err := s.Get(2, 0); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, ErrNodeNotFound)
+	}
+	if err := s.Put(0, 0, []byte("gggg")); !errors.Is(err, ErrNodeStoreReadOnly) {
+		t.Errorf("Put() error = %v, want %v", err, ErrNodeStoreReadOnly)
+	}
+	if _, err := OpenFileNodeStore(t.TempDir(), 4); err == nil {
+		t.Errorf("OpenFileNodeStore() of an empty directory error = nil, want error")
+	}
+}
+
+func TestNewFromNodeStore(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		blocks []DataBlock
+	}{
+		{
+			name:   "test_2",
+			config: &Config{},
+			blocks: generatedTestDataBlocks(2),
+		},
+		{
+			name:   "test_1001",
+			config: &Config{},
+			blocks: generatedTestDataBlocks(1001),
+		},
+		{
+			name:   "test_1001_parallel",
+			config: &Config{RunInParallel: true, NumRoutines: 4},
+			blocks: generatedTestDataBlocks(1001),
+		},
+		{
+			name:   "test_commp",
+			config: NewCommPConfig(false),
+			blocks: zeroPieceBlocks(100 * 32),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := NewFileNodeStore(dir, 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := *tt.config
+			config.Mode = ModeProofGenAndTreeBuild
+			built, err := NewWithNodeStore(&config, tt.blocks, store)
+			if err != nil {
+				t.Fatalf("NewWithNodeStore() error = %v", err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err = OpenFileNodeStore(dir, 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			m, err := NewFromNodeStore(tt.config, store, len(tt.blocks))
+			if err != nil {
+				t.Fatalf("NewFromNodeStore() error = %v", err)
+			}
+			if !bytes.Equal(m.Root, built.Root) {
+				t.Fatalf("root mismatch, got %x, want %x", m.Root, built.Root)
+			}
+			for i, block := range tt.blocks {
+				proof, err := m.Proof(block)
+				if err != nil {
+					t.Fatalf("Proof() error = %v", err)
+				}
+				if ok, err := m.Verify(block, proof); err != nil || !ok {
+					t.Fatalf("proof %d verification failed, error = %v", i, err)
+				}
+				// The zero piece has identical leaves, so only the last proof matches.
+				if tt.name != "test_commp" && !reflect.DeepEqual(proof, built.Proofs[i]) {
+					t.Fatalf("Proof() %d got = %v, want %v", i, proof, built.Proofs[i])
+				}
+			}
+		})
+	}
+	if _, err := NewFromNodeStore(nil, NewMemoryNodeStore(), 5); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("NewFromNodeStore() error = %v, want %v", err, ErrNodeNotFound)
+	}
+	if _, err := NewFromNodeStore(nil, nil, 5); !errors.Is(err, ErrNodeStoreIsNil) {
+		t.Errorf("NewFromNodeStore() error = %v, want %v", err, ErrNodeStoreIsNil)
+	}
+	if _, err := NewWithNodeStore(nil, generatedTestDataBlocks(5), nil); !errors.Is(err, ErrNodeStoreIsNil) {
+		t.Errorf("NewWithNodeStore() error = %v, want %v", err, ErrNodeStoreIsNil)
+	}
+}
+
+func TestNewFromNodeStore_appendUpdate(t *testing.T) {
+	blocks := generatedTestDataBlocks(11)
+	for _, config := range []Config{{}, {Duplicates: true}, {ZeroPadding: true}, {RFC6962: true}} {
+		config.Mode = ModeTreeBuild
+		store := NewMemoryNodeStore()
+		if _, err := NewWithNodeStore(&config, blocks[:5], store); err != nil {
+			t.Fatalf("NewWithNodeStore() error = %v", err)
+		}
+		m, err := NewFromNodeStore(&config, store, 5)
+		if err != nil {
+			t.Fatalf("NewFromNodeStore() error = %v", err)
+		}
+		// The restored tree pads the odd nodes in the same way as the built one.
+		if err = m.Append(blocks[5:]...); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		want, err := New(&config, blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if !bytes.Equal(m.Root, want.Root) {
+			t.Fatalf("%+v: root after Append() = %x, want %x", config, m.Root, want.Root)
+		}
+
+		store = NewMemoryNodeStore()
+		if _, err = NewWithNodeStore(&config, blocks[:5], store); err != nil {
+			t.Fatalf("NewWithNodeStore() error = %v", err)
+		}
+		if m, err = NewFromNodeStore(&config, store, 5); err != nil {
+			t.Fatalf("NewFromNodeStore() error = %v", err)
+		}
+		if err = m.Update(3, blocks[10]); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		updated := append([]DataBlock(nil), blocks[:5]...)
+		updated[3] = blocks[10]
+		if want, err = New(&config, updated); err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if !bytes.Equal(m.Root, want.Root) {
+			t.Fatalf("%+v: root after Update() = %x, want %x", config, m.Root, want.Root)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package merkletree
+
+import (
+	"reflect"
+	"sync"
+)
+
+// zeroPaddingKey identifies a cached zero padding table.
+type zeroPaddingKey struct {
+	hashName           string
+	disableLeafHashing bool
+	arity              int
+}
+
+// zeroPaddingCache caches the zero padding tables computed by ZeroPaddingTable.
+var zeroPaddingCache sync.Map
+
+// builtinHashFuncNames maps the code pointers of the built-in hash functions to their names.
+// Hash functions are not comparable in Go, but the built-in ones are top-level functions without state,
+// so their code pointers identify them. Closures sharing the same code may capture different states,
+// so their code pointers cannot be used as cache keys.
+var builtinHashFuncNames = map[uintptr]string{
+	reflect.ValueOf(DefaultHashFunc).Pointer():                HashNameSHA256,
+	reflect.ValueOf(DefaultHashFuncParallel).Pointer():        HashNameSHA256,
+	reflect.ValueOf(Keccak256HashFunc).Pointer():              HashNameKeccak256,
+	reflect.ValueOf(Keccak256HashFuncParallel).Pointer():      HashNameKeccak256,
+	reflect.ValueOf(SHA256Trunc254HashFunc).Pointer():         HashNameSHA256Trunc254,
+	reflect.ValueOf(SHA256Trunc254HashFuncParallel).Pointer(): HashNameSHA256Trunc254,
+	reflect.ValueOf(PoseidonHashFunc).Pointer():               HashNamePoseidonBN254,
+	reflect.ValueOf(BLAKE2b256HashFunc).Pointer():             HashNameBLAKE2b256,
+	reflect.ValueOf(BLAKE2s256HashFunc).Pointer():             HashNameBLAKE2s256,
+	reflect.ValueOf(BLAKE3HashFunc).Pointer():                 HashNameBLAKE3,
+}
+
+// hashCacheKey returns the key of the hash function of the configuration in the caches of the tables
+// derived from it. The key is the HashName if the hash function is the registered one, as a name is only
+// registered once. It returns false if the hash function is neither a registered nor a built-in one,
+// whose tables are not cached, or if it does not match the HashName.
+func hashCacheKey(config *Config) (string, bool) {
+	if config == nil || config.HashFunc == nil && config.HashName == "" {
+		return HashNameSHA256, true
+	}
+	if checkHashName(config) != nil {
+		return "", false
+	}
+	if config.HashName != "" {
+		hashFunc, err := HashFuncByName(config.HashName)
+		if err == nil && (config.HashFunc == nil ||
+			reflect.ValueOf(config.HashFunc).Pointer() == reflect.ValueOf(hashFunc).Pointer()) {
+			return config.HashName, true
+		}
+	}
+	if config.HashFunc == nil {
+		return "", false
+	}
+	name, ok := builtinHashFuncNames[reflect.ValueOf(config.HashFunc).Pointer()]
+	return name, ok
+}
+
+// ZeroPaddingTable derives the padding table used for odd nodes from the configured hash function.
+// The entry at level 0 is the leaf of an all-zero data block whose size is the hash size, i.e. the zero
+// bytes themselves if DisableLeafHashing is true and their hash otherwise. The entry at level i is the hash
+// of two entries at level i-1, or as many as the Arity for a tree of a higher arity, i.e. the root of
+// an all-zero subtree of height i.
+// For Filecoin piece trees this is the table of zero piece commitments.
+// The tables of the built-in and the registered hash functions are cached, while those of the other hash
+// functions, e.g. closures which may capture different states, are computed on each call.
+func ZeroPaddingTable(config *Config) ([MaxDepth][]byte, error) {
+	var table [MaxDepth][]byte
+	if config == nil {
+		config = new(Config)
+	}
+	hashFunc := copyConfig(config).HashFunc
+	hashName, cacheable := hashCacheKey(config)
+	key := zeroPaddingKey{
+		hashName:           hashName,
+		disableLeafHashing: config.DisableLeafHashing,
+		arity:              arityOf(config),
+	}
+	if cached, ok := zeroPaddingCache.Load(key); cacheable && ok {
+		return cached.([MaxDepth][]byte), nil
+	}
+
+	// The size of the zero data block is the hash size.
+	size, err := hashSize(hashFunc)
+	if err != nil {
+		return table, err
+	}
+	zeroLeaf := make([]byte, size)
+	if !config.DisableLeafHashing {
+		if zeroLeaf, err = hashFunc(zeroLeaf); err != nil {
+			return table, err
+		}
+	}
+	table[0] = zeroLeaf
+	group := make([][]byte, key.arity)
+	for i := 1; i < int(MaxDepth); i++ {
+		for j := range group {
+			group[j] = table[i-1]
+		}
+		if table[i], err = hashFunc(concatGroup(group)); err != nil {
+			return table, err
+		}
+	}
+	if cacheable {
+		zeroPaddingCache.Store(key, table)
+	}
+	return table, nil
+}
@@ -0,0 +1,234 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+func TestZeroPaddingTable(t *testing.T) {
+	table, err := ZeroPaddingTable(NewCommPConfig(false))
+	if err != nil {
+		t.Fatalf("ZeroPaddingTable() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		level    int
+		pieceCID string
+	}{
+		{
+			name:     "zero_piece_2KiB",
+			level:    6,
+			pieceCID: "baga6ea4seaqpy7usqklokfx2vxuynmupslkeutzexe2uqurdg5vhtebhxqmpqmy",
+		},
+		{
+			name:     "zero_piece_512MiB",
+			level:    24,
+			pieceCID: "baga6ea4seaqdsvqopmj2soyhujb72jza76t4wpq5fzifvm3ctz47iyytkewnubq",
+		},
+		{
+			name:     "zero_piece_32GiB",
+			level:    30,
+			pieceCID: "baga6ea4seaqao7s73y24kcutaosvacpdjgfe5pw76ooefnyqw4ynr3d2y6x2mpq",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want := pieceCIDToCommitment(t, tt.pieceCID); !bytes.Equal(table[tt.level], want) {
+				t.Errorf("level %d = %x, want %x", tt.level, table[tt.level], want)
+			}
+		})
+	}
+	// The 64 GiB zero piece is the root of the tree with two 32 GiB zero pieces.
+	root, err := SHA256Trunc254HashFunc(concatHash(table[30], table[30]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := pieceCIDToCommitment(t, "baga6ea4seaqomqafu276g53zko4k23xzh4h4uecjwicbmvhsuqi7o4bhthhm4aq")
+	if !bytes.Equal(root, want) {
+		t.Errorf("64GiB zero piece = %x, want %x", root, want)
+	}
+}
+
+func TestZeroPaddingTable_defaultHashFunc(t *testing.T) {
+	table, err := ZeroPaddingTable(nil)
+	if err != nil {
+		t.Fatalf("ZeroPaddingTable() error = %v", err)
+	}
+	zeroLeaf := sha256.Sum256(make([]byte, sha256.Size))
+	if !bytes.Equal(table[0], zeroLeaf[:]) {
+		t.Errorf("level 0 = %x, want %x", table[0], zeroLeaf)
+	}
+	for i := 1; i < int(MaxDepth); i++ {
+		want := sha256.Sum256(append(append([]byte{}, table[i-1]...), table[i-1]...))
+		if !bytes.Equal(table[i], want[:]) {
+			t.Fatalf("level %d = %x, want %x", i, table[i], want)
+		}
+	}
+	cached, err := ZeroPaddingTable(&Config{HashFunc: DefaultHashFunc})
+	if err != nil {
+		t.Fatalf("ZeroPaddingTable() error = %v", err)
+	}
+	if &cached[0][0] != &table[0][0] {
+		t.Errorf("zero padding table is not cached")
+	}
+}
+
+// prefixedHashFunc returns a closure hashing the data with the prefix, so that the closures of different
+// prefixes share the same code but are different hash functions.
+func prefixedHashFunc(prefix byte) TypeHashFunc {
+	return func(data []byte) ([]byte, error) {
+		return DefaultHashFuncParallel(append([]byte{prefix}, data...))
+	}
+}
+
+func TestZeroPaddingTable_closures(t *testing.T) {
+	first, err := ZeroPaddingTable(&Config{HashFunc: prefixedHashFunc(1)})
+	if err != nil {
+		t.Fatalf("ZeroPaddingTable() error = %v", err)
+	}
+	second, err := ZeroPaddingTable(&Config{HashFunc: prefixedHashFunc(2)})
+	if err != nil {
+		t.Fatalf("ZeroPaddingTable() error = %v", err)
+	}
+	if bytes.Equal(first[1], second[1]) {
+		t.Errorf("closures of different states share the zero padding table")
+	}
+	want, err := prefixedHashFunc(2)(append(append([]byte{}, second[0]...), second[0]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(second[1], want) {
+		t.Errorf("level 1 = %x, want %x", second[1], want)
+	}
+}
+
+func TestZeroPaddingTable_hashFuncError(t *testing.T) {
+	config := &Config{
+		HashFunc: func([]byte) ([]byte, error) {
+			return nil, errors.New("test_hash_func_err")
+		},
+	}
+	if _, err := ZeroPaddingTable(config); err == nil {
+		t.Errorf("ZeroPaddingTable() error = nil, want error")
+	}
+}
+
+func TestMerkleTreeNew_zeroPadding(t *testing.T) {
+	blocks := zeroPieceBlocks(8 * 32)
+	random := generatedTestDataBlocks(5)
+	for i := 0; i < 5; i++ {
+		data, _ := random[i].Serialize()
+		blocks[i] = BytesBlock(data[:32])
+	}
+	full, err := New(NewCommPConfig(false), blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		config *Config
+	}{
+		{
+			name:   "test_proof_gen",
+			config: NewCommPConfig(false),
+		},
+		{
+			name:   "test_proof_gen_parallel",
+			config: NewCommPConfig(true),
+		},
+		{
+			name: "test_tree_build",
+			config: func() *Config {
+				config := NewCommPConfig(false)
+				config.Mode = ModeProofGenAndTreeBuild
+				return config
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.config, blocks[:5])
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if !bytes.Equal(m.Root, full.Root) {
+				t.Errorf("root mismatch, got %x, want %x", m.Root, full.Root)
+			}
+			for i := 0; i < 5; i++ {
+				if ok, err := m.Verify(blocks[i], m.Proofs[i]); err != nil || !ok {
+					t.Errorf("proof %d verification failed, error = %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewWithPadding_concurrent(t *testing.T) {
+	blocks := generatedTestDataBlocks(5)
+	zeroPadding, err := ZeroPaddingTable(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var customPadding [MaxDepth][]byte
+	for i := range customPadding {
+		customPadding[i] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	paddings := [][MaxDepth][]byte{zeroPadding, customPadding, {}}
+	wantRoots := make([][]byte, len(paddings))
+	for i, padding := range paddings {
+		m, err := NewWithPadding(&Config{Mode: ModeTreeBuild}, blocks, padding)
+		if err != nil {
+			t.Fatalf("NewWithPadding() error = %v", err)
+		}
+		wantRoots[i] = m.Root
+	}
+	if bytes.Equal(wantRoots[0], wantRoots[1]) || bytes.Equal(wantRoots[1], wantRoots[2]) {
+		t.Fatalf("different padding tables produce the same root")
+	}
+	duplicated, err := New(&Config{Duplicates: true}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !bytes.Equal(wantRoots[2], duplicated.Root) {
+		t.Errorf("empty padding table does not duplicate the odd nodes")
+	}
+
+	const numRoutines = 32
+	errs := make(chan error, numRoutines)
+	for i := 0; i < numRoutines; i++ {
+		go func(i int) {
+			idx := i % len(paddings)
+			// DefaultHashFunc is not concurrent-safe.
+			config := &Config{
+				HashFunc: DefaultHashFuncParallel,
+				Mode:     ModeTreeBuild,
+			}
+			m, err := NewWithPadding(config, blocks, paddings[idx])
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(m.Root, wantRoots[idx]) {
+				errs <- errors.New("root mismatch")
+				return
+			}
+			proof, err := m.Proof(blocks[4])
+			if err != nil {
+				errs <- err
+				return
+			}
+			if ok, err := Verify(blocks[4], proof, m.Root, config); err != nil || !ok {
+				errs <- errors.New("proof verification failed")
+				return
+			}
+			errs <- nil
+		}(i)
+	}
+	for i := 0; i < numRoutines; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package merkletree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/constants"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+var (
+	// ErrInvalidPoseidonInput is the error for an input of PoseidonHashFunc which is not 1 to 16 field elements
+	// of 32 bytes.
+	ErrInvalidPoseidonInput = errors.New("poseidon input must be 1 to 16 field elements of 32 bytes")
+	// ErrNotInPoseidonField is the error for a value which is not an element of the BN254 scalar field.
+	ErrNotInPoseidonField = errors.New("value is not in the BN254 scalar field")
+)
+
+const (
+	// PoseidonElementSize is the size of a BN254 scalar field element in the Poseidon encoding.
+	PoseidonElementSize = 32
+	// PoseidonMaxInputs is the largest number of field elements hashed by PoseidonHashFunc,
+	// which is the largest width of the circomlib parameters.
+	PoseidonMaxInputs = 16
+)
+
+// PoseidonHashFunc implements the Poseidon hash function over the BN254 scalar field with the circomlib
+// parameters. The data is split into 32-byte big-endian field elements, and the hash is the 32-byte
+// big-endian encoding of Poseidon(elements...), the same as the Poseidon template of circomlib
+// and the BN254 Poseidon of gnark with the circomlib constants.
+// The nodes of a binary tree are Poseidon(left, right), and those of a tree of a higher arity are
+// Poseidon of the whole sibling group, so the arity can be at most 16.
+// The leaves must be encoded as field elements, e.g. by PoseidonDataBlock, or the leaf hashing
+// can be disabled to use the field elements as the leaves.
+// It does not keep any state, so it is safe for concurrent use.
+func PoseidonHashFunc(data []byte) ([]byte, error) {
+	elements, err := PoseidonFieldElements(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) == 0 || len(elements) > PoseidonMaxInputs {
+		return nil, fmt.Errorf("%w: %d elements", ErrInvalidPoseidonInput, len(elements))
+	}
+	hash, err := poseidon.Hash(elements)
+	if err != nil {
+		return nil, err
+	}
+	return hash.FillBytes(make([]byte, PoseidonElementSize)), nil
+}
+
+// PoseidonFieldElements maps the 32-byte chunks of the data to field elements, reading each chunk
+// as a big-endian integer. The length of the data must be a multiple of 32 bytes,
+// and every chunk must be less than the BN254 scalar field modulus.
+func PoseidonFieldElements(data []byte) ([]*big.Int, error) {
+	if len(data)%PoseidonElementSize != 0 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidPoseidonInput, len(data))
+	}
+	elements := make([]*big.Int, len(data)/PoseidonElementSize)
+	for i := range elements {
+		elements[i] = new(big.Int).SetBytes(data[i*PoseidonElementSize : (i+1)*PoseidonElementSize])
+		if elements[i].Cmp(constants.Q) >= 0 {
+			return nil, fmt.Errorf("%w: element %d", ErrNotInPoseidonField, i)
+		}
+	}
+	return elements, nil
+}
+
+// PoseidonEncode encodes the field elements as 32-byte big-endian chunks, the encoding read by
+// PoseidonFieldElements. The elements must be in the BN254 scalar field.
+func PoseidonEncode(elements ...*big.Int) ([]byte, error) {
+	encoded := make([]byte, len(elements)*PoseidonElementSize)
+	for i, element := range elements {
+		if element == nil || element.Sign() < 0 || element.Cmp(constants.Q) >= 0 {
+			return nil, fmt.Errorf("%w: element %d", ErrNotInPoseidonField, i)
+		}
+		element.FillBytes(encoded[i*PoseidonElementSize : (i+1)*PoseidonElementSize])
+	}
+	return encoded, nil
+}
+
+// PoseidonDataBlock is the DataBlock of field elements, which are serialized by PoseidonEncode.
+// With PoseidonHashFunc, its leaf is Poseidon(elements...) as computed in a circuit.
+type PoseidonDataBlock struct {
+	// Elements are the field elements of the leaf.
+	Elements []*big.Int
+}
+
+// NewPoseidonDataBlock creates a PoseidonDataBlock of the field elements.
+func NewPoseidonDataBlock(elements ...*big.Int) *PoseidonDataBlock {
+	return &PoseidonDataBlock{
+		Elements: elements,
+	}
+}
+
+// Serialize returns the 32-byte big-endian encoding of the field elements.
+func (b *PoseidonDataBlock) Serialize() ([]byte, error) {
+	return PoseidonEncode(b.Elements...)
+}
@@ -0,0 +1,223 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/constants"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+func TestPoseidonHashFunc(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements []*big.Int
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "test_one_input",
+			elements: []*big.Int{big.NewInt(1)},
+			want:     "18586133768512220936620570745912940619677854269274689475585506675881198879027",
+		},
+		{
+			name:     "test_two_inputs",
+			elements: []*big.Int{big.NewInt(1), big.NewInt(2)},
+			want:     "7853200120776062878684798364095072458815029376092732009249414926327459813530",
+		},
+		{
+			name:     "test_no_input",
+			elements: []*big.Int{},
+			wantErr:  ErrInvalidPoseidonInput,
+		},
+		{
+			name:     "test_too_many_inputs",
+			elements: make([]*big.Int, PoseidonMaxInputs+1),
+			wantErr:  ErrInvalidPoseidonInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.elements {
+				if tt.elements[i] == nil {
+					tt.elements[i] = big.NewInt(int64(i))
+				}
+			}
+			data, err := PoseidonEncode(tt.elements...)
+			if err != nil {
+				t.Fatalf("PoseidonEncode() error = %v", err)
+			}
+			got, err := PoseidonHashFunc(data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PoseidonHashFunc() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(got) != PoseidonElementSize || new(big.Int).SetBytes(got).String() != tt.want {
+				t.Errorf("PoseidonHashFunc() = %x, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := PoseidonHashFunc(make([]byte, 33)); !errors.Is(err, ErrInvalidPoseidonInput) {
+		t.Errorf("PoseidonHashFunc() error = %v, want %v", err, ErrInvalidPoseidonInput)
+	}
+	if _, err := PoseidonHashFunc(constants.Q.FillBytes(make([]byte, 32))); !errors.Is(err, ErrNotInPoseidonField) {
+		t.Errorf("PoseidonHashFunc() error = %v, want %v", err, ErrNotInPoseidonField)
+	}
+	if _, err := PoseidonEncode(big.NewInt(-1)); !errors.Is(err, ErrNotInPoseidonField) {
+		t.Errorf("PoseidonEncode() error = %v, want %v", err, ErrNotInPoseidonField)
+	}
+}
+
+func TestMerkleTree_poseidon(t *testing.T) {
+	blocks := make([]DataBlock, 8)
+	leaves := make([]*big.Int, len(blocks))
+	for i := range blocks {
+		blocks[i] = NewPoseidonDataBlock(big.NewInt(int64(i)), big.NewInt(int64(i*i)))
+		var err error
+		if leaves[i], err = poseidon.Hash([]*big.Int{big.NewInt(int64(i)), big.NewInt(int64(i * i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, arity := range []int{2, 8} {
+		// The root of the circuit: each node is Poseidon of its children.
+		level := leaves
+		for len(level) > 1 {
+			next := make([]*big.Int, len(level)/arity)
+			for i := range next {
+				var err error
+				if next[i], err = poseidon.Hash(level[i*arity : (i+1)*arity]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			level = next
+		}
+		want := level[0].FillBytes(make([]byte, PoseidonElementSize))
+
+		config := &Config{
+			HashFunc:      PoseidonHashFunc,
+			Arity:         arity,
+			RunInParallel: true,
+		}
+		m, err := New(config, blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if !bytes.Equal(m.Root, want) {
+			t.Fatalf("arity %d: root = %x, want %x", arity, m.Root, want)
+		}
+		for i, block := range blocks {
+			if ok, err := m.Verify(block, m.Proofs[i]); err != nil || !ok {
+				t.Fatalf("arity %d: Verify(%d) = %v, %v", arity, i, ok, err)
+			}
+		}
+	}
+}
+
+// poseidonTestBlocks returns the data blocks of single field elements.
+func poseidonTestBlocks(num int) []DataBlock {
+	blocks := make([]DataBlock, num)
+	for i := range blocks {
+		blocks[i] = NewPoseidonDataBlock(big.NewInt(int64(i + 1)))
+	}
+	return blocks
+}
+
+func TestZeroPaddingTable_poseidon(t *testing.T) {
+	table, err := ZeroPaddingTable(&Config{HashFunc: PoseidonHashFunc})
+	if err != nil {
+		t.Fatalf("ZeroPaddingTable() error = %v", err)
+	}
+	// The zero leaf is Poseidon(0), and each level is Poseidon of two entries of the level below.
+	want, err := poseidon.Hash([]*big.Int{big.NewInt(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if !bytes.Equal(table[i], want.FillBytes(make([]byte, PoseidonElementSize))) {
+			t.Fatalf("table[%d] = %x, want %x", i, table[i], want)
+		}
+		if want, err = poseidon.Hash([]*big.Int{want, want}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blocks := poseidonTestBlocks(5)
+	m, err := New(&Config{HashFunc: PoseidonHashFunc, ZeroPadding: true, Mode: ModeProofGenAndTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	padded := append(append([]DataBlock(nil), blocks...), make([]DataBlock, 3)...)
+	for i := len(blocks); i < len(padded); i++ {
+		padded[i] = NewPoseidonDataBlock(big.NewInt(0))
+	}
+	full, err := New(&Config{HashFunc: PoseidonHashFunc}, padded)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !bytes.Equal(m.Root, full.Root) {
+		t.Errorf("root = %x, want the root of the zero padded leaves %x", m.Root, full.Root)
+	}
+	if err = m.Append(poseidonTestBlocks(2)...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+}
+
+func TestSparseMerkleTree_poseidon(t *testing.T) {
+	tree, err := NewSparseMerkleTree(&Config{HashName: HashNamePoseidonBN254})
+	if err != nil {
+		t.Fatalf("NewSparseMerkleTree() error = %v", err)
+	}
+	key, _ := PoseidonEncode(big.NewInt(42))
+	value, _ := PoseidonEncode(big.NewInt(7))
+	if err = tree.Set(key, value); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	proof, err := tree.CompressedProof(key)
+	if err != nil {
+		t.Fatalf("CompressedProof() error = %v", err)
+	}
+	if ok, err := tree.VerifyMembership(key, value, proof); err != nil || !ok {
+		t.Errorf("VerifyMembership() = %v, %v", ok, err)
+	}
+	missing, _ := PoseidonEncode(big.NewInt(43))
+	if proof, err = tree.Proof(missing); err != nil {
+		t.Fatalf("Proof() error = %v", err)
+	}
+	if ok, err := tree.VerifyNonMembership(missing, proof); err != nil || !ok {
+		t.Errorf("VerifyNonMembership() = %v, %v", ok, err)
+	}
+}
+
+func TestMerkleTree_poseidonContiguous(t *testing.T) {
+	blocks := poseidonTestBlocks(9)
+	config := &Config{HashFunc: PoseidonHashFunc, Mode: ModeProofGenAndTreeBuild}
+	want, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	contiguous := *config
+	contiguous.ContiguousNodes = true
+	m, err := New(&contiguous, blocks[:6])
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err = m.Append(blocks[6:]...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if !bytes.Equal(m.Root, want.Root) {
+		t.Fatalf("root after Append() = %x, want %x", m.Root, want.Root)
+	}
+	if err = m.Update(2, blocks[0]); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	for i, block := range []DataBlock{blocks[0], blocks[1], blocks[0]} {
+		if ok, err := m.VerifyByIndex(block, i, m.Proofs[i]); err != nil || !ok {
+			t.Errorf("VerifyByIndex(%d) = %v, %v", i, ok, err)
+		}
+	}
+}
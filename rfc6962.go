@@ -0,0 +1,123 @@
+package merkletree
+
+import "bytes"
+
+const (
+	// rfc6962LeafPrefix is the domain separation prefix of the leaf hashes in RFC 6962.
+	rfc6962LeafPrefix = 0x00
+	// rfc6962NodePrefix is the domain separation prefix of the internal node hashes in RFC 6962.
+	rfc6962NodePrefix = 0x01
+)
+
+// checkRFC6962Config checks that the configuration has no option conflicting with RFC 6962 mode.
+func checkRFC6962Config(config *Config) error {
+	if config.Duplicates || config.Padding[0] != nil || config.ZeroPadding ||
+		config.SortSiblingPairs || config.ContiguousNodes {
+		return ErrInvalidRFC6962Config
+	}
+	return nil
+}
+
+// rfc6962Leaf returns the data prefixed for the leaf hash.
+func rfc6962Leaf(data []byte) []byte {
+	leaf := make([]byte, 1+len(data))
+	leaf[0] = rfc6962LeafPrefix
+	copy(leaf[1:], data)
+	return leaf
+}
+
+// concatRFC6962Node concatenates two hashes with the prefix for the internal node hash.
+func concatRFC6962Node(b1, b2 []byte) []byte {
+	result := make([]byte, 1+len(b1)+len(b2))
+	result[0] = rfc6962NodePrefix
+	copy(result[1:], b1)
+	copy(result[1+len(b1):], b2)
+	return result
+}
+
+// buildLevelsRFC6962 computes the levels in RFC 6962 mode and stores them in the node store.
+// Splitting the leaves at the largest power of two smaller than their number, as defined by RFC 6962,
+// is the same as pairing the nodes level by level and promoting the odd last node of a level unchanged.
+// If the proofs are initialized, they are generated from the node store.
+func (m *MerkleTree) buildLevelsRFC6962(store NodeStore) (err error) {
+	buffer := make([][]byte, m.NumLeaves)
+	copy(buffer, m.Leaves)
+	for i := 0; i < m.Depth; i++ {
+		if err = putLevel(store, i, buffer); err != nil {
+			return
+		}
+		if i == m.Depth-1 {
+			break
+		}
+		var nodes [][]byte
+		if nodes, err = m.computeTreeNodes(buffer, len(buffer)&^1); err != nil {
+			return
+		}
+		if len(buffer)&1 == 1 {
+			nodes = append(nodes, buffer[len(buffer)-1])
+		}
+		buffer = nodes
+	}
+	if m.Root, err = m.HashFunc(m.concatHashFunc(buffer[0], buffer[1])); err != nil {
+		return
+	}
+	if m.Proofs == nil {
+		return
+	}
+	for i := range m.Proofs {
+		if m.Proofs[i], err = m.nodeProofFrom(store, 0, i); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// generateProofsRFC6962 generates the proofs for each leaf in RFC 6962 mode.
+// The levels are kept in a temporary node store, which is released afterwards.
+func (m *MerkleTree) generateProofsRFC6962() error {
+	m.initProofs()
+	return m.buildLevelsRFC6962(NewMemoryNodeStore())
+}
+
+// VerifyRFC6962Inclusion checks the audit path of the data block at the leaf index in the tree of treeSize leaves
+// against the root, following the verification algorithm of RFC 9162. The path bits of the proof are not used,
+// as the position of each sibling is derived from the leaf index and the tree size.
+func VerifyRFC6962Inclusion(dataBlock DataBlock, idx, treeSize int, proof *Proof, root []byte, config *Config) (bool, error) {
+	if dataBlock == nil {
+		return false, ErrDataBlockIsNil
+	}
+	if proof == nil {
+		return false, ErrProofIsNil
+	}
+	if idx < 0 || idx >= treeSize {
+		return false, ErrLeafIndexOutOfRange
+	}
+	// Work on a copy so that the configuration shared by other goroutines is not modified.
+	config = copyConfig(config)
+	config.RFC6962 = true
+
+	result, err := dataBlockToLeaf(dataBlock, config)
+	if err != nil {
+		return false, err
+	}
+	fn, sn := idx, treeSize-1
+	for _, sibling := range proof.Siblings {
+		if sn == 0 {
+			return false, nil
+		}
+		if fn&1 == 1 || fn == sn {
+			if result, err = config.HashFunc(concatRFC6962Node(sibling, result)); err != nil {
+				return false, err
+			}
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else if result, err = config.HashFunc(concatRFC6962Node(result, sibling)); err != nil {
+			return false, err
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(result, root), nil
+}
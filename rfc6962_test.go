@@ -0,0 +1,204 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/txaty/go-merkletree/mock"
+)
+
+// rfc6962Inputs are the leaf inputs of the RFC 6962 test vectors of Certificate Transparency.
+var rfc6962Inputs = []string{
+	"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f",
+}
+
+// rfc6962Roots are the roots of the first i+1 inputs.
+var rfc6962Roots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func rfc6962Blocks(t *testing.T) []DataBlock {
+	t.Helper()
+	blocks := make([]DataBlock, len(rfc6962Inputs))
+	for i, input := range rfc6962Inputs {
+		data, err := hex.DecodeString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks[i] = &mock.DataBlock{Data: data}
+	}
+	return blocks
+}
+
+// rfc6962ReferenceRoot computes the Merkle Tree Hash as recursively defined by RFC 6962.
+func rfc6962ReferenceRoot(data [][]byte) []byte {
+	if len(data) == 1 {
+		h := sha256.Sum256(append([]byte{0}, data[0]...))
+		return h[:]
+	}
+	k := 1
+	for k*2 < len(data) {
+		k *= 2
+	}
+	h := sha256.Sum256(append(append([]byte{1}, rfc6962ReferenceRoot(data[:k])...), rfc6962ReferenceRoot(data[k:])...))
+	return h[:]
+}
+
+func TestMerkleTreeNew_RFC6962(t *testing.T) {
+	blocks := rfc6962Blocks(t)
+	for _, mode := range []TypeConfigMode{ModeProofGen, ModeTreeBuild, ModeProofGenAndTreeBuild} {
+		for _, parallel := range []bool{false, true} {
+			for n := 2; n <= len(blocks); n++ {
+				config := &Config{RFC6962: true, Mode: mode, RunInParallel: parallel}
+				m, err := New(config, blocks[:n])
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				if got := hex.EncodeToString(m.Root); got != rfc6962Roots[n-1] {
+					t.Fatalf("mode %d size %d: root = %s, want %s", mode, n, got, rfc6962Roots[n-1])
+				}
+				for i := 0; i < n; i++ {
+					proof, err := m.ProofByIndex(i)
+					if err != nil {
+						t.Fatalf("ProofByIndex() error = %v", err)
+					}
+					ok, err := m.Verify(blocks[i], proof)
+					if err != nil || !ok {
+						t.Fatalf("size %d: Verify(%d) = %v, %v", n, i, ok, err)
+					}
+					ok, err = m.VerifyByIndex(blocks[i], i, proof)
+					if err != nil || !ok {
+						t.Fatalf("size %d: VerifyByIndex(%d) = %v, %v", n, i, ok, err)
+					}
+					if ok, _ = VerifyRFC6962Inclusion(blocks[i], i^1, n, proof, m.Root, nil); ok && i^1 < n {
+						t.Errorf("size %d: VerifyRFC6962Inclusion() at a wrong index = true", n)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleTreeNew_RFC6962Reference(t *testing.T) {
+	blocks := generatedTestDataBlocks(70)
+	data := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		data[i], _ = block.Serialize()
+	}
+	for n := 2; n <= len(blocks); n++ {
+		m, err := New(&Config{RFC6962: true, Mode: ModeTreeBuild}, blocks[:n])
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if want := rfc6962ReferenceRoot(data[:n]); !bytes.Equal(m.Root, want) {
+			t.Fatalf("size %d: root = %x, want %x", n, m.Root, want)
+		}
+		s, err := NewStreamBuilder(&Config{RFC6962: true})
+		if err != nil {
+			t.Fatalf("NewStreamBuilder() error = %v", err)
+		}
+		for _, block := range blocks[:n] {
+			if err = s.Add(block); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+		}
+		if root, err := s.Root(); err != nil || !bytes.Equal(root, m.Root) {
+			t.Fatalf("size %d: StreamBuilder.Root() = %x, %v, want %x", n, root, err, m.Root)
+		}
+	}
+}
+
+func TestMerkleTree_RFC6962AuditPath(t *testing.T) {
+	m, err := New(&Config{RFC6962: true, Mode: ModeTreeBuild}, rfc6962Blocks(t))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	tests := []struct {
+		idx  int
+		want []string
+	}{
+		{idx: 0, want: []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{idx: 5, want: []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+	}
+	for _, tt := range tests {
+		proof, err := m.ProofByIndex(tt.idx)
+		if err != nil {
+			t.Fatalf("ProofByIndex() error = %v", err)
+		}
+		if len(proof.Siblings) != len(tt.want) {
+			t.Fatalf("audit path length = %d, want %d", len(proof.Siblings), len(tt.want))
+		}
+		for i, sibling := range proof.Siblings {
+			if got := hex.EncodeToString(sibling); got != tt.want[i] {
+				t.Errorf("leaf %d: audit path[%d] = %s, want %s", tt.idx, i, got, tt.want[i])
+			}
+		}
+	}
+
+	// The odd last node of a level is promoted, so it has a shorter audit path.
+	m, err = New(&Config{RFC6962: true, Mode: ModeTreeBuild}, rfc6962Blocks(t)[:5])
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	proof, err := m.ProofByIndex(4)
+	if err != nil {
+		t.Fatalf("ProofByIndex() error = %v", err)
+	}
+	if len(proof.Siblings) != 1 {
+		t.Errorf("audit path length = %d, want 1", len(proof.Siblings))
+	}
+}
+
+func TestMerkleTreeNew_RFC6962InvalidConfig(t *testing.T) {
+	for _, config := range []*Config{
+		{RFC6962: true, Duplicates: true},
+		{RFC6962: true, ZeroPadding: true},
+		{RFC6962: true, SortSiblingPairs: true},
+		{RFC6962: true, ContiguousNodes: true},
+	} {
+		if _, err := New(config, generatedTestDataBlocks(4)); !errors.Is(err, ErrInvalidRFC6962Config) {
+			t.Errorf("New() error = %v, want %v", err, ErrInvalidRFC6962Config)
+		}
+	}
+	if _, err := VerifyByIndex(generatedTestDataBlocks(1)[0], 0, &Proof{}, nil, &Config{RFC6962: true}); !errors.Is(err, ErrRFC6962TreeSizeRequired) {
+		t.Errorf("VerifyByIndex() error = %v, want %v", err, ErrRFC6962TreeSizeRequired)
+	}
+}
+
+func TestMerkleTree_RFC6962Unsupported(t *testing.T) {
+	config := &Config{RFC6962: true, Mode: ModeTreeBuild}
+	m, err := New(config, generatedTestDataBlocks(5))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err = GenerateSolidityVerifier(config, m.Root, "Verifier"); !errors.Is(err, ErrUnsupportedRFC6962) {
+		t.Errorf("GenerateSolidityVerifier() error = %v, want %v", err, ErrUnsupportedRFC6962)
+	}
+	if _, err = NewLevelCache(m, 0, 2); !errors.Is(err, ErrUnsupportedRFC6962) {
+		t.Errorf("NewLevelCache() error = %v, want %v", err, ErrUnsupportedRFC6962)
+	}
+	if _, err = SubtreeFromTree(m); !errors.Is(err, ErrUnsupportedRFC6962) {
+		t.Errorf("SubtreeFromTree() error = %v, want %v", err, ErrUnsupportedRFC6962)
+	}
+	if _, err = NewTopTree(config, []Subtree{{Root: m.Root, Depth: 3}, {Root: m.Root, Depth: 3}}); !errors.Is(err, ErrUnsupportedRFC6962) {
+		t.Errorf("NewTopTree() error = %v, want %v", err, ErrUnsupportedRFC6962)
+	}
+}
@@ -0,0 +1,155 @@
+package merkletree
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// solidityVerifySignature is the signature of the verify function of the generated Solidity verifier.
+const solidityVerifySignature = "verify(bytes,bytes32[],uint32)"
+
+var (
+	// ErrUnsupportedSolidityHash is the error for a hash function which has no Solidity equivalent.
+	ErrUnsupportedSolidityHash = errors.New("hash function is not supported by the Solidity verifier")
+	// ErrInvalidContractName is the error for an invalid Solidity contract name.
+	ErrInvalidContractName = errors.New("invalid Solidity contract name")
+)
+
+// solidityIdentifier matches the valid Solidity identifiers.
+var solidityIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// solidityHashExprs maps the supported hash functions to the Solidity expressions hashing the bytes b.
+var solidityHashExprs = []struct {
+	hashFunc TypeHashFunc
+	expr     string
+}{
+	{hashFunc: DefaultHashFunc, expr: "sha256(b)"},
+	{hashFunc: DefaultHashFuncParallel, expr: "sha256(b)"},
+	{hashFunc: Keccak256HashFunc, expr: "keccak256(b)"},
+	{hashFunc: Keccak256HashFuncParallel, expr: "keccak256(b)"},
+	{hashFunc: SHA256Trunc254HashFunc, expr: "sha256(b) & ~bytes32(uint256(0xc0))"},
+	{hashFunc: SHA256Trunc254HashFuncParallel, expr: "sha256(b) & ~bytes32(uint256(0xc0))"},
+}
+
+// solidityVerifierTemplate is the template of the Solidity verifier source.
+var solidityVerifierTemplate = template.Must(template.New("verifier").Parse(`// SPDX-License-Identifier: MIT
+// Code generated by go-merkletree. DO NOT EDIT.
+pragma solidity ^0.8.5;
+
+/// @notice Verifies the proofs of the Merkle Tree with the root ROOT, in the same way as Verify of go-merkletree.
+contract {{.Name}} {
+    bytes32 public constant ROOT = 0x{{.Root}};
+
+    /// @notice Checks that data is a leaf of the tree.
+    /// @param data The serialized data block.
+    /// @param siblings The sibling nodes of the proof, from the leaf level up.
+{{- if .SortSiblingPairs}}
+    /// @dev The path argument is not used, as the sibling pairs are sorted.
+{{- else}}
+    /// @param path The proof path, whose bit i is set if the node at the i-th step is the left child.
+{{- end}}
+    function verify(bytes calldata data, bytes32[] calldata siblings, uint32 {{if .SortSiblingPairs}}/* path */{{else}}path{{end}}) external pure returns (bool) {
+{{- if .DisableLeafHashing}}
+        require(data.length == 32, "leaf must be 32 bytes");
+        bytes32 node = bytes32(data);
+{{- else}}
+        bytes32 node = _hash(data);
+{{- end}}
+        for (uint256 i = 0; i < siblings.length; i++) {
+{{- if .SortSiblingPairs}}
+            node = node < siblings[i] ? _hashPair(node, siblings[i]) : _hashPair(siblings[i], node);
+{{- else}}
+            node = (path >> i) & 1 == 1 ? _hashPair(node, siblings[i]) : _hashPair(siblings[i], node);
+{{- end}}
+        }
+        return node == ROOT;
+    }
+
+    function _hashPair(bytes32 a, bytes32 b) private pure returns (bytes32) {
+        return _hash(abi.encodePacked(a, b));
+    }
+
+    function _hash(bytes memory b) private pure returns (bytes32) {
+        return {{.HashExpr}};
+    }
+}
+`))
+
+// GenerateSolidityVerifier generates the source of a Solidity contract which verifies the proofs of the tree
+// with the root, using the same hash function, concatenation and leaf hashing rules as Verify with the config.
+// The supported hash functions are the default SHA256, Keccak-256 and SHA256-trunc254-padded, and the nodes
+// must be 32 bytes. The trees of a higher arity and RFC 6962 mode are not supported.
+// The verify function of the contract takes the arguments encoded by ProofCalldata.
+func GenerateSolidityVerifier(config *Config, root []byte, contractName string) (string, error) {
+	if !solidityIdentifier.MatchString(contractName) {
+		return "", ErrInvalidContractName
+	}
+	if len(root) != abiWordSize {
+		return "", ErrInvalidLeafSize
+	}
+	config = copyConfig(config)
+	if config.Arity > 2 {
+		return "", ErrUnsupportedArity
+	}
+	if config.RFC6962 {
+		return "", ErrUnsupportedRFC6962
+	}
+	hashPtr := reflect.ValueOf(config.HashFunc).Pointer()
+	var hashExpr string
+	for _, h := range solidityHashExprs {
+		if reflect.ValueOf(h.hashFunc).Pointer() == hashPtr {
+			hashExpr = h.expr
+			break
+		}
+	}
+	if hashExpr == "" {
+		return "", ErrUnsupportedSolidityHash
+	}
+
+	var source strings.Builder
+	err := solidityVerifierTemplate.Execute(&source, struct {
+		Name               string
+		Root               string
+		HashExpr           string
+		SortSiblingPairs   bool
+		DisableLeafHashing bool
+	}{
+		Name:               contractName,
+		Root:               hex.EncodeToString(root),
+		HashExpr:           hashExpr,
+		SortSiblingPairs:   config.SortSiblingPairs,
+		DisableLeafHashing: config.DisableLeafHashing,
+	})
+	if err != nil {
+		return "", err
+	}
+	return source.String(), nil
+}
+
+// ProofCalldata returns the calldata calling the verify function of the contract generated by
+// GenerateSolidityVerifier with the data block and its proof.
+func ProofCalldata(dataBlock DataBlock, proof *Proof) ([]byte, error) {
+	if dataBlock == nil {
+		return nil, ErrDataBlockIsNil
+	}
+	if proof == nil {
+		return nil, ErrProofIsNil
+	}
+	data, err := dataBlock.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	args, err := ABIEncode(
+		[]string{"bytes", "bytes32[]", "uint32"},
+		[]any{data, proof.Siblings, proof.Path},
+	)
+	if err != nil {
+		return nil, err
+	}
+	selector := keccak256([]byte(solidityVerifySignature))[:4]
+	return append(selector, args...), nil
+}
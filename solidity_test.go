@@ -0,0 +1,371 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/txaty/go-merkletree/mock"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestGenerateSolidityVerifier(t *testing.T) {
+	root := bytes.Repeat([]byte{0x12}, 32)
+	tests := []struct {
+		name     string
+		config   *Config
+		contains []string
+		excludes []string
+		wantErr  error
+	}{
+		{
+			name:     "test_default",
+			config:   nil,
+			contains: []string{"contract Verifier {", "return sha256(b);", "bytes32 node = _hash(data);", "(path >> i) & 1 == 1"},
+			excludes: []string{"node < siblings[i]"},
+		},
+		{
+			name:     "test_openzeppelin",
+			config:   &Config{HashFunc: Keccak256HashFunc, SortSiblingPairs: true},
+			contains: []string{"return keccak256(b);", "node < siblings[i]"},
+			excludes: []string{"path >> i"},
+		},
+		{
+			name:     "test_commp",
+			config:   NewCommPConfig(true),
+			contains: []string{"return sha256(b) & ~bytes32(uint256(0xc0));", "bytes32 node = bytes32(data);"},
+		},
+		{
+			name:    "test_unsupported_hash",
+			config:  &Config{HashFunc: mockHashFunc},
+			wantErr: ErrUnsupportedSolidityHash,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := GenerateSolidityVerifier(tt.config, root, "Verifier")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GenerateSolidityVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !strings.Contains(source, "bytes32 public constant ROOT = 0x"+hex.EncodeToString(root)+";") {
+				t.Errorf("root is not in the source:\n%s", source)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(source, s) {
+					t.Errorf("%q is not in the source:\n%s", s, source)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(source, s) {
+					t.Errorf("%q is in the source:\n%s", s, source)
+				}
+			}
+		})
+	}
+
+	if _, err := GenerateSolidityVerifier(nil, root, "1Verifier"); !errors.Is(err, ErrInvalidContractName) {
+		t.Errorf("GenerateSolidityVerifier() error = %v, want %v", err, ErrInvalidContractName)
+	}
+	if _, err := GenerateSolidityVerifier(nil, root[:31], "Verifier"); !errors.Is(err, ErrInvalidLeafSize) {
+		t.Errorf("GenerateSolidityVerifier() error = %v, want %v", err, ErrInvalidLeafSize)
+	}
+}
+
+func TestProofCalldata(t *testing.T) {
+	blocks := generatedTestDataBlocks(5)
+	m, err := New(&Config{HashFunc: Keccak256HashFunc}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	calldata, err := ProofCalldata(blocks[3], m.Proofs[3])
+	if err != nil {
+		t.Fatalf("ProofCalldata() error = %v", err)
+	}
+	if !bytes.Equal(calldata[:4], keccak256([]byte("verify(bytes,bytes32[],uint32)"))[:4]) {
+		t.Errorf("selector = %x", calldata[:4])
+	}
+
+	// Decode the arguments following the head and tail layout of the ABI encoding.
+	args := calldata[4:]
+	word := func(offset int) int {
+		return int(new(big.Int).SetBytes(args[offset : offset+abiWordSize]).Int64())
+	}
+	data, _ := blocks[3].Serialize()
+	dataOffset := word(0)
+	if got := args[dataOffset+abiWordSize : dataOffset+abiWordSize+word(dataOffset)]; !bytes.Equal(got, data) {
+		t.Errorf("data = %x, want %x", got, data)
+	}
+	siblingsOffset := word(abiWordSize)
+	if n := word(siblingsOffset); n != len(m.Proofs[3].Siblings) {
+		t.Fatalf("number of siblings = %d, want %d", n, len(m.Proofs[3].Siblings))
+	}
+	for i, sibling := range m.Proofs[3].Siblings {
+		offset := siblingsOffset + (i+1)*abiWordSize
+		if !bytes.Equal(args[offset:offset+abiWordSize], sibling) {
+			t.Errorf("sibling %d = %x, want %x", i, args[offset:offset+abiWordSize], sibling)
+		}
+	}
+	if path := word(2 * abiWordSize); uint32(path) != m.Proofs[3].Path {
+		t.Errorf("path = %d, want %d", path, m.Proofs[3].Path)
+	}
+
+	if _, err = ProofCalldata(blocks[0], nil); !errors.Is(err, ErrProofIsNil) {
+		t.Errorf("ProofCalldata() error = %v, want %v", err, ErrProofIsNil)
+	}
+}
+
+func TestProofCalldata_encoding(t *testing.T) {
+	data := bytes.Repeat([]byte{0xab}, 40)
+	proof := &Proof{
+		Siblings: [][]byte{bytes.Repeat([]byte{0x11}, 32), bytes.Repeat([]byte{0x22}, 32)},
+		Path:     5,
+	}
+	got, err := ProofCalldata(&mock.DataBlock{Data: data}, proof)
+	if err != nil {
+		t.Fatalf("ProofCalldata() error = %v", err)
+	}
+	want := "9a1bba63" + // keccak256("verify(bytes,bytes32[],uint32)")[:4]
+		"0000000000000000000000000000000000000000000000000000000000000060" + // offset of data
+		"00000000000000000000000000000000000000000000000000000000000000c0" + // offset of siblings
+		"0000000000000000000000000000000000000000000000000000000000000005" + // path
+		"0000000000000000000000000000000000000000000000000000000000000028" + // length of data
+		strings.Repeat("ab", 40) + strings.Repeat("00", 24) +
+		"0000000000000000000000000000000000000000000000000000000000000002" + // number of siblings
+		strings.Repeat("11", 32) +
+		strings.Repeat("22", 32)
+	if hex.EncodeToString(got) != want {
+		t.Errorf("ProofCalldata() = %x, want %s", got, want)
+	}
+}
+
+// solidityHashes maps the hash expressions of the generated contracts to their Go equivalents.
+var solidityHashes = map[string]func([]byte) []byte{
+	"sha256(b)": func(b []byte) []byte {
+		h := sha256.Sum256(b)
+		return h[:]
+	},
+	"keccak256(b)": func(b []byte) []byte {
+		d := sha3.NewLegacyKeccak256()
+		d.Write(b)
+		return d.Sum(nil)
+	},
+	"sha256(b) & ~bytes32(uint256(0xc0))": func(b []byte) []byte {
+		h := sha256.Sum256(b)
+		h[31] &^= 0xc0
+		return h[:]
+	},
+}
+
+var (
+	solidityRootPattern = regexp.MustCompile(`bytes32 public constant ROOT = 0x([0-9a-f]{64});`)
+	solidityHashPattern = regexp.MustCompile(`function _hash\(bytes memory b\) private pure returns \(bytes32\) \{\s*return (.*);`)
+)
+
+// solidityReferenceVerify runs the verify function of the generated contract source on the calldata,
+// reading the root, the hash expression, the leaf hashing and the sibling ordering from the source.
+func solidityReferenceVerify(source string, calldata []byte) (bool, error) {
+	root := solidityRootPattern.FindStringSubmatch(source)
+	expr := solidityHashPattern.FindStringSubmatch(source)
+	if root == nil || expr == nil {
+		return false, fmt.Errorf("unexpected source:\n%s", source)
+	}
+	hash, ok := solidityHashes[expr[1]]
+	if !ok {
+		return false, fmt.Errorf("unknown hash expression %q", expr[1])
+	}
+	sorted := strings.Contains(source, "node = node < siblings[i] ? _hashPair(node, siblings[i]) : _hashPair(siblings[i], node);")
+	byPath := strings.Contains(source, "node = (path >> i) & 1 == 1 ? _hashPair(node, siblings[i]) : _hashPair(siblings[i], node);")
+	if sorted == byPath || !strings.Contains(source, "return _hash(abi.encodePacked(a, b));") {
+		return false, fmt.Errorf("unexpected pair hashing:\n%s", source)
+	}
+	hashLeaf := strings.Contains(source, "bytes32 node = _hash(data);")
+
+	// Decode the arguments of verify(bytes,bytes32[],uint32) as the EVM does.
+	if len(calldata) < 4+3*abiWordSize || !bytes.Equal(calldata[:4], keccak256([]byte(solidityVerifySignature))[:4]) {
+		return false, fmt.Errorf("invalid selector")
+	}
+	args := calldata[4:]
+	word := func(offset int) (int, error) {
+		if offset < 0 || offset+abiWordSize > len(args) {
+			return 0, fmt.Errorf("word at %d is out of range", offset)
+		}
+		n := new(big.Int).SetBytes(args[offset : offset+abiWordSize])
+		if !n.IsInt64() || n.Int64() > int64(len(args)) {
+			return 0, fmt.Errorf("word at %d is too large", offset)
+		}
+		return int(n.Int64()), nil
+	}
+	dataOffset, err := word(0)
+	if err != nil {
+		return false, err
+	}
+	dataLen, err := word(dataOffset)
+	if err != nil || dataOffset+abiWordSize+dataLen > len(args) {
+		return false, fmt.Errorf("invalid data")
+	}
+	data := args[dataOffset+abiWordSize : dataOffset+abiWordSize+dataLen]
+	siblingsOffset, err := word(abiWordSize)
+	if err != nil {
+		return false, err
+	}
+	numSiblings, err := word(siblingsOffset)
+	if err != nil || siblingsOffset+(numSiblings+1)*abiWordSize > len(args) {
+		return false, fmt.Errorf("invalid siblings")
+	}
+	path, err := word(2 * abiWordSize)
+	if err != nil || path > 1<<32-1 {
+		return false, fmt.Errorf("invalid path")
+	}
+
+	var node []byte
+	if hashLeaf {
+		node = hash(data)
+	} else {
+		if len(data) != abiWordSize {
+			return false, fmt.Errorf("leaf must be 32 bytes")
+		}
+		node = data
+	}
+	for i := 0; i < numSiblings; i++ {
+		offset := siblingsOffset + (i+1)*abiWordSize
+		sibling := args[offset : offset+abiWordSize]
+		left := path>>i&1 == 1
+		if sorted {
+			left = bytes.Compare(node, sibling) < 0
+		}
+		if left {
+			node = hash(append(append([]byte(nil), node...), sibling...))
+		} else {
+			node = hash(append(append([]byte(nil), sibling...), node...))
+		}
+	}
+	return hex.EncodeToString(node) == root[1], nil
+}
+
+func TestGenerateSolidityVerifier_reference(t *testing.T) {
+	chunks := make([]DataBlock, 13)
+	for i := range chunks {
+		chunks[i] = &mock.DataBlock{Data: bytes.Repeat([]byte{byte(i)}, 32)}
+	}
+	tests := []struct {
+		name   string
+		config *Config
+		blocks []DataBlock
+	}{
+		{name: "test_default", config: nil, blocks: generatedTestDataBlocks(13)},
+		{name: "test_keccak256", config: &Config{HashFunc: Keccak256HashFunc}, blocks: generatedTestDataBlocks(8)},
+		{
+			name:   "test_openzeppelin",
+			config: &Config{HashFunc: Keccak256HashFuncParallel, SortSiblingPairs: true},
+			blocks: generatedTestDataBlocks(13),
+		},
+		{name: "test_duplicates", config: &Config{Duplicates: true}, blocks: generatedTestDataBlocks(5)},
+		{name: "test_commp", config: NewCommPConfig(false), blocks: chunks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.config, tt.blocks)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			source, err := GenerateSolidityVerifier(tt.config, m.Root, "Verifier")
+			if err != nil {
+				t.Fatalf("GenerateSolidityVerifier() error = %v", err)
+			}
+			for i, block := range tt.blocks {
+				calldata, err := ProofCalldata(block, m.Proofs[i])
+				if err != nil {
+					t.Fatalf("ProofCalldata() error = %v", err)
+				}
+				if ok, err := solidityReferenceVerify(source, calldata); err != nil || !ok {
+					t.Fatalf("verify(%d) = %v, %v", i, ok, err)
+				}
+				// The proof of another leaf must be rejected.
+				other := (i + 1) % len(tt.blocks)
+				if calldata, err = ProofCalldata(block, m.Proofs[other]); err != nil {
+					t.Fatalf("ProofCalldata() error = %v", err)
+				}
+				ok, err := solidityReferenceVerify(source, calldata)
+				if err != nil {
+					t.Fatalf("verify(%d) error = %v", i, err)
+				}
+				if want, _ := m.Verify(block, m.Proofs[other]); ok != want {
+					t.Errorf("verify(%d) with the proof of %d = %v, Verify() = %v", i, other, ok, want)
+				}
+			}
+		})
+	}
+}
+
+// solidityParamPattern matches the names of the named parameters in a Solidity parameter list,
+// i.e. the identifiers after the type and the data location.
+var solidityParamPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$\[\]]*(?:\s+(?:calldata|memory|storage))?\s+([A-Za-z_$][A-Za-z0-9_$]*)$`)
+
+// checkSolidityNatSpec checks that each @param tag of the NatSpec comments documents a named parameter of
+// the function below it, which solc requires to compile the source.
+func checkSolidityNatSpec(source string) error {
+	var params []string
+	for _, line := range strings.Split(source, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "///") {
+			if fields := strings.Fields(strings.TrimPrefix(line, "///")); len(fields) >= 2 && fields[0] == "@param" {
+				params = append(params, fields[1])
+			}
+			continue
+		}
+		if len(params) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(line, "function ") {
+			return fmt.Errorf("@param %v is not followed by a function: %s", params, line)
+		}
+		named := make(map[string]bool)
+		list := line[strings.Index(line, "(")+1 : strings.Index(line, ")")]
+		list = regexp.MustCompile(`/\*.*?\*/`).ReplaceAllString(list, "")
+		for _, param := range strings.Split(list, ",") {
+			if match := solidityParamPattern.FindStringSubmatch(strings.TrimSpace(param)); match != nil {
+				named[match[1]] = true
+			}
+		}
+		for _, param := range params {
+			if !named[param] {
+				return fmt.Errorf("@param %s has no matching named parameter: %s", param, line)
+			}
+		}
+		params = nil
+	}
+	return nil
+}
+
+func TestGenerateSolidityVerifier_natSpec(t *testing.T) {
+	root := bytes.Repeat([]byte{0x12}, 32)
+	for _, config := range []*Config{
+		nil,
+		{HashFunc: Keccak256HashFunc, SortSiblingPairs: true},
+		NewCommPConfig(true),
+		{HashFunc: Keccak256HashFunc, SortSiblingPairs: true, DisableLeafHashing: true},
+	} {
+		source, err := GenerateSolidityVerifier(config, root, "Verifier")
+		if err != nil {
+			t.Fatalf("GenerateSolidityVerifier() error = %v", err)
+		}
+		if err = checkSolidityNatSpec(source); err != nil {
+			t.Errorf("checkSolidityNatSpec() error = %v", err)
+		}
+	}
+	// The check rejects a documented parameter whose name is commented out.
+	invalid := `    /// @param path The proof path.
+    function verify(bytes calldata data, uint32 /* path */) external pure returns (bool) {`
+	if err := checkSolidityNatSpec(invalid); err == nil {
+		t.Errorf("checkSolidityNatSpec() of an unnamed parameter is nil")
+	}
+}
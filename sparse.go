@@ -0,0 +1,318 @@
+package merkletree
+
+import (
+	"bytes"
+	"math/bits"
+	"sync"
+)
+
+// sparseEmptyCache caches the empty subtree tables computed by sparseEmptyTable, keyed by hashCacheKey
+// as in zeroPaddingCache.
+var sparseEmptyCache sync.Map
+
+// sparseEmptyTable returns the table of the empty subtree roots of a sparse Merkle tree with the hash function
+// of the configuration. The entry at height 0 is the empty leaf, i.e. zero bytes of the hash size, and the entry
+// at height i is the hash of two entries at height i-1. The table has an entry for each bit of the hash,
+// plus the empty root.
+func sparseEmptyTable(config *Config) ([][]byte, error) {
+	key, cacheable := hashCacheKey(config)
+	if cached, ok := sparseEmptyCache.Load(key); cacheable && ok {
+		return cached.([][]byte), nil
+	}
+	hashFunc := copyConfig(config).HashFunc
+	size, err := hashSize(hashFunc)
+	if err != nil {
+		return nil, err
+	}
+	depth := size * 8
+	table := make([][]byte, depth+1)
+	table[0] = make([]byte, size)
+	for i := 1; i <= depth; i++ {
+		if table[i], err = hashFunc(concatHash(table[i-1], table[i-1])); err != nil {
+			return nil, err
+		}
+	}
+	if cacheable {
+		sparseEmptyCache.Store(key, table)
+	}
+	return table, nil
+}
+
+// SparseMerkleTree is a sparse Merkle tree of key-value pairs. The position of a key is given by the bits of
+// its hash, so the tree has a leaf for every possible key, and a key that is not set has the empty leaf.
+// This allows to prove that a key is not in the tree. Only the nodes which are not the roots of empty subtrees
+// are stored. A SparseMerkleTree is not safe for concurrent use.
+type SparseMerkleTree struct {
+	// hashFunc is the hash function of the keys, the leaves and the internal nodes.
+	hashFunc TypeHashFunc
+	// hashName is the HashName of the configuration, which keys the cached table of the hash function.
+	hashName string
+	// depth is the number of bits of the hash, i.e. the number of levels below the root.
+	depth int
+	// empty is the table of the empty subtree roots by height.
+	empty [][]byte
+	// nodes maps the height and the path prefix of each non-empty node to the node.
+	nodes map[string][]byte
+	// values maps the path of each key to its value.
+	values map[string][]byte
+	// Root is the hash of the sparse Merkle root node.
+	Root []byte
+}
+
+// SparseProof is the proof of a key in a SparseMerkleTree, which proves either the value of the key
+// or that the key is not in the tree.
+type SparseProof struct {
+	// Siblings are the siblings of the path from the leaf to the root.
+	// If Bitmap is set, the siblings which are the roots of empty subtrees are omitted.
+	Siblings [][]byte
+	// Bitmap is nil for a full proof. For a compressed proof, bit i%8 of Bitmap[i/8] is set if the sibling
+	// at height i is in Siblings, otherwise the sibling is the root of an empty subtree.
+	Bitmap []byte
+}
+
+// NewSparseMerkleTree creates an empty sparse Merkle tree with the hash function of the configuration.
+// The other options of the configuration are not used.
+func NewSparseMerkleTree(config *Config) (*SparseMerkleTree, error) {
+	config = copyConfig(config)
+	empty, err := sparseEmptyTable(config)
+	if err != nil {
+		return nil, err
+	}
+	depth := len(empty) - 1
+	return &SparseMerkleTree{
+		hashFunc: config.HashFunc,
+		hashName: config.HashName,
+		depth:    depth,
+		empty:    empty,
+		nodes:    make(map[string][]byte),
+		values:   make(map[string][]byte),
+		Root:     empty[depth],
+	}, nil
+}
+
+// Len returns the number of keys in the tree.
+func (t *SparseMerkleTree) Len() int {
+	return len(t.values)
+}
+
+// Get returns the value of the key. It returns ErrSparseKeyNotFound if the key is not in the tree.
+func (t *SparseMerkleTree) Get(key []byte) ([]byte, error) {
+	path, err := t.hashFunc(key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := t.values[string(path)]
+	if !ok {
+		return nil, ErrSparseKeyNotFound
+	}
+	return value, nil
+}
+
+// Set sets the value of the key and recomputes the nodes on the path of the key.
+func (t *SparseMerkleTree) Set(key, value []byte) error {
+	if value == nil {
+		return ErrSparseValueIsNil
+	}
+	path, err := t.hashFunc(key)
+	if err != nil {
+		return err
+	}
+	leaf, err := sparseLeaf(path, value, t.hashFunc)
+	if err != nil {
+		return err
+	}
+	if err = t.updatePath(path, leaf); err != nil {
+		return err
+	}
+	// Copy the value so that it is not modified by the caller.
+	t.values[string(path)] = append([]byte{}, value...)
+	return nil
+}
+
+// Delete removes the key from the tree, so that its leaf is the empty leaf again.
+// Deleting a key which is not in the tree does nothing.
+func (t *SparseMerkleTree) Delete(key []byte) error {
+	path, err := t.hashFunc(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := t.values[string(path)]; !ok {
+		return nil
+	}
+	if err = t.updatePath(path, t.empty[0]); err != nil {
+		return err
+	}
+	delete(t.values, string(path))
+	return nil
+}
+
+// updatePath sets the leaf of the path and recomputes the nodes up to the root.
+// The nodes which become the roots of empty subtrees are removed.
+func (t *SparseMerkleTree) updatePath(path, leaf []byte) (err error) {
+	node := leaf
+	for height := 0; height < t.depth; height++ {
+		key := sparseNodeKey(path, height)
+		if bytes.Equal(node, t.empty[height]) {
+			delete(t.nodes, key)
+		} else {
+			t.nodes[key] = node
+		}
+		sibling := t.sibling(path, height)
+		if sparseBit(path, height) == 0 {
+			node, err = t.hashFunc(concatHash(node, sibling))
+		} else {
+			node, err = t.hashFunc(concatHash(sibling, node))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	t.Root = node
+	return nil
+}
+
+// sibling returns the sibling of the node at the height on the path.
+func (t *SparseMerkleTree) sibling(path []byte, height int) []byte {
+	siblingPath := append([]byte{}, path...)
+	siblingPath[len(siblingPath)-1-height/8] ^= 1 << (height % 8)
+	if node, ok := t.nodes[sparseNodeKey(siblingPath, height)]; ok {
+		return node
+	}
+	return t.empty[height]
+}
+
+// Proof generates the full proof of the key, which proves the value of the key if it is in the tree,
+// or that the key is not in the tree otherwise.
+func (t *SparseMerkleTree) Proof(key []byte) (*SparseProof, error) {
+	path, err := t.hashFunc(key)
+	if err != nil {
+		return nil, err
+	}
+	siblings := make([][]byte, t.depth)
+	for height := range siblings {
+		siblings[height] = t.sibling(path, height)
+	}
+	return &SparseProof{Siblings: siblings}, nil
+}
+
+// CompressedProof generates the proof of the key with the siblings which are the roots of empty subtrees
+// omitted and marked in the bitmap.
+func (t *SparseMerkleTree) CompressedProof(key []byte) (*SparseProof, error) {
+	path, err := t.hashFunc(key)
+	if err != nil {
+		return nil, err
+	}
+	proof := &SparseProof{Bitmap: make([]byte, t.depth/8)}
+	for height := 0; height < t.depth; height++ {
+		sibling := t.sibling(path, height)
+		if bytes.Equal(sibling, t.empty[height]) {
+			continue
+		}
+		proof.Bitmap[height/8] |= 1 << (height % 8)
+		proof.Siblings = append(proof.Siblings, sibling)
+	}
+	return proof, nil
+}
+
+// VerifyMembership checks if the key has the value using the proof and the cached root.
+func (t *SparseMerkleTree) VerifyMembership(key, value []byte, proof *SparseProof) (bool, error) {
+	return VerifySparseMembership(key, value, proof, t.Root, &Config{HashFunc: t.hashFunc, HashName: t.hashName})
+}
+
+// VerifyNonMembership checks if the key is not in the tree using the proof and the cached root.
+func (t *SparseMerkleTree) VerifyNonMembership(key []byte, proof *SparseProof) (bool, error) {
+	return VerifySparseNonMembership(key, proof, t.Root, &Config{HashFunc: t.hashFunc, HashName: t.hashName})
+}
+
+// VerifySparseMembership checks if the key has the value in the sparse Merkle tree of the root using the proof,
+// which is either a full or a compressed proof. Only the hash function of the configuration is used.
+func VerifySparseMembership(key, value []byte, proof *SparseProof, root []byte, config *Config) (bool, error) {
+	if value == nil {
+		return false, ErrSparseValueIsNil
+	}
+	return verifySparseProof(key, value, proof, root, config)
+}
+
+// VerifySparseNonMembership checks if the key is not in the sparse Merkle tree of the root using the proof,
+// which is either a full or a compressed proof. Only the hash function of the configuration is used.
+func VerifySparseNonMembership(key []byte, proof *SparseProof, root []byte, config *Config) (bool, error) {
+	return verifySparseProof(key, nil, proof, root, config)
+}
+
+// verifySparseProof computes the root from the leaf of the key and compares it with the root.
+// The leaf is the empty leaf if the value is nil.
+func verifySparseProof(key, value []byte, proof *SparseProof, root []byte, config *Config) (bool, error) {
+	if proof == nil {
+		return false, ErrProofIsNil
+	}
+	empty, err := sparseEmptyTable(config)
+	if err != nil {
+		return false, err
+	}
+	config = copyConfig(config)
+	depth := len(empty) - 1
+	if proof.Bitmap == nil && len(proof.Siblings) != depth {
+		return false, ErrInvalidSparseProof
+	}
+	if proof.Bitmap != nil {
+		count := 0
+		for _, b := range proof.Bitmap {
+			count += bits.OnesCount8(b)
+		}
+		if len(proof.Bitmap) != depth/8 || count != len(proof.Siblings) {
+			return false, ErrInvalidSparseProof
+		}
+	}
+
+	path, err := config.HashFunc(key)
+	if err != nil {
+		return false, err
+	}
+	node := empty[0]
+	if value != nil {
+		if node, err = sparseLeaf(path, value, config.HashFunc); err != nil {
+			return false, err
+		}
+	}
+	siblings := proof.Siblings
+	for height := 0; height < depth; height++ {
+		sibling := empty[height]
+		if proof.Bitmap == nil || proof.Bitmap[height/8]&(1<<(height%8)) != 0 {
+			sibling, siblings = siblings[0], siblings[1:]
+		}
+		if sparseBit(path, height) == 0 {
+			node, err = config.HashFunc(concatHash(node, sibling))
+		} else {
+			node, err = config.HashFunc(concatHash(sibling, node))
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return bytes.Equal(node, root), nil
+}
+
+// sparseLeaf returns the leaf of the key path with the value, which binds the path to the value.
+func sparseLeaf(path, value []byte, hashFunc TypeHashFunc) ([]byte, error) {
+	return hashFunc(concatHash(path, value))
+}
+
+// sparseBit returns the bit of the path at the height, where the lowest bit of the path is at height 0.
+func sparseBit(path []byte, height int) byte {
+	return path[len(path)-1-height/8] >> (height % 8) & 1
+}
+
+// sparseNodeKey returns the key of the node at the height on the path in the node map,
+// which is the height followed by the path with the bits below the height cleared.
+func sparseNodeKey(path []byte, height int) string {
+	key := make([]byte, 2+len(path))
+	key[0], key[1] = byte(height>>8), byte(height)
+	copy(key[2:], path)
+	for i := 0; i < height/8; i++ {
+		key[len(key)-1-i] = 0
+	}
+	if height%8 != 0 {
+		key[len(key)-1-height/8] &^= 1<<(height%8) - 1
+	}
+	return string(key)
+}
@@ -0,0 +1,165 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSparseMerkleTree(t *testing.T) {
+	for _, config := range []*Config{nil, {HashFunc: Keccak256HashFunc}} {
+		tree, err := NewSparseMerkleTree(config)
+		if err != nil {
+			t.Fatalf("NewSparseMerkleTree() error = %v", err)
+		}
+		emptyRoot := tree.Root
+		keys := make([][]byte, 50)
+		for i := range keys {
+			keys[i] = []byte(fmt.Sprintf("deal-%d", i))
+			if err = tree.Set(keys[i], []byte(fmt.Sprintf("value-%d", i))); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+		}
+		if tree.Len() != len(keys) {
+			t.Fatalf("Len() = %d, want %d", tree.Len(), len(keys))
+		}
+
+		// The root does not depend on the insertion order.
+		reversed, err := NewSparseMerkleTree(config)
+		if err != nil {
+			t.Fatalf("NewSparseMerkleTree() error = %v", err)
+		}
+		for i := len(keys) - 1; i >= 0; i-- {
+			if err = reversed.Set(keys[i], []byte("old")); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if err = reversed.Set(keys[i], []byte(fmt.Sprintf("value-%d", i))); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+		}
+		if !bytes.Equal(reversed.Root, tree.Root) {
+			t.Fatalf("root depends on the insertion order")
+		}
+
+		for i, key := range keys {
+			value, err := tree.Get(key)
+			if err != nil || string(value) != fmt.Sprintf("value-%d", i) {
+				t.Fatalf("Get(%s) = %s, %v", key, value, err)
+			}
+			for _, prove := range []func([]byte) (*SparseProof, error){tree.Proof, tree.CompressedProof} {
+				proof, err := prove(key)
+				if err != nil {
+					t.Fatalf("Proof() error = %v", err)
+				}
+				if ok, err := tree.VerifyMembership(key, value, proof); err != nil || !ok {
+					t.Fatalf("VerifyMembership(%s) = %v, %v", key, ok, err)
+				}
+				if ok, _ := tree.VerifyMembership(key, []byte("wrong"), proof); ok {
+					t.Fatalf("VerifyMembership(%s) with a wrong value = true", key)
+				}
+				if ok, _ := tree.VerifyNonMembership(key, proof); ok {
+					t.Fatalf("VerifyNonMembership(%s) of a member = true", key)
+				}
+			}
+		}
+
+		absent := []byte("revoked")
+		if _, err = tree.Get(absent); !errors.Is(err, ErrSparseKeyNotFound) {
+			t.Fatalf("Get() error = %v, want %v", err, ErrSparseKeyNotFound)
+		}
+		proof, err := tree.Proof(absent)
+		if err != nil {
+			t.Fatalf("Proof() error = %v", err)
+		}
+		compressed, err := tree.CompressedProof(absent)
+		if err != nil {
+			t.Fatalf("CompressedProof() error = %v", err)
+		}
+		if len(compressed.Siblings) >= len(proof.Siblings)/4 {
+			t.Errorf("compressed proof has %d of %d siblings", len(compressed.Siblings), len(proof.Siblings))
+		}
+		for _, p := range []*SparseProof{proof, compressed} {
+			if ok, err := tree.VerifyNonMembership(absent, p); err != nil || !ok {
+				t.Fatalf("VerifyNonMembership() = %v, %v", ok, err)
+			}
+			if ok, _ := tree.VerifyMembership(absent, []byte("value-0"), p); ok {
+				t.Fatalf("VerifyMembership() of a non-member = true")
+			}
+		}
+
+		// Deleting all the keys restores the empty tree.
+		for _, key := range keys {
+			if err = tree.Delete(key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+		}
+		if err = tree.Delete(absent); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if !bytes.Equal(tree.Root, emptyRoot) || tree.Len() != 0 || len(tree.nodes) != 0 {
+			t.Fatalf("tree is not empty after deleting all the keys")
+		}
+		proof, err = tree.CompressedProof(keys[0])
+		if err != nil {
+			t.Fatalf("CompressedProof() error = %v", err)
+		}
+		if ok, err := tree.VerifyNonMembership(keys[0], proof); err != nil || !ok || len(proof.Siblings) != 0 {
+			t.Fatalf("VerifyNonMembership() of a deleted key = %v, %v", ok, err)
+		}
+	}
+}
+
+func TestSparseMerkleTree_closures(t *testing.T) {
+	first, err := NewSparseMerkleTree(&Config{HashFunc: prefixedHashFunc(1)})
+	if err != nil {
+		t.Fatalf("NewSparseMerkleTree() error = %v", err)
+	}
+	second, err := NewSparseMerkleTree(&Config{HashFunc: prefixedHashFunc(2)})
+	if err != nil {
+		t.Fatalf("NewSparseMerkleTree() error = %v", err)
+	}
+	if bytes.Equal(first.Root, second.Root) {
+		t.Fatalf("closures of different states share the empty tree")
+	}
+	if err = second.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	proof, err := second.CompressedProof([]byte("absent"))
+	if err != nil {
+		t.Fatalf("CompressedProof() error = %v", err)
+	}
+	if ok, err := second.VerifyNonMembership([]byte("absent"), proof); err != nil || !ok {
+		t.Fatalf("VerifyNonMembership() = %v, %v", ok, err)
+	}
+}
+
+func TestSparseMerkleTree_errors(t *testing.T) {
+	tree, err := NewSparseMerkleTree(nil)
+	if err != nil {
+		t.Fatalf("NewSparseMerkleTree() error = %v", err)
+	}
+	if err = tree.Set([]byte("key"), nil); !errors.Is(err, ErrSparseValueIsNil) {
+		t.Errorf("Set() error = %v, want %v", err, ErrSparseValueIsNil)
+	}
+	if err = tree.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	proof, err := tree.CompressedProof([]byte("key"))
+	if err != nil {
+		t.Fatalf("CompressedProof() error = %v", err)
+	}
+	if _, err = tree.VerifyMembership([]byte("key"), []byte("value"), nil); !errors.Is(err, ErrProofIsNil) {
+		t.Errorf("VerifyMembership() error = %v, want %v", err, ErrProofIsNil)
+	}
+	invalid := []*SparseProof{
+		{Siblings: proof.Siblings},
+		{Siblings: proof.Siblings, Bitmap: proof.Bitmap[1:]},
+		{Siblings: append(proof.Siblings, make([]byte, 32)), Bitmap: proof.Bitmap},
+	}
+	for _, p := range invalid {
+		if _, err = tree.VerifyMembership([]byte("key"), []byte("value"), p); !errors.Is(err, ErrInvalidSparseProof) {
+			t.Errorf("VerifyMembership() error = %v, want %v", err, ErrInvalidSparseProof)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StandardTreeFormat is the format of the StandardMerkleTree dump of @openzeppelin/merkle-tree.
+const StandardTreeFormat = "standard-v1"
+
+var (
+	// ErrInvalidStandardTree is the error for an invalid StandardMerkleTree dump.
+	ErrInvalidStandardTree = errors.New("invalid standard merkle tree")
+	// ErrValueIndexOutOfRange is the error for a value index out of range.
+	ErrValueIndexOutOfRange = errors.New("value index is out of range")
+	// ErrNotStandardTree is the error for a MerkleTree which does not have the nodes of a StandardMerkleTree.
+	ErrNotStandardTree = errors.New("merkle tree is not compatible with the standard merkle tree")
+	// ErrStandardTreeLeafCount is the error for converting a tree whose number of leaves is not a power of two.
+	// The StandardMerkleTree of such leaves is a complete tree, which has other nodes and another root than
+	// the padded MerkleTree. It wraps ErrNotStandardTree.
+	ErrStandardTreeLeafCount = fmt.Errorf("%w: the number of leaves must be a power of two", ErrNotStandardTree)
+)
+
+// StandardValue is a value of a StandardMerkleTree with the index of its leaf in the tree array.
+type StandardValue struct {
+	Value     []any `json:"value"`
+	TreeIndex int   `json:"treeIndex"`
+}
+
+// standardTreeDump is the JSON dump of a StandardMerkleTree.
+type standardTreeDump struct {
+	Format       string          `json:"format"`
+	Tree         []string        `json:"tree"`
+	Values       []StandardValue `json:"values"`
+	LeafEncoding []string        `json:"leafEncoding"`
+}
+
+// StandardMerkleTree is the Merkle Tree compatible with the StandardMerkleTree of @openzeppelin/merkle-tree,
+// so that the roots, the proofs and the JSON dumps match the JS library byte-for-byte.
+// The leaves are the double Keccak-256 hashes of the ABI encoded values, sorted in ascending order, and the
+// sibling pairs are sorted before hashing like SortSiblingPairs. The nodes are stored in a complete binary
+// tree array, where the children of the node i are 2i+1 and 2i+2 and the root is the node 0.
+// If the number of values is a power of two, the root is the same as the one of New with NewStandardTreeConfig
+// applied to the sorted leaves, and DumpStandard and NewFromStandardDump convert between the two trees.
+type StandardMerkleTree struct {
+	// LeafEncoding are the ABI types of the value fields.
+	LeafEncoding []string
+	// Values are the values in their original order.
+	Values []StandardValue
+	// Tree is the complete binary tree array of the nodes.
+	Tree [][]byte
+}
+
+// NewStandardTreeConfig returns a Config preset that makes New produce the same nodes as a StandardMerkleTree,
+// if the data blocks are the StandardLeafHash of the values in ascending order and their number is a power of two.
+func NewStandardTreeConfig() *Config {
+	return &Config{
+		HashFunc:           Keccak256HashFuncParallel,
+		SortSiblingPairs:   true,
+		DisableLeafHashing: true,
+	}
+}
+
+// StandardLeafHash returns the leaf of the value in a StandardMerkleTree, i.e. keccak256(keccak256(abi.encode(value))).
+func StandardLeafHash(leafEncoding []string, value []any) ([]byte, error) {
+	encoded, err := ABIEncode(leafEncoding, value)
+	if err != nil {
+		return nil, err
+	}
+	return keccak256(keccak256(encoded)), nil
+}
+
+// NewStandardMerkleTree builds the StandardMerkleTree of the values with the ABI types of leafEncoding,
+// in the same way as StandardMerkleTree.of with the default options.
+func NewStandardMerkleTree(values [][]any, leafEncoding []string) (*StandardMerkleTree, error) {
+	if len(values) == 0 {
+		return nil, ErrInvalidNumOfDataBlocks
+	}
+	type hashedValue struct {
+		valueIdx int
+		hash     []byte
+	}
+	hashed := make([]hashedValue, len(values))
+	for i, value := range values {
+		hash, err := StandardLeafHash(leafEncoding, value)
+		if err != nil {
+			return nil, err
+		}
+		hashed[i] = hashedValue{valueIdx: i, hash: hash}
+	}
+	sort.SliceStable(hashed, func(i, j int) bool {
+		return bytes.Compare(hashed[i].hash, hashed[j].hash) < 0
+	})
+
+	t := &StandardMerkleTree{
+		LeafEncoding: leafEncoding,
+		Values:       make([]StandardValue, len(values)),
+		Tree:         make([][]byte, 2*len(values)-1),
+	}
+	for i, h := range hashed {
+		treeIdx := len(t.Tree) - 1 - i
+		t.Tree[treeIdx] = h.hash
+		t.Values[h.valueIdx] = StandardValue{Value: values[h.valueIdx], TreeIndex: treeIdx}
+	}
+	for i := len(t.Tree) - 1 - len(values); i >= 0; i-- {
+		t.Tree[i] = keccak256(concatSortHash(t.Tree[2*i+1], t.Tree[2*i+2]))
+	}
+	return t, nil
+}
+
+// LoadStandardMerkleTree loads the StandardMerkleTree from its JSON dump, and validates the tree and the values.
+func LoadStandardMerkleTree(data []byte) (*StandardMerkleTree, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var dump standardTreeDump
+	if err := decoder.Decode(&dump); err != nil {
+		return nil, err
+	}
+	if dump.Format != StandardTreeFormat || len(dump.Tree) == 0 || len(dump.Tree)%2 == 0 {
+		return nil, ErrInvalidStandardTree
+	}
+	t := &StandardMerkleTree{
+		LeafEncoding: dump.LeafEncoding,
+		Values:       dump.Values,
+		Tree:         make([][]byte, len(dump.Tree)),
+	}
+	for i, node := range dump.Tree {
+		if !strings.HasPrefix(node, "0x") {
+			return nil, ErrInvalidStandardTree
+		}
+		var err error
+		if t.Tree[i], err = hex.DecodeString(node[2:]); err != nil || len(t.Tree[i]) != abiWordSize {
+			return nil, ErrInvalidStandardTree
+		}
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// validate checks that the internal nodes are the hashes of their children,
+// and that the leaves are the hashes of the values.
+func (t *StandardMerkleTree) validate() error {
+	numLeaves := (len(t.Tree) + 1) / 2
+	if len(t.Values) != numLeaves {
+		return ErrInvalidStandardTree
+	}
+	for i := 0; i < len(t.Tree)-numLeaves; i++ {
+		if !bytes.Equal(t.Tree[i], keccak256(concatSortHash(t.Tree[2*i+1], t.Tree[2*i+2]))) {
+			return ErrInvalidStandardTree
+		}
+	}
+	for _, value := range t.Values {
+		if value.TreeIndex < len(t.Tree)-numLeaves || value.TreeIndex >= len(t.Tree) {
+			return ErrInvalidStandardTree
+		}
+		leaf, err := StandardLeafHash(t.LeafEncoding, value.Value)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(leaf, t.Tree[value.TreeIndex]) {
+			return ErrInvalidStandardTree
+		}
+	}
+	return nil
+}
+
+// Dump returns the JSON dump of the StandardMerkleTree in the format of StandardMerkleTree.dump.
+func (t *StandardMerkleTree) Dump() ([]byte, error) {
+	dump := standardTreeDump{
+		Format:       StandardTreeFormat,
+		Tree:         make([]string, len(t.Tree)),
+		Values:       t.Values,
+		LeafEncoding: t.LeafEncoding,
+	}
+	for i, node := range t.Tree {
+		dump.Tree[i] = "0x" + hex.EncodeToString(node)
+	}
+	return json.Marshal(dump)
+}
+
+// Root returns the root of the StandardMerkleTree.
+func (t *StandardMerkleTree) Root() []byte {
+	return t.Tree[0]
+}
+
+// Proof returns the proof of the value at the given index, in the format of StandardMerkleTree.getProof.
+func (t *StandardMerkleTree) Proof(valueIdx int) ([][]byte, error) {
+	if valueIdx < 0 || valueIdx >= len(t.Values) {
+		return nil, ErrValueIndexOutOfRange
+	}
+	var proof [][]byte
+	for i := t.Values[valueIdx].TreeIndex; i > 0; i = (i - 1) / 2 {
+		// The left child has an odd index.
+		if i&1 == 1 {
+			proof = append(proof, t.Tree[i+1])
+		} else {
+			proof = append(proof, t.Tree[i-1])
+		}
+	}
+	return proof, nil
+}
+
+// MultiProof returns the multiproof of the values at the given indices, in the format of
+// StandardMerkleTree.getMultiProof. The Indices of the proof are the value indices in the order of the leaves.
+// As in getMultiProof, duplicate indices are rejected with ErrMultiProofDuplicateLeaf.
+func (t *StandardMerkleTree) MultiProof(valueIndices []int) (*CompactMultiProof, error) {
+	if len(valueIndices) == 0 {
+		return nil, ErrMultiProofNoLeaves
+	}
+	treeIndices := make([]int, len(valueIndices))
+	valueOf := make(map[int]int, len(valueIndices))
+	for i, valueIdx := range valueIndices {
+		if valueIdx < 0 || valueIdx >= len(t.Values) {
+			return nil, ErrValueIndexOutOfRange
+		}
+		treeIndices[i] = t.Values[valueIdx].TreeIndex
+		valueOf[treeIndices[i]] = valueIdx
+	}
+	// Process the nodes in descending order of the tree index, which is the order of the verification.
+	sort.Sort(sort.Reverse(sort.IntSlice(treeIndices)))
+	proof := new(CompactMultiProof)
+	for i, treeIdx := range treeIndices {
+		if i > 0 && treeIdx == treeIndices[i-1] {
+			return nil, ErrMultiProofDuplicateLeaf
+		}
+		proof.Indices = append(proof.Indices, valueOf[treeIdx])
+		proof.Leaves = append(proof.Leaves, t.Tree[treeIdx])
+	}
+	stack := make([]int, 0, len(t.Tree))
+	for _, valueIdx := range proof.Indices {
+		stack = append(stack, t.Values[valueIdx].TreeIndex)
+	}
+	for len(stack) > 0 && stack[0] > 0 {
+		j := stack[0]
+		stack = stack[1:]
+		sibling := j + 1
+		if j&1 == 0 {
+			sibling = j - 1
+		}
+		if len(stack) > 0 && stack[0] == sibling {
+			proof.ProofFlags = append(proof.ProofFlags, true)
+			stack = stack[1:]
+		} else {
+			proof.ProofFlags = append(proof.ProofFlags, false)
+			proof.Proof = append(proof.Proof, t.Tree[sibling])
+		}
+		stack = append(stack, (j-1)/2)
+	}
+	return proof, nil
+}
+
+// VerifyStandardProof checks the proof of the value against the root of a StandardMerkleTree,
+// in the same way as StandardMerkleTree.verify.
+func VerifyStandardProof(root []byte, leafEncoding []string, value []any, proof [][]byte) (bool, error) {
+	result, err := StandardLeafHash(leafEncoding, value)
+	if err != nil {
+		return false, err
+	}
+	for _, sibling := range proof {
+		result = keccak256(concatSortHash(result, sibling))
+	}
+	return bytes.Equal(result, root), nil
+}
+
+// VerifyStandardMultiProof checks the multiproof of the values against the root of a StandardMerkleTree,
+// in the same way as StandardMerkleTree.verifyMultiProof. The values must be in the order of the proof Indices.
+func VerifyStandardMultiProof(root []byte, leafEncoding []string, values [][]any, proof *CompactMultiProof) (bool, error) {
+	leaves := make([][]byte, len(values))
+	for i, value := range values {
+		var err error
+		if leaves[i], err = StandardLeafHash(leafEncoding, value); err != nil {
+			return false, err
+		}
+	}
+	return CompactMultiVerifyLeaves(leaves, proof, root, &Config{
+		HashFunc:         Keccak256HashFuncParallel,
+		SortSiblingPairs: true,
+	})
+}
+
+// DumpStandard returns the JSON dump of the tree in the format of StandardMerkleTree.dump, so that it can be
+// loaded by @openzeppelin/merkle-tree. The values are ABI encoded with leafEncoding, and each value is assigned
+// to a leaf which is its StandardLeafHash. The tree must have the nodes of a StandardMerkleTree, i.e. it is built
+// with NewStandardTreeConfig from a power-of-two number of leaves. The leaf i is the node len(tree)-1-i
+// of the dump, so the dump of a tree with the leaves in ascending order is the same as the one of
+// NewStandardMerkleTree with the values.
+// The trees of other numbers of leaves cannot be dumped, and ErrStandardTreeLeafCount is returned. Use
+// NewStandardMerkleTree with the values instead, whose root differs from the one of the MerkleTree.
+func (m *MerkleTree) DumpStandard(values [][]any, leafEncoding []string) ([]byte, error) {
+	if m.NumLeaves&(m.NumLeaves-1) != 0 {
+		return nil, ErrStandardTreeLeafCount
+	}
+	if !m.SortSiblingPairs || m.RFC6962 || m.Arity > 2 || len(values) != m.NumLeaves {
+		return nil, ErrNotStandardTree
+	}
+	t := &StandardMerkleTree{
+		LeafEncoding: leafEncoding,
+		Values:       make([]StandardValue, len(values)),
+		Tree:         make([][]byte, 2*m.NumLeaves-1),
+	}
+	leafIndices := make(map[string][]int, m.NumLeaves)
+	for i := 0; i < m.NumLeaves; i++ {
+		leaf, err := m.leafAt(i)
+		if err != nil {
+			return nil, err
+		}
+		t.Tree[len(t.Tree)-1-i] = leaf
+		leafIndices[string(leaf)] = append(leafIndices[string(leaf)], i)
+	}
+	for i, value := range values {
+		leaf, err := StandardLeafHash(leafEncoding, value)
+		if err != nil {
+			return nil, err
+		}
+		// The equal values are assigned to the equal leaves in ascending order, as in StandardMerkleTree.of.
+		indices := leafIndices[string(leaf)]
+		if len(indices) == 0 {
+			return nil, ErrNotStandardTree
+		}
+		leafIndices[string(leaf)] = indices[1:]
+		t.Values[i] = StandardValue{Value: value, TreeIndex: len(t.Tree) - 1 - indices[0]}
+	}
+	for i := len(t.Tree) - 1 - m.NumLeaves; i >= 0; i-- {
+		t.Tree[i] = keccak256(concatSortHash(t.Tree[2*i+1], t.Tree[2*i+2]))
+	}
+	// The roots differ if the tree is not hashed with Keccak-256.
+	if !bytes.Equal(t.Root(), m.Root) {
+		return nil, ErrNotStandardTree
+	}
+	return t.Dump()
+}
+
+// NewFromStandardDump loads the JSON dump of a StandardMerkleTree as a MerkleTree in ModeProofGenAndTreeBuild
+// with NewStandardTreeConfig, which is the reverse of DumpStandard. The number of values must be a power of two,
+// so that the trees have the same nodes, otherwise ErrStandardTreeLeafCount is returned. It also returns the values in the order of the dump and the leaf encoding.
+// The proof of a value is the proof of its StandardLeafHash, e.g. by Proof(BytesBlock(leaf)).
+func NewFromStandardDump(data []byte) (m *MerkleTree, values [][]any, leafEncoding []string, err error) {
+	t, err := LoadStandardMerkleTree(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	numLeaves := len(t.Values)
+	if numLeaves&(numLeaves-1) != 0 {
+		return nil, nil, nil, ErrStandardTreeLeafCount
+	}
+	blocks := make([]DataBlock, numLeaves)
+	for i := range blocks {
+		blocks[i] = BytesBlock(t.Tree[len(t.Tree)-1-i])
+	}
+	config := NewStandardTreeConfig()
+	config.Mode = ModeProofGenAndTreeBuild
+	if m, err = New(config, blocks); err != nil {
+		return nil, nil, nil, err
+	}
+	if !bytes.Equal(m.Root, t.Root()) {
+		return nil, nil, nil, ErrNotStandardTree
+	}
+	values = make([][]any, numLeaves)
+	for i, value := range t.Values {
+		values[i] = value.Value
+	}
+	return m, values, t.LeafEncoding, nil
+}
@@ -0,0 +1,259 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// ozValues are the values of the README example of @openzeppelin/merkle-tree.
+var ozValues = [][]any{
+	{"0x1111111111111111111111111111111111111111", "5000000000000000000"},
+	{"0x2222222222222222222222222222222222222222", "2500000000000000000"},
+}
+
+// ozRoot is the root of ozValues computed by @openzeppelin/merkle-tree.
+const ozRoot = "d4dee0beab2d53f2cc83e567171bd2820e49898130a22622b10ead383e90bd77"
+
+func TestNewStandardMerkleTree(t *testing.T) {
+	tree, err := NewStandardMerkleTree(ozValues, []string{"address", "uint256"})
+	if err != nil {
+		t.Fatalf("NewStandardMerkleTree() error = %v", err)
+	}
+	if got := hex.EncodeToString(tree.Root()); got != ozRoot {
+		t.Fatalf("Root() = %s, want %s", got, ozRoot)
+	}
+	for i, value := range ozValues {
+		proof, err := tree.Proof(i)
+		if err != nil {
+			t.Fatalf("Proof() error = %v", err)
+		}
+		ok, err := VerifyStandardProof(tree.Root(), tree.LeafEncoding, value, proof)
+		if err != nil || !ok {
+			t.Errorf("VerifyStandardProof() = %v, %v", ok, err)
+		}
+	}
+	if _, err = tree.Proof(2); !errors.Is(err, ErrValueIndexOutOfRange) {
+		t.Errorf("Proof() error = %v, want %v", err, ErrValueIndexOutOfRange)
+	}
+	// As getMultiProof, a value cannot be proven twice.
+	if _, err = tree.MultiProof([]int{1, 0, 1}); !errors.Is(err, ErrMultiProofDuplicateLeaf) {
+		t.Errorf("MultiProof() error = %v, want %v", err, ErrMultiProofDuplicateLeaf)
+	}
+}
+
+func TestStandardMerkleTree_matchesMerkleTree(t *testing.T) {
+	for _, numValues := range []int{2, 3, 7, 8, 16} {
+		values := make([][]any, numValues)
+		for i := range values {
+			values[i] = []any{fmt.Sprintf("0x%040x", i+1), i * 1000, fmt.Sprintf("value %d", i), i%2 == 0}
+		}
+		leafEncoding := []string{"address", "uint256", "string", "bool"}
+		tree, err := NewStandardMerkleTree(values, leafEncoding)
+		if err != nil {
+			t.Fatalf("NewStandardMerkleTree() error = %v", err)
+		}
+		for i, value := range values {
+			proof, err := tree.Proof(i)
+			if err != nil {
+				t.Fatalf("Proof() error = %v", err)
+			}
+			ok, err := VerifyStandardProof(tree.Root(), leafEncoding, value, proof)
+			if err != nil || !ok {
+				t.Fatalf("VerifyStandardProof() = %v, %v", ok, err)
+			}
+		}
+		valueIndices := []int{0, numValues - 1}
+		if numValues > 2 {
+			valueIndices = append(valueIndices, numValues/2)
+		}
+		proof, err := tree.MultiProof(valueIndices)
+		if err != nil {
+			t.Fatalf("MultiProof() error = %v", err)
+		}
+		proven := make([][]any, len(proof.Indices))
+		for i, valueIdx := range proof.Indices {
+			proven[i] = values[valueIdx]
+		}
+		ok, err := VerifyStandardMultiProof(tree.Root(), leafEncoding, proven, proof)
+		if err != nil || !ok {
+			t.Fatalf("VerifyStandardMultiProof() = %v, %v", ok, err)
+		}
+		if numValues&(numValues-1) != 0 {
+			continue
+		}
+
+		// With a power-of-two number of values, the sorted leaves give the same root as New.
+		blocks := make([]DataBlock, numValues)
+		for i := range blocks {
+			blocks[i] = BytesBlock(tree.Tree[len(tree.Tree)-1-i])
+		}
+		m, err := New(NewStandardTreeConfig(), blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if !bytes.Equal(m.Root, tree.Root()) {
+			t.Errorf("root mismatch, got %x, want %x", m.Root, tree.Root())
+		}
+	}
+}
+
+func TestStandardMerkleTree_DumpLoad(t *testing.T) {
+	tree, err := NewStandardMerkleTree(ozValues, []string{"address", "uint256"})
+	if err != nil {
+		t.Fatalf("NewStandardMerkleTree() error = %v", err)
+	}
+	dump, err := tree.Dump()
+	if err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(dump, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["format"]) != `"standard-v1"` || string(fields["leafEncoding"]) != `["address","uint256"]` {
+		t.Errorf("unexpected dump %s", dump)
+	}
+	loaded, err := LoadStandardMerkleTree(dump)
+	if err != nil {
+		t.Fatalf("LoadStandardMerkleTree() error = %v", err)
+	}
+	if got := hex.EncodeToString(loaded.Root()); got != ozRoot {
+		t.Errorf("Root() = %s, want %s", got, ozRoot)
+	}
+	redump, err := loaded.Dump()
+	if err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	if !bytes.Equal(redump, dump) {
+		t.Errorf("Dump() after load = %s, want %s", redump, dump)
+	}
+
+	tampered := bytes.Replace(dump, []byte("5000000000000000000"), []byte("5000000000000000001"), 1)
+	if _, err = LoadStandardMerkleTree(tampered); !errors.Is(err, ErrInvalidStandardTree) {
+		t.Errorf("LoadStandardMerkleTree() error = %v, want %v", err, ErrInvalidStandardTree)
+	}
+	if _, err = LoadStandardMerkleTree([]byte(`{"format":"standard-v2","tree":["0x00"]}`)); !errors.Is(err, ErrInvalidStandardTree) {
+		t.Errorf("LoadStandardMerkleTree() error = %v, want %v", err, ErrInvalidStandardTree)
+	}
+}
+
+func TestMerkleTree_DumpStandard(t *testing.T) {
+	values := make([][]any, 8)
+	for i := range values {
+		values[i] = []any{fmt.Sprintf("0x%040x", i+1), i * 1000}
+	}
+	values[5] = values[2]
+	leafEncoding := []string{"address", "uint256"}
+	want, err := NewStandardMerkleTree(values, leafEncoding)
+	if err != nil {
+		t.Fatalf("NewStandardMerkleTree() error = %v", err)
+	}
+	wantDump, err := want.Dump()
+	if err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+
+	// The tree of the sorted leaves, as built by StandardMerkleTree.of.
+	leaves := make([][]byte, len(values))
+	for i, value := range values {
+		if leaves[i], err = StandardLeafHash(leafEncoding, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		return bytes.Compare(leaves[i], leaves[j]) < 0
+	})
+	blocks := make([]DataBlock, len(leaves))
+	for i, leaf := range leaves {
+		blocks[i] = BytesBlock(leaf)
+	}
+	m, err := New(NewStandardTreeConfig(), blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	dump, err := m.DumpStandard(values, leafEncoding)
+	if err != nil {
+		t.Fatalf("DumpStandard() error = %v", err)
+	}
+	if !bytes.Equal(dump, wantDump) {
+		t.Fatalf("DumpStandard() = %s, want %s", dump, wantDump)
+	}
+
+	loaded, loadedValues, loadedEncoding, err := NewFromStandardDump(dump)
+	if err != nil {
+		t.Fatalf("NewFromStandardDump() error = %v", err)
+	}
+	if !bytes.Equal(loaded.Root, want.Root()) {
+		t.Fatalf("root = %x, want %x", loaded.Root, want.Root())
+	}
+	for i, value := range values {
+		wantProof, err := want.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := loaded.ProofByIndex(len(want.Tree) - 1 - want.Values[i].TreeIndex)
+		if err != nil {
+			t.Fatalf("ProofByIndex() error = %v", err)
+		}
+		if !reflect.DeepEqual(proof.Siblings, wantProof) {
+			t.Fatalf("proof %d = %x, want %x", i, proof.Siblings, wantProof)
+		}
+		if ok, err := VerifyStandardProof(loaded.Root, leafEncoding, value, proof.Siblings); err != nil || !ok {
+			t.Fatalf("VerifyStandardProof() = %v, %v", ok, err)
+		}
+	}
+	redump, err := loaded.DumpStandard(loadedValues, loadedEncoding)
+	if err != nil {
+		t.Fatalf("DumpStandard() error = %v", err)
+	}
+	if !bytes.Equal(redump, wantDump) {
+		t.Errorf("DumpStandard() after load = %s, want %s", redump, wantDump)
+	}
+
+	if _, err = m.DumpStandard(values[:7], leafEncoding); !errors.Is(err, ErrNotStandardTree) {
+		t.Errorf("DumpStandard() error = %v, want %v", err, ErrNotStandardTree)
+	}
+	other := append([][]any{{"0x0000000000000000000000000000000000000009", 0}}, values[1:]...)
+	if _, err = m.DumpStandard(other, leafEncoding); !errors.Is(err, ErrNotStandardTree) {
+		t.Errorf("DumpStandard() error = %v, want %v", err, ErrNotStandardTree)
+	}
+	unsorted, err := New(&Config{HashFunc: Keccak256HashFuncParallel, DisableLeafHashing: true}, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = unsorted.DumpStandard(values, leafEncoding); !errors.Is(err, ErrNotStandardTree) {
+		t.Errorf("DumpStandard() error = %v, want %v", err, ErrNotStandardTree)
+	}
+	sha256Tree, err := New(&Config{SortSiblingPairs: true, DisableLeafHashing: true}, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sha256Tree.DumpStandard(values, leafEncoding); !errors.Is(err, ErrNotStandardTree) {
+		t.Errorf("DumpStandard() error = %v, want %v", err, ErrNotStandardTree)
+	}
+	odd, err := NewStandardMerkleTree(values[:7], leafEncoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oddDump, err := odd.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err = NewFromStandardDump(oddDump); !errors.Is(err, ErrStandardTreeLeafCount) {
+		t.Errorf("NewFromStandardDump() error = %v, want %v", err, ErrStandardTreeLeafCount)
+	}
+	oddTree, err := New(NewStandardTreeConfig(), blocks[:7])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = oddTree.DumpStandard(values[:7], leafEncoding); !errors.Is(err, ErrStandardTreeLeafCount) ||
+		!errors.Is(err, ErrNotStandardTree) {
+		t.Errorf("DumpStandard() error = %v, want %v", err, ErrStandardTreeLeafCount)
+	}
+}
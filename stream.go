@@ -0,0 +1,242 @@
+package merkletree
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// StreamBuilder computes the Merkle root from data blocks added one at a time.
+// It keeps only one pending node per tree level, so the memory usage is O(log n) for n data blocks,
+// and produces the same Root as New with the same configuration.
+// With a node store, it also writes the nodes to the store as they are computed, so that a tree
+// larger than the memory, e.g. of a 64 GiB piece, can be built into a FileNodeStore.
+type StreamBuilder struct {
+	config Config
+	// concatHashFunc is the function for concatenating two hashes.
+	concatHashFunc typeConcatHashFunc
+	// store is the storage of the nodes, or nil if only the root is computed.
+	store NodeStore
+	// pending[i] is the left node at level i waiting for its right sibling.
+	// It is only valid if the bit i of numLeaves is set.
+	pending [][]byte
+	// numLeaves is the number of leaves added to the builder.
+	numLeaves int
+}
+
+// NewStreamBuilder creates a StreamBuilder with the specified configuration.
+// The Mode, RunInParallel and NumRoutines settings are ignored, as the root is computed sequentially.
+func NewStreamBuilder(config *Config) (*StreamBuilder, error) {
+	s := &StreamBuilder{
+		config: *copyConfig(config),
+	}
+	s.concatHashFunc = concatFuncOf(&s.config)
+	if s.config.Arity > 2 {
+		return nil, ErrUnsupportedArity
+	}
+	if s.config.RFC6962 {
+		if err := checkRFC6962Config(&s.config); err != nil {
+			return nil, err
+		}
+	}
+	if !s.config.Duplicates && s.config.ZeroPadding && s.config.Padding[0] == nil {
+		var err error
+		if s.config.Padding, err = ZeroPaddingTable(&s.config); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewStreamBuilderWithNodeStore creates a StreamBuilder which also stores the nodes in the node store,
+// in the same layout as NewWithNodeStore, so that Tree returns the Merkle Tree with the proofs served
+// from the store. Each builder needs its own node store.
+func NewStreamBuilderWithNodeStore(config *Config, store NodeStore) (*StreamBuilder, error) {
+	if store == nil {
+		return nil, ErrNodeStoreIsNil
+	}
+	s, err := NewStreamBuilder(config)
+	if err != nil {
+		return nil, err
+	}
+	s.store = store
+	return s, nil
+}
+
+// NumLeaves returns the number of leaves added to the builder.
+func (s *StreamBuilder) NumLeaves() int {
+	return s.numLeaves
+}
+
+// Add adds a data block as the next leaf of the tree.
+func (s *StreamBuilder) Add(block DataBlock) error {
+	if block == nil {
+		return ErrDataBlockIsNil
+	}
+	leaf, err := dataBlockToLeaf(block, &s.config)
+	if err != nil {
+		return err
+	}
+	return s.AddLeaf(leaf)
+}
+
+// AddLeaf adds a leaf hash as the next leaf of the tree. The leaf is used as-is without hashing.
+func (s *StreamBuilder) AddLeaf(leaf []byte) error {
+	if s.numLeaves == 1<<MaxDepth {
+		return ErrTooManyDataBlocks
+	}
+	if s.store != nil {
+		// The first node of a level is the root while the number of leaves is a power of two,
+		// so it is only stored once the tree grows over it.
+		if s.numLeaves > 1 && s.numLeaves&(s.numLeaves-1) == 0 {
+			level := bits.TrailingZeros(uint(s.numLeaves))
+			if err := s.store.Put(level, 0, s.pending[level]); err != nil {
+				return err
+			}
+		}
+		if err := s.store.Put(0, s.numLeaves, leaf); err != nil {
+			return err
+		}
+	}
+	node := leaf
+	level := 0
+	// Merge the completed pairs, like incrementing a binary counter.
+	for ; s.numLeaves>>level&1 == 1; level++ {
+		var err error
+		if node, err = s.config.HashFunc(s.concatHashFunc(s.pending[level], node)); err != nil {
+			return err
+		}
+		if idx := s.numLeaves >> (level + 1); s.store != nil && idx > 0 {
+			if err = s.store.Put(level+1, idx, node); err != nil {
+				return err
+			}
+		}
+	}
+	if level == len(s.pending) {
+		s.pending = append(s.pending, nil)
+	}
+	s.pending[level] = node
+	s.numLeaves++
+	return nil
+}
+
+// AddFromReader splits the data read from r into data blocks of blockSize bytes and adds them
+// to the builder until io.EOF. If the data length is not a multiple of blockSize, the last data block
+// is shorter. It returns the number of data blocks added.
+func (s *StreamBuilder) AddFromReader(r io.Reader, blockSize int) (int, error) {
+	if blockSize <= 0 {
+		return 0, ErrInvalidBlockSize
+	}
+	var (
+		buffer    = make([]byte, blockSize)
+		numBlocks int
+	)
+	for {
+		n, err := io.ReadFull(r, buffer)
+		if n > 0 {
+			// The leaf is either hashed or copied, so the buffer can be reused.
+			if addErr := s.Add(BytesBlock(buffer[:n])); addErr != nil {
+				return numBlocks, addErr
+			}
+			numBlocks++
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return numBlocks, nil
+		}
+		if err != nil {
+			return numBlocks, err
+		}
+	}
+}
+
+// Root computes the Merkle root of the leaves added so far.
+// The odd nodes are handled in the same way as New, so the builder can keep accepting leaves afterwards.
+func (s *StreamBuilder) Root() ([]byte, error) {
+	return s.root(nil)
+}
+
+// Tree stores the last nodes of each level, which depend on the number of leaves, in the node store
+// and returns the Merkle Tree of the leaves added so far. The tree is in ModeTreeBuild and reads its leaves
+// from the node store, like a tree restored by NewFromNodeStore. The builder can keep accepting leaves afterwards,
+// but the tree must not be used once more leaves are added, as the last nodes of the levels are overwritten.
+func (s *StreamBuilder) Tree() (*MerkleTree, error) {
+	if s.store == nil {
+		return nil, ErrNodeStoreIsNil
+	}
+	if _, err := s.root(s.store); err != nil {
+		return nil, err
+	}
+	return NewFromNodeStore(&s.config, s.store, s.numLeaves)
+}
+
+// root computes the Merkle root of the leaves added so far. If the node store is not nil,
+// the last nodes of each level, i.e. the nodes of the incomplete subtrees and the padding nodes, are stored.
+func (s *StreamBuilder) root(store NodeStore) ([]byte, error) {
+	if s.numLeaves <= 1 {
+		return nil, ErrInvalidNumOfDataBlocks
+	}
+	var (
+		depth = bits.Len(uint(s.numLeaves - 1))
+		// carry is the last node of the current level produced by the lower levels.
+		carry []byte
+		err   error
+	)
+	for level := 0; level < depth; level++ {
+		hasPending := s.numLeaves>>level&1 == 1
+		if store != nil {
+			if err = s.storeLastNodes(store, level, hasPending, carry); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case hasPending && carry != nil:
+			carry, err = s.config.HashFunc(s.concatHashFunc(s.pending[level], carry))
+		case s.config.RFC6962:
+			// The odd node is promoted to the next level without padding.
+			if hasPending {
+				carry = s.pending[level]
+			}
+		case hasPending:
+			carry, err = s.config.HashFunc(s.concatHashFunc(s.pending[level], s.paddingNode(s.pending[level], level)))
+		case carry != nil:
+			carry, err = s.config.HashFunc(s.concatHashFunc(carry, s.paddingNode(carry, level)))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if carry == nil {
+		// The number of leaves is a power of two.
+		return s.pending[depth], nil
+	}
+	return carry, nil
+}
+
+// storeLastNodes stores the nodes after the complete subtrees of a level, i.e. the carry and the padding node.
+// The complete subtrees are already stored by AddLeaf.
+func (s *StreamBuilder) storeLastNodes(store NodeStore, level int, hasPending bool, carry []byte) error {
+	idx := s.numLeaves >> level
+	if carry != nil {
+		if err := store.Put(level, idx, carry); err != nil {
+			return err
+		}
+	}
+	if s.config.RFC6962 {
+		return nil
+	}
+	switch {
+	case hasPending && carry == nil:
+		return store.Put(level, idx, s.paddingNode(s.pending[level], level))
+	case !hasPending && carry != nil:
+		return store.Put(level, idx+1, s.paddingNode(carry, level))
+	}
+	return nil
+}
+
+// paddingNode returns the node appended after the last node of an odd level, in the same way as fixOddLength.
+func (s *StreamBuilder) paddingNode(last []byte, level int) []byte {
+	if s.config.Duplicates || s.config.Padding[0] == nil {
+		return last
+	}
+	return s.config.Padding[level]
+}
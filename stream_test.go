@@ -0,0 +1,296 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStreamBuilder_Root(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+	}{
+		{
+			name: "test_default",
+		},
+		{
+			name:   "test_duplicates",
+			config: &Config{Duplicates: true},
+		},
+		{
+			name:   "test_sorted",
+			config: &Config{SortSiblingPairs: true},
+		},
+		{
+			name:   "test_zero_padding",
+			config: &Config{ZeroPadding: true},
+		},
+		{
+			name:   "test_disable_leaf_hashing",
+			config: &Config{DisableLeafHashing: true},
+		},
+		{
+			name:   "test_commp",
+			config: NewCommPConfig(false),
+		},
+	}
+	blocks := generatedTestDataBlocks(70)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewStreamBuilder(tt.config)
+			if err != nil {
+				t.Fatalf("NewStreamBuilder() error = %v", err)
+			}
+			if err := s.Add(blocks[0]); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if _, err := s.Root(); !errors.Is(err, ErrInvalidNumOfDataBlocks) {
+				t.Errorf("Root() error = %v, want %v", err, ErrInvalidNumOfDataBlocks)
+			}
+			for n := 2; n <= len(blocks); n++ {
+				if err := s.Add(blocks[n-1]); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+				got, err := s.Root()
+				if err != nil {
+					t.Fatalf("Root() error = %v", err)
+				}
+				m, err := New(tt.config, blocks[:n])
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				if !bytes.Equal(got, m.Root) {
+					t.Fatalf("%d leaves: root mismatch, got %x, want %x", n, got, m.Root)
+				}
+			}
+			if s.NumLeaves() != len(blocks) {
+				t.Errorf("NumLeaves() = %d, want %d", s.NumLeaves(), len(blocks))
+			}
+		})
+	}
+}
+
+func TestStreamBuilder_Tree(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+	}{
+		{
+			name:   "test_default",
+			config: &Config{},
+		},
+		{
+			name:   "test_duplicates",
+			config: &Config{Duplicates: true},
+		},
+		{
+			name:   "test_zero_padding",
+			config: &Config{ZeroPadding: true},
+		},
+		{
+			name:   "test_rfc6962",
+			config: &Config{RFC6962: true},
+		},
+		{
+			name:   "test_commp",
+			config: NewCommPConfig(false),
+		},
+	}
+	blocks := generatedTestDataBlocks(70)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryNodeStore()
+			s, err := NewStreamBuilderWithNodeStore(tt.config, store)
+			if err != nil {
+				t.Fatalf("NewStreamBuilderWithNodeStore() error = %v", err)
+			}
+			if err := s.Add(blocks[0]); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			// The tree is built after every leaf, so the last nodes of the levels are overwritten.
+			for n := 2; n <= len(blocks); n++ {
+				if err := s.Add(blocks[n-1]); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+				got, err := s.Tree()
+				if err != nil {
+					t.Fatalf("Tree() error = %v", err)
+				}
+				config := *tt.config
+				config.Mode = ModeTreeBuild
+				want, err := New(&config, blocks[:n])
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				if !bytes.Equal(got.Root, want.Root) || got.Leaves != nil {
+					t.Fatalf("%d leaves: root mismatch, got %x, want %x", n, got.Root, want.Root)
+				}
+				for level := 0; level < want.Depth; level++ {
+					wantLevel, _ := getLevel(want.NodeStore, level)
+					for idx, node := range wantLevel {
+						if gotNode, err := store.Get(level, idx); err != nil || !bytes.Equal(gotNode, node) {
+							t.Fatalf("%d leaves: node (%d, %d) = %x, %v, want %x", n, level, idx, gotNode, err, node)
+						}
+					}
+				}
+				proof, err := got.ProofByIndex(n - 1)
+				if err != nil {
+					t.Fatalf("ProofByIndex() error = %v", err)
+				}
+				if ok, err := Verify(blocks[n-1], proof, got.Root, tt.config); err != nil || !ok {
+					t.Fatalf("%d leaves: Verify() = %v, %v", n, ok, err)
+				}
+			}
+		})
+	}
+
+	if _, err := NewStreamBuilderWithNodeStore(nil, nil); !errors.Is(err, ErrNodeStoreIsNil) {
+		t.Errorf("NewStreamBuilderWithNodeStore() error = %v, want %v", err, ErrNodeStoreIsNil)
+	}
+	s, err := NewStreamBuilder(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Tree(); !errors.Is(err, ErrNodeStoreIsNil) {
+		t.Errorf("Tree() error = %v, want %v", err, ErrNodeStoreIsNil)
+	}
+}
+
+func TestStreamBuilder_TreeFileNodeStore(t *testing.T) {
+	data := make([]byte, 50000)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := Fr32DataBlocks(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := New(NewCommPConfig(false), blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The leaves are streamed from the reader into the files, without keeping the piece in memory.
+	dir := t.TempDir()
+	store, err := NewFileNodeStore(dir, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStreamBuilderWithNodeStore(NewCommPConfig(false), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.AddFromReader(NewFr32Reader(bytes.NewReader(data)), Fr32LeafSize); err != nil {
+		t.Fatalf("AddFromReader() error = %v", err)
+	}
+	if _, err = s.Tree(); err != nil {
+		t.Fatalf("Tree() error = %v", err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if store, err = OpenFileNodeStore(dir, 32); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	m, err := NewFromNodeStore(NewCommPConfig(false), store, s.NumLeaves())
+	if err != nil {
+		t.Fatalf("NewFromNodeStore() error = %v", err)
+	}
+	if !bytes.Equal(m.Root, want.Root) {
+		t.Fatalf("root mismatch, got %x, want %x", m.Root, want.Root)
+	}
+	for i := 0; i < m.NumLeaves; i += 97 {
+		proof, err := m.ProofByIndex(i)
+		if err != nil {
+			t.Fatalf("ProofByIndex() error = %v", err)
+		}
+		if !reflect.DeepEqual(proof, want.Proofs[i]) {
+			t.Fatalf("ProofByIndex() %d got = %v, want %v", i, proof, want.Proofs[i])
+		}
+		if ok, err := Verify(blocks[i], proof, want.Root, NewCommPConfig(false)); err != nil || !ok {
+			t.Fatalf("Verify(%d) = %v, %v", i, ok, err)
+		}
+	}
+}
+
+func TestStreamBuilder_AddFromReader(t *testing.T) {
+	data := make([]byte, 5000)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := Fr32DataBlocks(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(NewCommPConfig(false), blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStreamBuilder(NewCommPConfig(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The Fr32 reader only pads the data to a multiple of 127 bytes, the rest of the
+	// piece is covered by the zero padding.
+	numBlocks, err := s.AddFromReader(NewFr32Reader(bytes.NewReader(data)), Fr32LeafSize)
+	if err != nil {
+		t.Fatalf("AddFromReader() error = %v", err)
+	}
+	if want := (len(data) + 126) / 127 * 4; numBlocks != want {
+		t.Errorf("AddFromReader() = %d, want %d", numBlocks, want)
+	}
+	root, err := s.Root()
+	if err != nil {
+		t.Fatalf("Root() error = %v", err)
+	}
+	if !bytes.Equal(root, m.Root) {
+		t.Errorf("root mismatch, got %x, want %x", root, m.Root)
+	}
+
+	if _, err := s.AddFromReader(bytes.NewReader(data), 0); !errors.Is(err, ErrInvalidBlockSize) {
+		t.Errorf("AddFromReader() error = %v, want %v", err, ErrInvalidBlockSize)
+	}
+}
+
+func TestStreamBuilder_hashFuncError(t *testing.T) {
+	s, err := NewStreamBuilder(&Config{
+		HashFunc: func([]byte) ([]byte, error) {
+			return nil, errors.New("test_hash_func_err")
+		},
+		DisableLeafHashing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddLeaf([]byte("leaf_0")); err != nil {
+		t.Fatalf("AddLeaf() error = %v", err)
+	}
+	if err := s.AddLeaf([]byte("leaf_1")); err == nil {
+		t.Errorf("AddLeaf() error = nil, want error")
+	}
+}
+
+func BenchmarkStreamBuilder(b *testing.B) {
+	testCases := generatedTestDataBlocks(benchSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, err := NewStreamBuilder(nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, block := range testCases {
+			if err := s.Add(block); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if _, err := s.Root(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package merkletree
+
+import "math/bits"
+
+// Subtree is the root of an equal-sized subtree of a larger tree, such as a shard built on another machine.
+type Subtree struct {
+	// Root is the root of the subtree.
+	Root []byte
+	// Depth is the depth of the subtree, which has 2^Depth leaves after padding.
+	Depth int
+}
+
+// SubtreeFromTree returns the Subtree of a Merkle Tree built for a shard of the leaves.
+// All the shards must have the same depth, so only the last shard may have fewer than 2^Depth leaves.
+func SubtreeFromTree(m *MerkleTree) (Subtree, error) {
+	if m == nil {
+		return Subtree{}, ErrMerkleTreeIsNil
+	}
+	if m.Arity > 2 {
+		return Subtree{}, ErrUnsupportedArity
+	}
+	if m.RFC6962 {
+		return Subtree{}, ErrUnsupportedRFC6962
+	}
+	return Subtree{Root: m.Root, Depth: m.Depth}, nil
+}
+
+// SubtreeFromLevelCache returns the Subtree of a LevelCache reaching the root of its tree,
+// whose top level holds the two children of the root.
+func SubtreeFromLevelCache(lc *LevelCache, config *Config) (Subtree, error) {
+	if lc == nil || lc.Level < 1 || lc.numLevels() != lc.Level || lc.levelLen(lc.Level-1) != 2 {
+		return Subtree{}, ErrLevelCacheLevel
+	}
+	config = copyConfig(config)
+	if config.Arity > 2 {
+		return Subtree{}, ErrUnsupportedArity
+	}
+	if config.RFC6962 {
+		return Subtree{}, ErrUnsupportedRFC6962
+	}
+	concatFunc := concatFuncOf(config)
+	root, err := config.HashFunc(concatFunc(lc.node(lc.Level-1, 0), lc.node(lc.Level-1, 1)))
+	if err != nil {
+		return Subtree{}, err
+	}
+	return Subtree{Root: root, Depth: lc.Start + lc.Level}, nil
+}
+
+// TopTree is the upper part of a Merkle Tree built from the roots of equal-sized subtrees.
+// The proof of a leaf in the full tree is the proof of the leaf in its subtree followed by the proof
+// of the subtree root in the TopTree, and they are combined by AppendProof.
+type TopTree struct {
+	// Root is the root of the full tree.
+	Root []byte
+	// Depth is the depth of the full tree.
+	Depth int
+	// SubtreeDepth is the depth of the subtrees.
+	SubtreeDepth int
+	// Proofs are the proofs of the subtree roots in the full tree.
+	// The path bits start from SubtreeDepth, so that they follow the path bits of a subtree proof.
+	Proofs []*Proof
+}
+
+// NewTopTree builds the TopTree from the subtrees of the same depth, ordered by their position in the full tree.
+// The configuration must be the one of the full tree. If the number of subtrees is not a power of two,
+// the odd subtree roots are handled with the padding table offset by the subtree depth, or duplicated
+// if the padding table is empty.
+func NewTopTree(config *Config, subtrees []Subtree) (*TopTree, error) {
+	if len(subtrees) == 0 {
+		return nil, ErrInvalidNumOfDataBlocks
+	}
+	if arityOf(config) > 2 {
+		return nil, ErrUnsupportedArity
+	}
+	if config != nil && config.RFC6962 {
+		return nil, ErrUnsupportedRFC6962
+	}
+	subtreeDepth := subtrees[0].Depth
+	if subtreeDepth <= 0 || subtreeDepth >= int(MaxDepth) {
+		return nil, ErrInvalidSubtreeDepth
+	}
+	for _, subtree := range subtrees {
+		if subtree.Depth != subtreeDepth {
+			return nil, ErrSubtreeDepthMismatch
+		}
+	}
+	topDepth := bits.Len(uint(len(subtrees) - 1))
+	if subtreeDepth+topDepth > int(MaxDepth) {
+		return nil, ErrTooManyDataBlocks
+	}
+	t := &TopTree{
+		Depth:        subtreeDepth + topDepth,
+		SubtreeDepth: subtreeDepth,
+	}
+	if len(subtrees) == 1 {
+		t.Root = subtrees[0].Root
+		t.Proofs = []*Proof{{}}
+		return t, nil
+	}
+
+	topConfig := copyConfig(config)
+	if !topConfig.Duplicates && topConfig.ZeroPadding && topConfig.Padding[0] == nil {
+		padding, err := ZeroPaddingTable(topConfig)
+		if err != nil {
+			return nil, err
+		}
+		topConfig.Padding = padding
+	}
+	var topPadding [MaxDepth][]byte
+	if topConfig.Padding[0] != nil {
+		copy(topPadding[:], topConfig.Padding[subtreeDepth:])
+	}
+	topConfig.Padding = topPadding
+	topConfig.ZeroPadding = false
+	topConfig.DisableLeafHashing = true
+	topConfig.Mode = ModeProofGen
+	topConfig.ContiguousNodes = false
+
+	blocks := make([]DataBlock, len(subtrees))
+	for i, subtree := range subtrees {
+		blocks[i] = BytesBlock(subtree.Root)
+	}
+	m, err := New(topConfig, blocks)
+	if err != nil {
+		return nil, err
+	}
+	t.Root = m.Root
+	t.Proofs = m.Proofs
+	for _, proof := range t.Proofs {
+		proof.Path <<= uint(subtreeDepth)
+	}
+	return t, nil
+}
+
+// Proof returns a copy of the proof of the subtree root at the given index in the full tree.
+func (t *TopTree) Proof(subtreeIdx int) (*Proof, error) {
+	if subtreeIdx < 0 || subtreeIdx >= len(t.Proofs) {
+		return nil, ErrSubtreeIndexOutOfRange
+	}
+	proof := t.Proofs[subtreeIdx]
+	return &Proof{
+		Siblings: append([][]byte(nil), proof.Siblings...),
+		Path:     proof.Path,
+	}, nil
+}
+
+// LeafProof returns the proof of a leaf in the full tree from its proof in the subtree at the given index.
+// The subtree proof is not modified.
+func (t *TopTree) LeafProof(subtreeIdx int, subtreeProof *Proof) (*Proof, error) {
+	if subtreeProof == nil {
+		return nil, ErrProofIsNil
+	}
+	if len(subtreeProof.Siblings) != t.SubtreeDepth {
+		return nil, ErrSubtreeDepthMismatch
+	}
+	topProof, err := t.Proof(subtreeIdx)
+	if err != nil {
+		return nil, err
+	}
+	base := &Proof{
+		Siblings: append([][]byte(nil), subtreeProof.Siblings...),
+		Path:     subtreeProof.Path,
+	}
+	return AppendProof(base, *topProof)
+}
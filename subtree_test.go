@@ -0,0 +1,160 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// buildShards builds a subtree for every shard of 2^subtreeDepth blocks with the configuration.
+func buildShards(t *testing.T, config *Config, blocks []DataBlock, subtreeDepth int) ([]*MerkleTree, []Subtree) {
+	t.Helper()
+	var (
+		shards    []*MerkleTree
+		subtrees  []Subtree
+		shardSize = 1 << subtreeDepth
+	)
+	for start := 0; start < len(blocks); start += shardSize {
+		end := start + shardSize
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		shard, err := New(config, blocks[start:end])
+		if err != nil {
+			t.Fatalf("New() shard error = %v", err)
+		}
+		subtree, err := SubtreeFromTree(shard)
+		if err != nil {
+			t.Fatalf("SubtreeFromTree() error = %v", err)
+		}
+		shards = append(shards, shard)
+		subtrees = append(subtrees, subtree)
+	}
+	return shards, subtrees
+}
+
+func TestNewTopTree(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       *Config
+		numBlocks    int
+		subtreeDepth int
+	}{
+		{name: "test_power_of_two", config: &Config{}, numBlocks: 64, subtreeDepth: 3},
+		{name: "test_odd_subtrees_duplicates", config: &Config{}, numBlocks: 5 * 8, subtreeDepth: 3},
+		{name: "test_odd_subtrees_zero_padding", config: &Config{ZeroPadding: true}, numBlocks: 5 * 8, subtreeDepth: 3},
+		{name: "test_partial_last_shard", config: &Config{ZeroPadding: true}, numBlocks: 6*16 + 9, subtreeDepth: 4},
+		{name: "test_sort_sibling_pairs", config: &Config{SortSiblingPairs: true}, numBlocks: 3 * 4, subtreeDepth: 2},
+		{name: "test_single_subtree", config: &Config{}, numBlocks: 8, subtreeDepth: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := generatedTestDataBlocks(tt.numBlocks)
+			full, err := New(tt.config, blocks)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			shards, subtrees := buildShards(t, tt.config, blocks, tt.subtreeDepth)
+			top, err := NewTopTree(tt.config, subtrees)
+			if err != nil {
+				t.Fatalf("NewTopTree() error = %v", err)
+			}
+			if !bytes.Equal(top.Root, full.Root) {
+				t.Fatalf("root mismatch, got %x, want %x", top.Root, full.Root)
+			}
+			if top.Depth != full.Depth {
+				t.Errorf("depth = %d, want %d", top.Depth, full.Depth)
+			}
+			for i, block := range blocks {
+				subtreeIdx := i >> tt.subtreeDepth
+				proof, err := top.LeafProof(subtreeIdx, shards[subtreeIdx].Proofs[i-subtreeIdx<<tt.subtreeDepth])
+				if err != nil {
+					t.Fatalf("LeafProof() error = %v", err)
+				}
+				if !reflect.DeepEqual(proof, full.Proofs[i]) {
+					t.Fatalf("proof %d mismatch, got %v, want %v", i, proof, full.Proofs[i])
+				}
+				ok, err := full.Verify(block, proof)
+				if err != nil || !ok {
+					t.Fatalf("Verify() = %v, %v", ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewTopTree_levelCaches(t *testing.T) {
+	config := &Config{Mode: ModeTreeBuild, ZeroPadding: true}
+	blocks := generatedTestDataBlocks(3 * 8)
+	full, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	shards, _ := buildShards(t, config, blocks, 3)
+	subtrees := make([]Subtree, len(shards))
+	for i, shard := range shards {
+		lc, err := NewLevelCache(shard, 1, shard.Depth-1)
+		if err != nil {
+			t.Fatalf("NewLevelCache() error = %v", err)
+		}
+		if subtrees[i], err = SubtreeFromLevelCache(lc, config); err != nil {
+			t.Fatalf("SubtreeFromLevelCache() error = %v", err)
+		}
+	}
+	top, err := NewTopTree(config, subtrees)
+	if err != nil {
+		t.Fatalf("NewTopTree() error = %v", err)
+	}
+	if !bytes.Equal(top.Root, full.Root) {
+		t.Errorf("root mismatch, got %x, want %x", top.Root, full.Root)
+	}
+
+	lc, err := NewLevelCache(shards[0], 0, 1)
+	if err != nil {
+		t.Fatalf("NewLevelCache() error = %v", err)
+	}
+	if _, err = SubtreeFromLevelCache(lc, config); !errors.Is(err, ErrLevelCacheLevel) {
+		t.Errorf("SubtreeFromLevelCache() error = %v, want %v", err, ErrLevelCacheLevel)
+	}
+}
+
+func TestNewTopTree_errors(t *testing.T) {
+	root := make([]byte, 32)
+	tests := []struct {
+		name     string
+		subtrees []Subtree
+		wantErr  error
+	}{
+		{name: "test_empty", wantErr: ErrInvalidNumOfDataBlocks},
+		{name: "test_zero_depth", subtrees: []Subtree{{Root: root}}, wantErr: ErrInvalidSubtreeDepth},
+		{
+			name:     "test_depth_mismatch",
+			subtrees: []Subtree{{Root: root, Depth: 2}, {Root: root, Depth: 3}},
+			wantErr:  ErrSubtreeDepthMismatch,
+		},
+		{
+			name:     "test_too_deep",
+			subtrees: []Subtree{{Root: root, Depth: int(MaxDepth) - 1}, {Root: root, Depth: int(MaxDepth) - 1}, {Root: root, Depth: int(MaxDepth) - 1}},
+			wantErr:  ErrTooManyDataBlocks,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTopTree(nil, tt.subtrees); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewTopTree() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	top, err := NewTopTree(nil, []Subtree{{Root: root, Depth: 2}, {Root: root, Depth: 2}})
+	if err != nil {
+		t.Fatalf("NewTopTree() error = %v", err)
+	}
+	if _, err = top.Proof(2); !errors.Is(err, ErrSubtreeIndexOutOfRange) {
+		t.Errorf("Proof() error = %v, want %v", err, ErrSubtreeIndexOutOfRange)
+	}
+	if _, err = top.LeafProof(0, &Proof{Siblings: [][]byte{root}}); !errors.Is(err, ErrSubtreeDepthMismatch) {
+		t.Errorf("LeafProof() error = %v, want %v", err, ErrSubtreeDepthMismatch)
+	}
+}
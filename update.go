@@ -0,0 +1,253 @@
+package merkletree
+
+import "sort"
+
+// nodeUpdates holds the changed nodes of a level in ascending order of their indices.
+type nodeUpdates struct {
+	indices []int
+	nodes   [][]byte
+}
+
+// Update replaces the leaf at the given index with the data block. Only the nodes on the path to the root are
+// recomputed. The leafMap and the cached proofs in ModeProofGenAndTreeBuild are updated as well.
+// This method is only available when the configuration mode is ModeTreeBuild or ModeProofGenAndTreeBuild,
+// and it must not be called concurrently with other methods of the tree.
+func (m *MerkleTree) Update(idx int, block DataBlock) error {
+	return m.UpdateBatch([]int{idx}, []DataBlock{block})
+}
+
+// UpdateBatch replaces the leaves at the given indices with the data blocks. The paths to the root are recomputed
+// level by level, so that a node shared by several paths is only computed once, and the nodes of a level are
+// computed by the worker pool if RunInParallel is true. If an index occurs more than once, the last data block wins.
+func (m *MerkleTree) UpdateBatch(indices []int, blocks []DataBlock) error {
+	if m.Mode != ModeTreeBuild && m.Mode != ModeProofGenAndTreeBuild {
+		return ErrTreeNotBuilt
+	}
+	if m.Arity > 2 {
+		return ErrUnsupportedArity
+	}
+	if len(indices) != len(blocks) {
+		return ErrUpdateMismatch
+	}
+	for _, idx := range indices {
+		if idx < 0 || idx >= m.NumLeaves {
+			return ErrLeafIndexOutOfRange
+		}
+	}
+	if len(indices) == 0 {
+		return nil
+	}
+	if m.RunInParallel {
+		m.openWorkerPool()
+		defer m.wp.Close()
+	}
+	leaves, err := m.modifiedLeaves(blocks, m.Depth)
+	if err != nil {
+		return err
+	}
+
+	// Sort the updates by index and keep the last update of each index.
+	order := make([]int, len(indices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return indices[order[i]] < indices[order[j]]
+	})
+	changed := nodeUpdates{
+		indices: make([]int, 0, len(indices)),
+		nodes:   make([][]byte, 0, len(indices)),
+	}
+	for _, i := range order {
+		if n := len(changed.indices); n > 0 && changed.indices[n-1] == indices[i] {
+			changed.nodes[n-1] = leaves[i]
+			continue
+		}
+		changed.indices = append(changed.indices, indices[i])
+		changed.nodes = append(changed.nodes, leaves[i])
+	}
+
+	// Keep the old leaves for the leafMap, as the nodes in the node store may be overwritten in place.
+	oldLeaves := make([]string, len(changed.indices))
+	for i, idx := range changed.indices {
+		leaf, err := m.leafAt(idx)
+		if err != nil {
+			return err
+		}
+		oldLeaves[i] = string(leaf)
+	}
+
+	// Compute the changed nodes before modifying the tree, so that the tree is unchanged if the hashing fails.
+	levels, root, err := m.updateLevels(changed)
+	if err != nil {
+		return err
+	}
+	for i, level := range levels {
+		for j, idx := range level.indices {
+			if err = m.NodeStore.Put(i, idx, level.nodes[j]); err != nil {
+				return err
+			}
+		}
+	}
+	if len(m.Leaves) == m.NumLeaves {
+		for i, idx := range changed.indices {
+			if m.ContiguousNodes {
+				// Keep the leaves contiguous.
+				copy(m.Leaves[idx], changed.nodes[i])
+			} else {
+				m.Leaves[idx] = changed.nodes[i]
+			}
+		}
+	}
+	m.Root = root
+
+	m.leafMapMu.Lock()
+	if m.leafMap != nil {
+		for i, idx := range changed.indices {
+			m.leafMap.remove(oldLeaves[i], idx)
+			m.leafMap.add(string(changed.nodes[i]), idx)
+		}
+	}
+	m.leafMapMu.Unlock()
+
+	if m.Proofs != nil {
+		return m.updateCachedProofs(levels, m.NumLeaves)
+	}
+	return nil
+}
+
+// leafAt returns the leaf at the given index from the Leaves, or from the node store if the Leaves are not loaded.
+func (m *MerkleTree) leafAt(idx int) ([]byte, error) {
+	if len(m.Leaves) == m.NumLeaves {
+		return m.Leaves[idx], nil
+	}
+	return m.NodeStore.Get(0, idx)
+}
+
+// updateLevels computes the changed nodes of each level from the changed leaves, and the new root.
+// The parents of the changed nodes of a level are computed together, so the shared parents are computed once.
+func (m *MerkleTree) updateLevels(changed nodeUpdates) ([]nodeUpdates, []byte, error) {
+	var (
+		levels = make([]nodeUpdates, m.Depth)
+		count  = m.NumLeaves
+	)
+	for i := 0; i < m.Depth; i++ {
+		// The duplicate of the odd last node changes with it.
+		last := len(changed.indices) - 1
+		if count&1 == 1 && !m.RFC6962 && (m.Duplicates || m.Padding[0] == nil) && changed.indices[last] == count-1 {
+			changed.indices = append(changed.indices, count)
+			changed.nodes = append(changed.nodes, changed.nodes[last])
+		}
+		levels[i] = changed
+
+		// Pair each changed node with its sibling, which is either changed as well or taken from the node store.
+		var (
+			buffer   = make([][]byte, 0, len(changed.indices)<<1)
+			parents  = make([]int, 0, len(changed.indices))
+			promoted []byte
+		)
+		for j := 0; j < len(changed.indices); j++ {
+			idx := changed.indices[j]
+			node := changed.nodes[j]
+			if m.RFC6962 && idx^1 >= count {
+				// The odd last node is promoted in RFC 6962 mode.
+				promoted = node
+				continue
+			}
+			var sibling []byte
+			if j+1 < len(changed.indices) && changed.indices[j+1] == idx^1 {
+				sibling = changed.nodes[j+1]
+				j++
+			} else {
+				var err error
+				if sibling, err = m.NodeStore.Get(i, idx^1); err != nil {
+					return nil, nil, err
+				}
+			}
+			if idx&1 == 0 {
+				buffer = append(buffer, node, sibling)
+			} else {
+				buffer = append(buffer, sibling, node)
+			}
+			parents = append(parents, idx>>1)
+		}
+		nodes, err := m.computeTreeNodes(buffer, len(buffer))
+		if err != nil {
+			return nil, nil, err
+		}
+		if promoted != nil {
+			parents = append(parents, (count-1)>>1)
+			nodes = append(nodes, promoted)
+		}
+		changed = nodeUpdates{indices: parents, nodes: nodes}
+		count = (count + 1) >> 1
+	}
+	// The level above the top level only has the root.
+	return levels, changed.nodes[0], nil
+}
+
+// updateCachedProofs updates the cached proofs of the first numLeaves leaves with the changed nodes of each level.
+// A changed node is the sibling at its level in the proofs of the leaves under its sibling node.
+// The sibling of a new level, added by Append, is appended to the proof.
+// The proofs returned before may still be in use, so the changed proofs are replaced instead of modified.
+func (m *MerkleTree) updateCachedProofs(levels []nodeUpdates, numLeaves int) (err error) {
+	var (
+		stale    = make([]bool, numLeaves)
+		numStale int
+	)
+	for i, level := range levels {
+		for _, idx := range level.indices {
+			for leaf := (idx ^ 1) << i; leaf < min((idx^1+1)<<i, numLeaves); leaf++ {
+				if !stale[leaf] {
+					stale[leaf] = true
+					numStale++
+				}
+			}
+		}
+	}
+	if m.RFC6962 {
+		// The position of a sibling in an RFC 6962 proof depends on the promoted nodes below it,
+		// so the stale proofs are regenerated from the node store.
+		for leaf, ok := range stale {
+			if !ok {
+				continue
+			}
+			if m.Proofs[leaf], err = m.nodeProof(0, leaf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The copies of the stale proofs share their allocations, as in initProofs.
+	var (
+		proofs   = make([]Proof, numStale)
+		siblings = make([][]byte, numStale*m.Depth)
+		k        int
+	)
+	for leaf, ok := range stale {
+		if !ok {
+			continue
+		}
+		proofs[k].Path = m.Proofs[leaf].Path
+		proofs[k].Siblings = append(siblings[k*m.Depth:k*m.Depth:(k+1)*m.Depth], m.Proofs[leaf].Siblings...)
+		m.Proofs[leaf] = &proofs[k]
+		k++
+	}
+	for i, level := range levels {
+		for j, idx := range level.indices {
+			for leaf := (idx ^ 1) << i; leaf < min((idx^1+1)<<i, numLeaves); leaf++ {
+				proof := m.Proofs[leaf]
+				if i < len(proof.Siblings) {
+					proof.Siblings[i] = level.nodes[j]
+					continue
+				}
+				if idx&1 == 1 {
+					proof.Path += 1 << i
+				}
+				proof.Siblings = append(proof.Siblings, level.nodes[j])
+			}
+		}
+	}
+	return nil
+}
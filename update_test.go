@@ -0,0 +1,170 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/txaty/go-merkletree/mock"
+)
+
+func TestMerkleTree_Update(t *testing.T) {
+	padding := [MaxDepth][]byte{}
+	for i := range padding {
+		padding[i] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	configs := []struct {
+		name   string
+		config Config
+	}{
+		{name: "test_default", config: Config{}},
+		{name: "test_duplicates", config: Config{Duplicates: true}},
+		{name: "test_padding", config: Config{Padding: padding}},
+		{name: "test_zero_padding", config: Config{ZeroPadding: true}},
+		{name: "test_sort_sibling_pairs", config: Config{SortSiblingPairs: true}},
+		{name: "test_parallel", config: Config{RunInParallel: true, NumRoutines: 4}},
+		{name: "test_contiguous", config: Config{ContiguousNodes: true}},
+		{name: "test_rfc6962", config: Config{RFC6962: true}},
+	}
+	for _, tt := range configs {
+		for _, mode := range []TypeConfigMode{ModeTreeBuild, ModeProofGenAndTreeBuild} {
+			for _, numBlocks := range []int{2, 5, 13, 64} {
+				config := tt.config
+				config.Mode = mode
+				blocks := generatedTestDataBlocks(numBlocks)
+				m, err := New(&config, blocks)
+				if err != nil {
+					t.Fatalf("%s: New() error = %v", tt.name, err)
+				}
+				// Update the last leaf, which may be duplicated, then a random leaf, then a batch.
+				updates := [][]int{{numBlocks - 1}, {rand.Intn(numBlocks)}, {0, numBlocks - 1, 1, numBlocks / 2, 0}}
+				for _, indices := range updates {
+					newBlocks := generatedTestDataBlocks(len(indices))
+					if len(indices) == 1 {
+						err = m.Update(indices[0], newBlocks[0])
+					} else {
+						err = m.UpdateBatch(indices, newBlocks)
+					}
+					if err != nil {
+						t.Fatalf("%s: UpdateBatch() error = %v", tt.name, err)
+					}
+					for i, idx := range indices {
+						blocks[idx] = newBlocks[i]
+					}
+					want, err := New(&config, blocks)
+					if err != nil {
+						t.Fatalf("%s: New() error = %v", tt.name, err)
+					}
+					if !bytes.Equal(m.Root, want.Root) {
+						t.Fatalf("%s mode %d blocks %d: root after UpdateBatch(%v) = %x, want %x",
+							tt.name, mode, numBlocks, indices, m.Root, want.Root)
+					}
+					for level := 0; level < want.Depth; level++ {
+						got, _ := getLevel(m.NodeStore, level)
+						wantLevel, _ := getLevel(want.NodeStore, level)
+						if !reflect.DeepEqual(got, wantLevel) {
+							t.Fatalf("%s: level %d mismatch after UpdateBatch(%v)", tt.name, level, indices)
+						}
+					}
+					if !reflect.DeepEqual(m.Leaves, want.Leaves) {
+						t.Fatalf("%s: leaves mismatch after UpdateBatch(%v)", tt.name, indices)
+					}
+					if mode == ModeProofGenAndTreeBuild && !reflect.DeepEqual(m.Proofs, want.Proofs) {
+						t.Fatalf("%s: proofs mismatch after UpdateBatch(%v)", tt.name, indices)
+					}
+				}
+				for i, block := range blocks {
+					got, err := m.LeafIndices(block)
+					if err != nil || !reflect.DeepEqual(got, []int{i}) {
+						t.Fatalf("%s: LeafIndices(%d) = %v, %v", tt.name, i, got, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleTree_UpdateLeafMap(t *testing.T) {
+	blocks := generatedTestDataBlocks(6)
+	m, err := New(&Config{Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	old := blocks[2]
+	if err = m.UpdateBatch([]int{4, 2}, []DataBlock{blocks[1], blocks[1]}); err != nil {
+		t.Fatalf("UpdateBatch() error = %v", err)
+	}
+	if _, err = m.LeafIndices(old); !errors.Is(err, ErrProofInvalidDataBlock) {
+		t.Errorf("LeafIndices() of the replaced block error = %v, want %v", err, ErrProofInvalidDataBlock)
+	}
+	if got, err := m.LeafIndices(blocks[1]); err != nil || !reflect.DeepEqual(got, []int{1, 2, 4}) {
+		t.Errorf("LeafIndices() = %v, %v, want [1 2 4]", got, err)
+	}
+	proof, err := m.Proof(blocks[1])
+	if err != nil {
+		t.Fatalf("Proof() error = %v", err)
+	}
+	if ok, err := m.VerifyByIndex(blocks[1], 4, proof); err != nil || !ok {
+		t.Errorf("VerifyByIndex() = %v, %v", ok, err)
+	}
+}
+
+func TestMerkleTree_UpdateHeldProofs(t *testing.T) {
+	blocks := generatedTestDataBlocks(8)
+	for _, config := range []Config{{}, {SortSiblingPairs: true}} {
+		config.Mode = ModeProofGenAndTreeBuild
+		m, err := New(&config, blocks)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		oldRoot := m.Root
+		held := m.Proofs[0]
+		want := copyProof(held)
+		if err = m.Update(1, generatedTestDataBlocks(1)[0]); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		// The proof held before the update still proves the block against the old root.
+		if !reflect.DeepEqual(held, want) {
+			t.Fatalf("the held proof is modified by Update()")
+		}
+		if ok, err := Verify(blocks[0], held, oldRoot, &config); err != nil || !ok {
+			t.Errorf("Verify() of the held proof = %v, %v", ok, err)
+		}
+		if ok, err := m.Verify(blocks[0], m.Proofs[0]); err != nil || !ok {
+			t.Errorf("Verify() of the updated proof = %v, %v", ok, err)
+		}
+	}
+}
+
+func TestMerkleTree_UpdateErrors(t *testing.T) {
+	blocks := generatedTestDataBlocks(5)
+	m, err := New(&Config{}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err = m.Update(0, blocks[1]); !errors.Is(err, ErrTreeNotBuilt) {
+		t.Errorf("Update() error = %v, want %v", err, ErrTreeNotBuilt)
+	}
+	m, err = New(&Config{Mode: ModeTreeBuild}, blocks)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	root := m.Root
+	if err = m.Update(5, blocks[0]); !errors.Is(err, ErrLeafIndexOutOfRange) {
+		t.Errorf("Update() error = %v, want %v", err, ErrLeafIndexOutOfRange)
+	}
+	if err = m.UpdateBatch([]int{0, 1}, blocks[:1]); !errors.Is(err, ErrUpdateMismatch) {
+		t.Errorf("UpdateBatch() error = %v, want %v", err, ErrUpdateMismatch)
+	}
+	if err = m.Update(0, nil); !errors.Is(err, ErrDataBlockIsNil) {
+		t.Errorf("Update() error = %v, want %v", err, ErrDataBlockIsNil)
+	}
+	if err = m.Update(0, &mock.DataBlock{Data: []byte("a")}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if bytes.Equal(m.Root, root) {
+		t.Errorf("root is not changed by Update()")
+	}
+}